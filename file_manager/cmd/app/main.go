@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"filemanager/internal/ffi"
 	"filemanager/internal/handler"
+	"filemanager/internal/journal"
 	"filemanager/internal/service"
+	"filemanager/internal/trash"
 	"filemanager/pkg/version"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -24,13 +29,18 @@ type OperationStyle struct {
 }
 
 var operationStyles = map[int]OperationStyle{
-	1: {Color: "\033[35m", Icon: "📁", Title: "CREATE FOLDER", Description: "Enter folder path(s) - space-separated for multiple folders"},
-	2: {Color: "\033[35m", Icon: "📄", Title: "CREATE FILE", Description: "Enter file(s) - space-separated for multiple"},
-	3: {Color: "\033[35m", Icon: "🔄", Title: "RENAME FILE/FOLDER", Description: "Enter old path and new name"},
-	4: {Color: "\033[35m", Icon: "🗑️", Title: "DELETE FILE/FOLDER", Description: "Enter path(s) to delete - space-separated"},
-	5: {Color: "\033[35m", Icon: "🔐", Title: "CHANGE PERMISSIONS", Description: "Enter path and permissions"},
-	6: {Color: "\033[35m", Icon: "➡️", Title: "MOVE FILE/FOLDER", Description: "Enter source and destination paths"},
-	7: {Color: "\033[35m", Icon: "📋", Title: "COPY FILE/FOLDER", Description: "Enter source and destination paths"},
+	1:  {Color: "\033[35m", Icon: "📁", Title: "CREATE FOLDER", Description: "Enter folder path(s) - space-separated (--mode=700, --owner=, --exclusive, --no-parents)"},
+	2:  {Color: "\033[35m", Icon: "📄", Title: "CREATE FILE", Description: "Enter file(s) - space-separated (--mode=600, --owner=, --exclusive, --no-parents, --content)"},
+	3:  {Color: "\033[35m", Icon: "🔄", Title: "RENAME FILE/FOLDER", Description: "Enter old path and new name"},
+	4:  {Color: "\033[35m", Icon: "🗑️", Title: "DELETE FILE/FOLDER", Description: "Enter path to move to the trash (-p: delete permanently)"},
+	5:  {Color: "\033[35m", Icon: "🔐", Title: "CHANGE PERMISSIONS", Description: "Enter path (-R: recursive), then permissions"},
+	6:  {Color: "\033[35m", Icon: "➡️", Title: "MOVE FILE/FOLDER", Description: "Enter source and destination paths"},
+	7:  {Color: "\033[35m", Icon: "📋", Title: "COPY FILE/FOLDER", Description: "Enter source and destination (-a: archive, --verify[-blake3])"},
+	10: {Color: "\033[35m", Icon: "♻️", Title: "TRASH", Description: "Enter numbers to restore - space-separated, 'e' to empty the trash"},
+	11: {Color: "\033[31m", Icon: "🔥", Title: "SHRED FILE/FOLDER", Description: "Enter path to overwrite and delete (--verify: read back)"},
+	12: {Color: "\033[35m", Icon: "👤", Title: "CHANGE OWNER", Description: "Enter path (-R: recursive, -h: links themselves)"},
+	13: {Color: "\033[35m", Icon: "🏷️", Title: "BULK RENAME", Description: "Enter folder or glob such as photos/*.JPG (--include=, --exclude=)"},
+	14: {Color: "\033[35m", Icon: "🕘", Title: "HISTORY", Description: "Enter u to undo, r to redo, or press Enter to go back"},
 }
 
 // displayOperationProgress shows styled progress output for operations
@@ -50,6 +60,272 @@ func displayOperationProgress(operation int, status string, success bool) {
 	}
 }
 
+// newProgressBar returns a ProgressFunc that renders a progress bar in the operation's color
+// Rendering is throttled; call finishProgressBar once the operation returns
+func newProgressBar(operation int) ffi.ProgressFunc {
+	style := operationStyles[operation]
+	var lastRender time.Time
+
+	return func(p ffi.Progress) {
+		if !p.Done() && time.Since(lastRender) < 100*time.Millisecond {
+			return
+		}
+		lastRender = time.Now()
+		renderProgressBar(style.Color, p)
+	}
+}
+
+// renderProgressBar draws a single-line progress bar, overwriting the previous one
+func renderProgressBar(color string, p ffi.Progress) {
+	reset := "\033[0m"
+	clearLine := "\033[K"
+	barWidth := 30
+
+	filled := int(p.Percent() / 100 * float64(barWidth))
+	if filled > barWidth {
+		filled = barWidth
+	}
+
+	fmt.Printf("\r%s[%s%s]%s %5.1f%% %d/%d files %s/%s%s",
+		color, strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled), reset,
+		p.Percent(), p.FilesDone, p.FilesTotal, formatBytes(p.BytesDone), formatBytes(p.BytesTotal), clearLine)
+}
+
+// finishProgressBar ends the progress bar line
+func finishProgressBar() {
+	fmt.Println()
+}
+
+// interruptContext returns a context that is cancelled when the user presses Ctrl+C,
+// so long-running operations stop between files instead of killing the program
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(menuContext, os.Interrupt)
+}
+
+// takeFlag removes any of the given flags from a line of input and reports whether
+// one was present, so prompts can accept options such as "src -a"
+func takeFlag(input string, names ...string) (string, bool) {
+	fields := strings.Fields(input)
+	rest := fields[:0]
+	found := false
+	for _, field := range fields {
+		matched := false
+		for _, name := range names {
+			if field == name {
+				matched = true
+			}
+		}
+		if matched {
+			found = true
+		} else {
+			rest = append(rest, field)
+		}
+	}
+	if !found {
+		// Keep paths containing repeated spaces intact
+		return strings.TrimSpace(input), false
+	}
+	return strings.Join(rest, " "), true
+}
+
+// takeOption removes every "name=value" option of the given names from a line of
+// input and returns their values, so prompts can accept options such as "--exclude=*.go"
+func takeOption(input string, names ...string) (string, []string) {
+	fields := strings.Fields(input)
+	rest := fields[:0]
+	var values []string
+	for _, field := range fields {
+		matched := false
+		for _, name := range names {
+			if value, ok := strings.CutPrefix(field, name+"="); ok {
+				values = append(values, value)
+				matched = true
+			}
+		}
+		if !matched {
+			rest = append(rest, field)
+		}
+	}
+	if values == nil {
+		// Keep paths containing repeated spaces intact
+		return strings.TrimSpace(input), nil
+	}
+	return strings.Join(rest, " "), values
+}
+
+// takeMatchOptions removes the glob and filter options --include=, --exclude=,
+// --min-size=, --max-size=, --older-than= and --newer-than= from a line of input
+func takeMatchOptions(input string) (string, service.MatchOptions, error) {
+	var opts service.MatchOptions
+	input, opts.Include = takeOption(input, "--include")
+	input, opts.Exclude = takeOption(input, "--exclude")
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if err := service.ValidatePattern(pattern); err != nil {
+			return input, opts, err
+		}
+	}
+
+	var values []string
+	var err error
+	for _, size := range []struct {
+		name  string
+		value *int64
+	}{{"--min-size", &opts.MinSize}, {"--max-size", &opts.MaxSize}} {
+		if input, values = takeOption(input, size.name); len(values) > 0 {
+			if *size.value, err = service.ParseSize(values[len(values)-1]); err != nil {
+				return input, opts, err
+			}
+		}
+	}
+	for _, age := range []struct {
+		name  string
+		value *time.Duration
+	}{{"--older-than", &opts.OlderThan}, {"--newer-than", &opts.NewerThan}} {
+		if input, values = takeOption(input, age.name); len(values) > 0 {
+			if *age.value, err = service.ParseAge(values[len(values)-1]); err != nil {
+				return input, opts, err
+			}
+		}
+	}
+	return input, opts, nil
+}
+
+// isBulk reports whether path and opts select entries by pattern or filter
+func isBulk(path string, opts service.MatchOptions) bool {
+	return fileService.IsGlob(path) || !opts.IsZero()
+}
+
+// maxListedMatches limits the matches printed before a summary line
+const maxListedMatches = 40
+
+// runBulk lists the entries path and opts select and applies run to them, first
+// asking with question, whose %d is the number of matches, unless it is empty
+func runBulk(scanner *bufio.Scanner, operation int, path string, opts service.MatchOptions, question string,
+	run func(context.Context, []service.Match) []ffi.Result) {
+	matches, err := fileService.GlobPaths(menuContext, []string{path}, opts)
+	if err != nil {
+		displayOperationProgress(operation, fmt.Sprintf("Failed to match %s: %v", path, err), false)
+		return
+	}
+	if len(matches) == 0 {
+		fmt.Printf("ℹ️  Nothing matches %s\n", path)
+		return
+	}
+
+	fmt.Printf("🔎 %d matching entries:\n", len(matches))
+	for i, match := range matches {
+		if i == maxListedMatches {
+			fmt.Printf("   … and %d more\n", len(matches)-i)
+			break
+		}
+		if match.IsDir {
+			fmt.Printf("   📁 %s/\n", match.Path)
+		} else {
+			fmt.Printf("   📄 %s (%s)\n", match.Path, formatBytes(uint64(match.Size)))
+		}
+	}
+
+	if question != "" {
+		fmt.Printf("⚠️  "+question+" (yes/no): ", len(matches))
+		if !scanner.Scan() {
+			return
+		}
+		confirmation := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if confirmation != "yes" && confirmation != "y" {
+			fmt.Println("❌ Cancelled")
+			return
+		}
+	}
+
+	fmt.Println()
+	ctx, stop := interruptContext()
+	results := run(ctx, matches)
+	stop()
+	failed := 0
+	for i, result := range results {
+		if !result.Success {
+			failed++
+			fmt.Printf("  ❌ %s: %s\n", matches[i].Path, result.Message)
+		}
+	}
+	displayOperationProgress(operation, fmt.Sprintf("%d of %d matching entries done, %d failed", len(results)-failed, len(results), failed), failed == 0)
+	fmt.Println()
+}
+
+// conflictChoices lists the conflict policies offered when a destination exists
+var conflictChoices = []struct {
+	policy ffi.ConflictPolicy
+	label  string
+}{
+	{ffi.ConflictFail, "Cancel"},
+	{ffi.ConflictOverwrite, "Overwrite"},
+	{ffi.ConflictSkip, "Skip existing entries"},
+	{ffi.ConflictKeepBoth, "Keep both (adds \"(1)\" to the name)"},
+	{ffi.ConflictOverwriteIfNewer, "Overwrite only if newer"},
+}
+
+// promptConflictPolicy asks how to handle an existing destination
+// It returns false when the user cancels or input ends
+func promptConflictPolicy(scanner *bufio.Scanner, dst string) (ffi.ConflictPolicy, bool) {
+	if _, err := backend.Lstat(dst); err != nil {
+		return ffi.ConflictOverwrite, true
+	}
+
+	fmt.Printf("\n⚠️  '%s' already exists. What should happen?\n", dst)
+	for i, choice := range conflictChoices {
+		fmt.Printf("  %d. %s\n", i+1, choice.label)
+	}
+	fmt.Print("Choice [1]: ")
+
+	if !scanner.Scan() {
+		return ffi.ConflictFail, false
+	}
+	input := strings.TrimSpace(scanner.Text())
+	if input == "" {
+		input = "1"
+	}
+
+	index, err := strconv.Atoi(input)
+	if err != nil || index < 1 || index > len(conflictChoices) {
+		fmt.Println("❌ Invalid choice")
+		return ffi.ConflictFail, false
+	}
+	if conflictChoices[index-1].policy == ffi.ConflictFail {
+		fmt.Println("❌ Operation cancelled")
+		return ffi.ConflictFail, false
+	}
+	return conflictChoices[index-1].policy, true
+}
+
+// printConflicts lists the destination entries that already existed
+func printConflicts(result ffi.Result) {
+	for _, conflict := range result.Conflicts {
+		fmt.Printf("  ↪ %s: %s\n", conflict.Action, conflict.Dest)
+	}
+}
+
+// printMismatches lists the copied files that failed verification
+func printMismatches(result ffi.Result) {
+	for _, mismatch := range result.Mismatches {
+		fmt.Printf("  ✗ %s: %s\n", mismatch.Dest, mismatch.Reason)
+	}
+}
+
+// formatBytes renders a byte count using binary units
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
 // displayInputBox shows a styled input box with Midnight Purple theme
 func displayInputBox(prompt string) {
 	// Midnight Purple color scheme
@@ -109,12 +385,125 @@ func displayStyledPrompt(operation int, prompt string) {
 	fmt.Print("> ")
 }
 
+// backend runs the file operations of the CLI and the web server
+// It is selected with --backend or the FILEMANAGER_BACKEND environment variable
+var backend ffi.Backend
+
+// fileService runs multi-step operations such as template creation on backend
+var fileService *service.FileService
+
+// trashCan moves deleted entries of backend to the trash of the user
+var trashCan *trash.Trash
+
+// dryRun is set by --dry-run; it wraps backend so operations are planned instead of run
+var dryRun *ffi.DryRun
+
+// history records the changes made through backend so they can be undone; it is
+// nil in a dry run
+var history *journal.Journal
+
+// menuContext is the context of the menu operation being run, whose changes the
+// history records as one entry
+var menuContext = context.Background()
+
+// beginOperation starts recording the changes of a menu operation; the returned
+// function records them as one entry of the history
+func beginOperation() func() {
+	if history == nil {
+		return func() {}
+	}
+	ctx, end := history.Begin(context.Background())
+	menuContext = ctx
+	return func() {
+		end()
+		menuContext = context.Background()
+	}
+}
+
+// selectBackend removes a "--backend name" or "--backend=name" flag and a "--dry-run"
+// flag from args and initializes backend from them, falling back to the environment
+func selectBackend(args []string) ([]string, error) {
+	name := os.Getenv(ffi.BackendEnv)
+	plan := false
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--backend" && i+1 < len(args):
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--backend="):
+			name = strings.TrimPrefix(args[i], "--backend=")
+		case args[i] == "--dry-run":
+			plan = true
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+
+	selected, err := ffi.NewBackend(name)
+	if err != nil {
+		return nil, err
+	}
+	if plan {
+		dryRun = ffi.NewDryRun(selected)
+		selected = dryRun
+	}
+	backend = selected
+	trashCan = trash.New(selected)
+	if dryRun == nil {
+		// The paths of a memory backend mean nothing to the next run
+		path := ""
+		if selected.Name() != ffi.BackendMemory {
+			path, _ = journal.DefaultPath()
+		}
+		history = journal.New(selected, path)
+		backend = history.Backend()
+		trashCan = history.Trash()
+	}
+	fileService = service.NewFileService(backend)
+	return rest, nil
+}
+
+// printPlan shows what the last operation of a dry run would have done
+func printPlan(plan ffi.Plan) {
+	if len(plan.Actions) == 0 && len(plan.Existing) == 0 && len(plan.Conflicts) == 0 {
+		return
+	}
+
+	fmt.Printf("\n📋 Dry run plan (%d actions, nothing was changed):\n", len(plan.Actions))
+	for _, action := range plan.Actions {
+		line := action.Path
+		switch {
+		case action.Op == ffi.ActionChmod:
+			line += fmt.Sprintf(" (%04o)", action.Mode)
+		case action.Dest != "":
+			line += " → " + action.Dest
+		}
+		if action.Op == ffi.ActionCopyFile || action.Op == ffi.ActionWriteFile {
+			line += fmt.Sprintf(" (%s)", formatBytes(uint64(action.Size)))
+		}
+		fmt.Printf("   %-13s %s\n", action.Op, line)
+	}
+	for _, path := range plan.Existing {
+		fmt.Printf("   ℹ️  Already exists: %s\n", path)
+	}
+	for _, conflict := range plan.Conflicts {
+		fmt.Printf("   ⚠️  %s: %s\n", conflict.Action, conflict.Dest)
+	}
+}
+
 func main() {
 	scanner := bufio.NewScanner(os.Stdin)
 
+	args, err := selectBackend(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(2)
+	}
+
 	// Handle command-line flags
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
+	if len(args) > 0 {
+		switch args[0] {
 		case "--version", "-v":
 			version.ShowVersion()
 			return
@@ -126,15 +515,31 @@ func main() {
 			return
 		case "--web", "-w":
 			// Start web server mode directly
-			if err := handler.StartWebServer(); err != nil {
+			if err := handler.StartWebServer(backend, history); err != nil {
 				fmt.Fprintf(os.Stderr, "❌ Server failed to start: %v\n", err)
 				os.Exit(1)
 			}
 			return
+		case "undo", "redo", "history":
+			if !runHistoryCommand(args[0]) {
+				os.Exit(1)
+			}
+			return
+		case "touch":
+			end := beginOperation()
+			ok := runTouch(args[1:], os.Stdin)
+			end()
+			if !ok {
+				os.Exit(1)
+			}
+			return
 		}
 	}
 
 	version.ShowBanner()
+	if dryRun != nil {
+		fmt.Println("🧪 Dry run: operations are planned and shown, nothing is changed on disk")
+	}
 
 	// Check for updates on startup (non-blocking)
 	go func() {
@@ -146,13 +551,14 @@ func main() {
 		displayMenu()
 
 		fmt.Println()
-		displayInputBox("Enter your choice (0-9)")
+		displayInputBox("Enter your choice (0-9, t, s, o, b, h)")
 		if !scanner.Scan() {
 			break
 		}
 
 		choice := strings.TrimSpace(scanner.Text())
 
+		end := beginOperation()
 		switch choice {
 		case "0":
 			fmt.Println("\n👋 Goodbye!")
@@ -176,9 +582,24 @@ func main() {
 		case "9":
 			handleWebServerLaunch()
 			return
+		case "t", "T":
+			handleTrash(scanner)
+		case "s", "S":
+			handleShred(scanner)
+		case "o", "O":
+			handleChangeOwner(scanner)
+		case "b", "B":
+			handleBulkRename(scanner)
+		case "h", "H":
+			handleHistory(scanner)
 		default:
 			fmt.Println("❌ Invalid choice. Please try again.")
 		}
+		end()
+
+		if dryRun != nil {
+			printPlan(dryRun.TakePlan())
+		}
 	}
 }
 
@@ -190,12 +611,30 @@ func showHelp() {
 	fmt.Println("  filemanager --update     Check for updates")
 	fmt.Println("  filemanager --help       Show this help message")
 	fmt.Println("  filemanager --web        Start web interface")
+	fmt.Println("  filemanager undo         Undo the last operation")
+	fmt.Println("  filemanager redo         Redo the last undone operation")
+	fmt.Println("  filemanager history      List the recorded operations")
+	fmt.Println("  filemanager touch PATH…  Create files, writing what is piped in to them")
+	fmt.Println("                           (--mode=600, --owner=USER, --exclusive, --no-parents,")
+	fmt.Println("                           --stdin to read the input when it is not piped in)")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --backend <name>         File operation backend: rust, native or memory")
+	fmt.Printf("                           (default: $%s, otherwise rust; native on Windows)\n", ffi.BackendEnv)
+	fmt.Println("  --dry-run                Show the planned changes of each operation instead of making them")
 	fmt.Println()
 	fmt.Println("Features:")
 	fmt.Println("  • Single & batch file/folder operations")
 	fmt.Println("  • 12 project templates (Flask, Spring Boot, React, etc.)")
 	fmt.Println("  • Interactive structure builder")
 	fmt.Println("  • Auto parent directory creation")
+	fmt.Println("  • Deletes go to the XDG trash; restore or empty it from the menu (t)")
+	fmt.Println("  • Shred overwrites files before deleting them, for credentials and keys (s)")
+	fmt.Println("  • Change the owner and group of files, by name or numeric ID (o)")
+	fmt.Println("  • Delete, copy, move and chmod by glob (**/*.pyc) with --include=, --exclude=,")
+	fmt.Println("    --min-size=, --max-size=, --older-than= and --newer-than= after the path")
+	fmt.Println("  • Bulk rename by regex, case, slug, sequence {n:03} and date {date} with a preview (b)")
+	fmt.Println("  • Moves, renames, creations, chmods and deletes to the trash can be undone (h)")
 	fmt.Println("  • Cross-platform support (Linux, macOS, Windows)")
 	fmt.Println("  • Web interface for browser-based management")
 	fmt.Println()
@@ -223,6 +662,11 @@ func displayMenu() {
 		"7️⃣  Copy File/Folder",
 		"8️⃣  Create Structure (Multi-entity)",
 		"9️⃣  Launch Web Interface",
+		"♻️  t: Trash (restore or empty)",
+		"🔥 s: Shred (secure delete)",
+		"👤 o: Change Owner",
+		"🏷️  b: Bulk Rename",
+		"🕘 h: History (undo/redo)",
 		"0️⃣  Exit",
 	}
 
@@ -262,7 +706,7 @@ func handleWebServerLaunch() {
 	fmt.Printf("%s%s└%s┘%s\n", primary, bold, strings.Repeat("─", boxWidth-2), reset)
 	fmt.Println()
 
-	if err := handler.StartWebServer(); err != nil {
+	if err := handler.StartWebServer(backend, history); err != nil {
 		fmt.Fprintf(os.Stderr, "❌ Server failed to start: %v\n", err)
 	}
 }
@@ -274,7 +718,11 @@ func handleCreateFolder(scanner *bufio.Scanner) {
 		return
 	}
 
-	input := strings.TrimSpace(scanner.Text())
+	input, opts, err := takeCreateOptions(scanner.Text(), true)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
 	if input == "" {
 		fmt.Println("❌ Path cannot be empty")
 		return
@@ -291,7 +739,7 @@ func handleCreateFolder(scanner *bufio.Scanner) {
 
 	fmt.Println()
 	for _, path := range paths {
-		result := ffi.CreateFolder(path)
+		result := backend.CreateFolder(menuContext, path, opts)
 		if result.Success {
 			successCount++
 			displayOperationProgress(1, fmt.Sprintf("Created folder: %s", path), true)
@@ -307,6 +755,33 @@ func handleCreateFolder(scanner *bufio.Scanner) {
 	fmt.Println()
 }
 
+// takeCreateOptions removes the creation options from the input of create folder,
+// create file or touch and returns the rest with the options they give:
+// --mode=MODE, --owner=OWNER, -x or --exclusive, and --no-parents
+func takeCreateOptions(input string, isDir bool) (string, ffi.CreateOptions, error) {
+	input, exclusive := takeFlag(input, "-x", "--exclusive")
+	input, noParents := takeFlag(input, "--no-parents")
+	input, modes := takeOption(input, "--mode")
+	input, owners := takeOption(input, "--owner")
+
+	opts := ffi.CreateOptions{Exclusive: exclusive, Parents: !noParents}
+	if len(modes) > 0 {
+		mode, err := ffi.ParseNewMode(modes[len(modes)-1], isDir)
+		if err != nil {
+			return input, opts, err
+		}
+		opts.Mode = mode
+	}
+	if len(owners) > 0 {
+		owner, err := ffi.ParseOwnerSpec(owners[len(owners)-1])
+		if err != nil {
+			return input, opts, err
+		}
+		opts.Owner = owner
+	}
+	return input, opts, nil
+}
+
 func handleCreateFile(scanner *bufio.Scanner) {
 	fmt.Println()
 	displayStyledPrompt(2, "")
@@ -314,7 +789,12 @@ func handleCreateFile(scanner *bufio.Scanner) {
 		return
 	}
 
-	input := strings.TrimSpace(scanner.Text())
+	input, withContent := takeFlag(scanner.Text(), "--content")
+	input, opts, err := takeCreateOptions(input, false)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
 	if input == "" {
 		fmt.Println("❌ Path cannot be empty")
 		return
@@ -326,34 +806,48 @@ func handleCreateFile(scanner *bufio.Scanner) {
 		return
 	}
 
+	var content []byte
+	if withContent {
+		fmt.Println("Enter the content, ending with a line holding only a dot (.):")
+		var lines []string
+		for scanner.Scan() && scanner.Text() != "." {
+			lines = append(lines, scanner.Text()+"\n")
+		}
+		content = []byte(strings.Join(lines, ""))
+	}
+
+	fmt.Println()
+	createFiles(paths, content, opts, validateFilePath)
+	fmt.Println()
+}
+
+// createFiles creates every path that validate accepts per opts, writing content to
+// the files unless it is nil, and reports whether all of them were created
+func createFiles(paths []string, content []byte, opts ffi.CreateOptions, validate func(string) error) bool {
 	successCount := 0
 	errorCount := 0
 
-	fmt.Println()
 	for _, path := range paths {
 		// Validate the path
-		if err := validateFilePath(path); err != nil {
+		if err := validate(path); err != nil {
 			displayOperationProgress(2, fmt.Sprintf("Invalid path %s: %s", path, err.Error()), false)
 			errorCount++
 			continue
 		}
 
-		// Create parent directories if needed
-		dir := filepath.Dir(path)
-		if dir != "." && dir != path {
-			result := ffi.CreateFolder(dir)
-			if !result.Success {
-				displayOperationProgress(2, fmt.Sprintf("Failed to create directory %s: %s", dir, result.Message), false)
-				errorCount++
-				continue
-			}
-			displayOperationProgress(2, fmt.Sprintf("Created directory: %s", dir), true)
+		var result ffi.Result
+		if content == nil {
+			result = backend.CreateFile(menuContext, path, opts)
+		} else {
+			result = ffi.CreateFileWithContent(menuContext, backend, path, content, opts)
 		}
-
-		result := ffi.CreateFile(path)
 		if result.Success {
 			successCount++
-			displayOperationProgress(2, fmt.Sprintf("Created file: %s", path), true)
+			if content != nil {
+				displayOperationProgress(2, fmt.Sprintf("Created file: %s (%d bytes)", path, len(content)), true)
+			} else {
+				displayOperationProgress(2, fmt.Sprintf("Created file: %s", path), true)
+			}
 		} else {
 			errorCount++
 			displayOperationProgress(2, fmt.Sprintf("Failed to create %s: %s", path, result.Message), false)
@@ -363,7 +857,64 @@ func handleCreateFile(scanner *bufio.Scanner) {
 	if len(paths) > 1 {
 		fmt.Printf("📊 Summary: %d succeeded, %d failed\n", successCount, errorCount)
 	}
-	fmt.Println()
+	return errorCount == 0
+}
+
+// runTouch runs "filemanager touch [options] PATH...", writing what stdin holds to
+// the files when it is redirected or piped in, or --stdin is given, and reports
+// whether it succeeded
+func runTouch(args []string, stdin io.Reader) bool {
+	var options, paths []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			options = append(options, arg)
+		} else {
+			paths = append(paths, arg)
+		}
+	}
+	rest, fromStdin := takeFlag(strings.Join(options, " "), "--stdin")
+	rest, opts, err := takeCreateOptions(rest, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return false
+	}
+	if rest != "" || len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ Usage: filemanager touch [--stdin] [--mode=MODE] [--owner=OWNER] [--exclusive] [--no-parents] PATH...")
+		return false
+	}
+
+	var content []byte
+	if fromStdin || isRedirected(stdin) {
+		if content, err = io.ReadAll(stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Cannot read the standard input: %v\n", err)
+			return false
+		}
+	}
+	return createFiles(paths, content, opts, validatePath)
+}
+
+// isRedirected reports whether input is a file or pipe rather than a terminal
+// Character devices such as a terminal or /dev/null, and sockets, are not read
+// unless asked for.
+func isRedirected(input io.Reader) bool {
+	file, ok := input.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && (info.Mode().IsRegular() || info.Mode()&os.ModeNamedPipe != 0)
+}
+
+// validatePath checks that path can name a file at all; unlike validateFilePath it
+// accepts any name, such as Makefile, .env.local or app.config.json
+func validatePath(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("invalid path - the path is empty")
+	}
+	if strings.ContainsRune(path, 0) {
+		return fmt.Errorf("invalid path - the path holds a NUL character")
+	}
+	return nil
 }
 
 func validateFilePath(path string) error {
@@ -425,7 +976,7 @@ func handleRename(scanner *bufio.Scanner) {
 	}
 
 	fmt.Println()
-	result := ffi.RenamePath(oldPath, newPath)
+	result := backend.RenamePath(menuContext, oldPath, newPath)
 	if !result.Success {
 		displayOperationProgress(3, fmt.Sprintf("Failed to rename %s: %s", oldPath, result.Message), false)
 	} else {
@@ -441,13 +992,38 @@ func handleDelete(scanner *bufio.Scanner) {
 		return
 	}
 
-	path := strings.TrimSpace(scanner.Text())
+	path, permanent := takeFlag(scanner.Text(), "-p", "--permanent")
+	path, match, err := takeMatchOptions(path)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
 	if path == "" {
 		fmt.Println("❌ Path cannot be empty")
 		return
 	}
 
-	fmt.Printf("⚠️  Are you sure you want to delete '%s'? (yes/no): ", path)
+	if isBulk(path, match) {
+		question := "Move these %d entries to the trash?"
+		if permanent {
+			question = "Delete these %d entries permanently? They cannot be restored"
+		}
+		runBulk(scanner, 4, path, match, question, func(ctx context.Context, matches []service.Match) []ffi.Result {
+			return fileService.ApplyMatches(ctx, matches, func(ctx context.Context, m service.Match) ffi.Result {
+				if permanent {
+					return backend.DeletePath(ctx, m.Path, ffi.DeleteOptions{}, nil)
+				}
+				return trashCan.Put(ctx, m.Path, nil)
+			})
+		})
+		return
+	}
+
+	if permanent {
+		fmt.Printf("⚠️  Are you sure you want to delete '%s' permanently? It cannot be restored (yes/no): ", path)
+	} else {
+		fmt.Printf("⚠️  Move '%s' to the trash? (yes/no): ", path)
+	}
 	if !scanner.Scan() {
 		return
 	}
@@ -459,24 +1035,157 @@ func handleDelete(scanner *bufio.Scanner) {
 	}
 
 	fmt.Println()
-	result := ffi.DeletePath(path)
-	if result.Success {
+	ctx, stop := interruptContext()
+	var result ffi.Result
+	if permanent {
+		result = backend.DeletePath(ctx, path, ffi.DeleteOptions{}, newProgressBar(4))
+	} else {
+		result = trashCan.Put(ctx, path, newProgressBar(4))
+	}
+	stop()
+	finishProgressBar()
+	if result.Success && permanent {
 		displayOperationProgress(4, fmt.Sprintf("Deleted: %s", path), true)
+	} else if result.Success {
+		displayOperationProgress(4, fmt.Sprintf("Moved to the trash: %s", path), true)
 	} else {
 		displayOperationProgress(4, fmt.Sprintf("Failed to delete %s: %s", path, result.Message), false)
 	}
 	fmt.Println()
 }
 
+// handleShred overwrites and deletes a file or folder after a typed confirmation
+func handleShred(scanner *bufio.Scanner) {
+	fmt.Println()
+	displayStyledPrompt(11, "")
+	if !scanner.Scan() {
+		return
+	}
+
+	path, verify := takeFlag(scanner.Text(), "--verify")
+	if path == "" {
+		fmt.Println("❌ Path cannot be empty")
+		return
+	}
+
+	fmt.Printf("🔢 Overwrite passes [%d]: ", ffi.DefaultShredPasses)
+	if !scanner.Scan() {
+		return
+	}
+	passes := ffi.DefaultShredPasses
+	if input := strings.TrimSpace(scanner.Text()); input != "" {
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 1 {
+			fmt.Println("❌ Passes must be a positive number")
+			return
+		}
+		passes = n
+	}
+
+	red := "\033[31m"
+	bold := "\033[1m"
+	reset := "\033[0m"
+	fmt.Printf("%s%s🔥 SHRED '%s': every file is overwritten %d time(s) and deleted.%s\n", red, bold, path, passes, reset)
+	fmt.Printf("%s%s   This cannot be undone and the trash is bypassed.%s\n", red, bold, reset)
+	fmt.Print("⚠️  Type SHRED to confirm: ")
+	if !scanner.Scan() {
+		return
+	}
+	if strings.TrimSpace(scanner.Text()) != "SHRED" {
+		fmt.Println("❌ Shred cancelled")
+		return
+	}
+
+	fmt.Println()
+	ctx, stop := interruptContext()
+	result := backend.DeletePath(ctx, path, ffi.DeleteOptions{ShredPasses: passes, VerifyShred: verify}, newProgressBar(11))
+	stop()
+	finishProgressBar()
+	displayOperationProgress(11, result.Message, result.Success)
+	for _, skipped := range result.Skipped {
+		fmt.Printf("   ⚠️  Left in place: %s (%s)\n", skipped.Path, skipped.Reason)
+	}
+	fmt.Println()
+}
+
+// handleTrash lists the trash and restores the chosen entries or empties it
+func handleTrash(scanner *bufio.Scanner) {
+	fmt.Println()
+	items, err := trashCan.List()
+	if err != nil {
+		displayOperationProgress(10, fmt.Sprintf("Cannot read the trash: %v", err), false)
+		return
+	}
+	if len(items) == 0 {
+		fmt.Println("♻️  The trash is empty")
+		fmt.Println()
+		return
+	}
+
+	for i, item := range items {
+		kind := "📄"
+		if item.IsDir {
+			kind = "📁"
+		}
+		fmt.Printf("  %2d. %s %s  (%s, %s)\n", i+1, kind, item.OriginalPath,
+			item.DeletionDate.Format("2006-01-02 15:04"), formatBytes(uint64(item.Size)))
+	}
+	fmt.Println()
+
+	displayStyledPrompt(10, "")
+	if !scanner.Scan() {
+		return
+	}
+	input := strings.TrimSpace(scanner.Text())
+	if input == "" {
+		return
+	}
+
+	if strings.EqualFold(input, "e") {
+		fmt.Printf("⚠️  Permanently delete the %d item(s) in the trash? (yes/no): ", len(items))
+		if !scanner.Scan() {
+			return
+		}
+		confirmation := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if confirmation != "yes" && confirmation != "y" {
+			fmt.Println("❌ Emptying the trash cancelled")
+			return
+		}
+
+		ctx, stop := interruptContext()
+		result := trashCan.Empty(ctx, nil)
+		stop()
+		displayOperationProgress(10, result.Message, result.Success)
+		fmt.Println()
+		return
+	}
+
+	for _, field := range strings.Fields(input) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(items) {
+			displayOperationProgress(10, fmt.Sprintf("Invalid item number: %s", field), false)
+			continue
+		}
+		result := trashCan.Restore(menuContext, items[n-1].Path)
+		displayOperationProgress(10, result.Message, result.Success)
+	}
+	fmt.Println()
+}
+
 func handleChangePermissions(scanner *bufio.Scanner) {
 	fmt.Println()
 	displayStyledPrompt(5, "")
 	if !scanner.Scan() {
 		return
 	}
-	path := strings.TrimSpace(scanner.Text())
+	path, recursive := takeFlag(scanner.Text(), "-R", "--recursive")
+	path, match, err := takeMatchOptions(path)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
 
-	fmt.Print("Enter permissions (octal, e.g., 755): ")
+	fmt.Print("Enter permissions (octal like 755 or 2775, symbolic like u+x,go-w or a=rX): ")
 	if !scanner.Scan() {
 		return
 	}
@@ -487,43 +1196,403 @@ func handleChangePermissions(scanner *bufio.Scanner) {
 		return
 	}
 
-	mode, err := strconv.ParseUint(modeStr, 8, 32)
+	fileMode, err := ffi.ParseModeSpec(modeStr)
 	if err != nil {
-		fmt.Printf("❌ Invalid permission format. Please enter a valid octal value (e.g., 755, 644)\n")
-		fmt.Printf("   Tip: 755 = rwxr-xr-x, 644 = rw-r--r--\n")
+		fmt.Printf("❌ %v\n", err)
+		fmt.Printf("   Tip: 755 = rwxr-xr-x, 644 = rw-r--r--, u+x adds execute for the owner, a=rX makes everything readable\n")
 		return
 	}
 
+	opts := ffi.ChmodOptions{FileMode: fileMode, DirMode: fileMode, Recursive: recursive}
+	if isBulk(path, match) {
+		runBulk(scanner, 5, path, match, "Change the permissions of these %d entries?", func(ctx context.Context, matches []service.Match) []ffi.Result {
+			return fileService.ApplyMatches(ctx, matches, func(ctx context.Context, m service.Match) ffi.Result {
+				return ffi.ChangeModes(ctx, backend, m.Path, opts)
+			})
+		})
+		return
+	}
+	if recursive {
+		fmt.Print("Enter permissions for folders (empty: the same): ")
+		if !scanner.Scan() {
+			return
+		}
+		if dirStr := strings.TrimSpace(scanner.Text()); dirStr != "" {
+			if opts.DirMode, err = ffi.ParseModeSpec(dirStr); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				return
+			}
+		}
+	}
+
+	// Show every change before a recursive one is made
+	fmt.Println()
+	opts.Preview = true
+	preview := ffi.ChangeModes(menuContext, backend, path, opts)
+	if !preview.Success {
+		displayOperationProgress(5, fmt.Sprintf("Failed to change permissions: %s", preview.Message), false)
+		return
+	}
+	printModeChanges(preview)
+	if recursive {
+		fmt.Print("⚠️  Apply these changes? (yes/no): ")
+		if !scanner.Scan() {
+			return
+		}
+		confirmation := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if confirmation != "yes" && confirmation != "y" {
+			fmt.Println("❌ Permission change cancelled")
+			return
+		}
+	}
+
 	fmt.Println()
-	result := ffi.ChangePermissions(path, uint32(mode))
+	opts.Preview = false
+	result := ffi.ChangeModes(menuContext, backend, path, opts)
 	if !result.Success {
 		displayOperationProgress(5, fmt.Sprintf("Failed to change permissions: %s", result.Message), false)
 		return
 	}
 
-	displayOperationProgress(5, fmt.Sprintf("Changed permissions to %s for %s", modeStr, path), true)
+	displayOperationProgress(5, result.Message, true)
 	fmt.Println()
-	displayPermissionInfo(modeStr)
+	if len(result.ModeChanges) > 0 {
+		displayPermissionInfo(result.ModeChanges[0].New)
+	}
 	fmt.Println()
 }
 
-// displayPermissionInfo shows detailed explanation of the permission mode
-func displayPermissionInfo(modeStr string) {
-	fmt.Println("📋 Permission Breakdown:")
-	fmt.Println("────────────────────────────────────────")
+// handleChangeOwner changes the owner and group of a file or folder
+func handleChangeOwner(scanner *bufio.Scanner) {
+	fmt.Println()
+	displayStyledPrompt(12, "")
+	if !scanner.Scan() {
+		return
+	}
+	path, recursive := takeFlag(scanner.Text(), "-R", "--recursive")
+	path, noDereference := takeFlag(path, "-h", "--no-dereference")
 
-	if len(modeStr) != 3 {
-		fmt.Println("   Invalid format")
+	fmt.Print("Enter owner (user, user:group, :group or user: for their login group; names or IDs): ")
+	if !scanner.Scan() {
+		return
+	}
+	ownerStr := strings.TrimSpace(scanner.Text())
+
+	if path == "" || ownerStr == "" {
+		fmt.Println("❌ Path and owner cannot be empty")
 		return
 	}
 
+	owner, err := ffi.ParseOwnerSpec(ownerStr)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+
+	fmt.Println()
+	opts := ffi.ChownOptions{Owner: owner, Recursive: recursive, NoDereference: noDereference}
+	result := ffi.ChangeOwners(menuContext, backend, path, opts)
+	if !result.Success {
+		displayOperationProgress(12, fmt.Sprintf("Failed to change owner: %s", result.Message), false)
+		return
+	}
+	displayOperationProgress(12, result.Message, true)
+	fmt.Println()
+}
+
+// handleBulkRename renames the entries of a folder or glob by a rule, after showing
+// the old and new names
+func handleBulkRename(scanner *bufio.Scanner) {
+	fmt.Println()
+	displayStyledPrompt(13, "")
+	if !scanner.Scan() {
+		return
+	}
+	path, opts, err := takeMatchOptions(scanner.Text())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if path == "" {
+		fmt.Println("❌ Path cannot be empty")
+		return
+	}
+
+	var rule service.RenameRule
+	fmt.Print("Find (regular expression, empty to replace whole names): ")
+	if !scanner.Scan() {
+		return
+	}
+	rule.Find = strings.TrimSpace(scanner.Text())
+	fmt.Print("Replace with ($1 for groups; {n}, {n:03}, {date}, {date:YYYYMMDD}, {name}, {ext}): ")
+	if !scanner.Scan() {
+		return
+	}
+	rule.Replace = strings.TrimSpace(scanner.Text())
+	fmt.Print("Convert (lower, upper, title and/or slug; empty to keep): ")
+	if !scanner.Scan() {
+		return
+	}
+	for _, word := range strings.Fields(strings.ToLower(scanner.Text())) {
+		if word == "slug" {
+			rule.Slugify = true
+		} else {
+			rule.Case = word
+		}
+	}
+	if strings.Contains(rule.Replace, "{n") {
+		fmt.Print("Start numbering at (empty for 1): ")
+		if !scanner.Scan() {
+			return
+		}
+		if text := strings.TrimSpace(scanner.Text()); text != "" {
+			if rule.Start, err = strconv.Atoi(text); err != nil {
+				fmt.Printf("❌ Invalid number %q\n", text)
+				return
+			}
+		}
+	}
+
+	matches, err := fileService.GlobPaths(menuContext, []string{path}, opts)
+	if err != nil {
+		displayOperationProgress(13, fmt.Sprintf("Failed to match %s: %v", path, err), false)
+		return
+	}
+	plan, err := fileService.PlanRenames(matches, rule)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if len(plan.Renames) == 0 {
+		fmt.Printf("ℹ️  None of the %d matching entries would change its name\n", len(matches))
+		return
+	}
+
+	printRenames(plan)
+	if plan.Conflicts > 0 {
+		displayOperationProgress(13, fmt.Sprintf("%d of %d renames have conflicts; nothing was renamed", plan.Conflicts, len(plan.Renames)), false)
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf("⚠️  Rename %d entries? (yes/no): ", len(plan.Renames))
+	if !scanner.Scan() {
+		return
+	}
+	confirmation := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	if confirmation != "yes" && confirmation != "y" {
+		fmt.Println("❌ Cancelled")
+		return
+	}
+
+	fmt.Println()
+	ctx, stop := interruptContext()
+	result := fileService.ApplyRenames(ctx, plan)
+	stop()
+	displayOperationProgress(13, result.Message, result.Success)
+	fmt.Println()
+}
+
+// printRenames lists the old and new names of a rename plan with their conflicts
+func printRenames(plan service.RenamePlan) {
+	width := 0
+	for _, rename := range plan.Renames {
+		width = max(width, len(rename.Old))
+	}
+	width = min(width, 60)
+
+	fmt.Printf("🔎 %d renames", len(plan.Renames))
+	if plan.Unchanged > 0 {
+		fmt.Printf(", %d entries keep their name", plan.Unchanged)
+	}
+	fmt.Println(":")
+	for _, rename := range plan.Renames {
+		mark := "✅"
+		if rename.Conflict != "" {
+			mark = "❌"
+		}
+		fmt.Printf("   %s %-*s → %s", mark, width, rename.Old, filepath.Base(rename.New))
+		if rename.Conflict != "" {
+			fmt.Printf("  (%s)", rename.Conflict)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+// maxListedHistory limits the entries of the history that are printed
+const maxListedHistory = 15
+
+// printHistory lists the most recent entries of the history and reports whether
+// it could be read
+func printHistory() bool {
+	entries, err := history.History()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return false
+	}
+	if len(entries) == 0 {
+		fmt.Println("ℹ️  The history is empty")
+		return true
+	}
+
+	fmt.Printf("🕘 %d recorded operations, most recent first:\n", len(entries))
+	for i, entry := range entries {
+		if i == maxListedHistory {
+			fmt.Printf("   … and %d more\n", len(entries)-i)
+			break
+		}
+		state := "  "
+		if entry.Undone {
+			state = "↩️"
+		}
+		fmt.Printf("   %s #%-4d %s  %s\n", state, entry.ID, entry.Time.Format("2006-01-02 15:04"), entry.Title)
+		for _, note := range entry.Notes {
+			fmt.Printf("            ⚠️  %s\n", note)
+		}
+	}
+	fmt.Println("   (↩️  undone; redo makes them again, earliest first)")
+	return true
+}
+
+// runHistoryCommand runs "filemanager undo", "redo" or "history" and reports whether it succeeded
+func runHistoryCommand(command string) bool {
+	if history == nil {
+		fmt.Fprintln(os.Stderr, "❌ A dry run does not record a history")
+		return false
+	}
+
+	var result ffi.Result
+	switch command {
+	case "history":
+		return printHistory()
+	case "undo":
+		result = history.Undo(menuContext)
+	default:
+		result = history.Redo(menuContext)
+	}
+	if !result.Success {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", result.Message)
+		return false
+	}
+	fmt.Printf("✅ %s\n", result.Message)
+	return true
+}
+
+// handleHistory lists the history and undoes its latest entry or redoes the
+// earliest undone one
+func handleHistory(scanner *bufio.Scanner) {
+	fmt.Println()
+	if history == nil {
+		fmt.Println("❌ A dry run does not record a history")
+		return
+	}
+	if !printHistory() {
+		return
+	}
+
+	fmt.Println()
+	displayStyledPrompt(14, "")
+	if !scanner.Scan() {
+		return
+	}
+
+	var result ffi.Result
+	switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+	case "":
+		return
+	case "u", "undo":
+		result = history.Undo(menuContext)
+	case "r", "redo":
+		result = history.Redo(menuContext)
+	default:
+		fmt.Println("❌ Invalid choice")
+		return
+	}
+	fmt.Println()
+	displayOperationProgress(14, result.Message, result.Success)
+	fmt.Println()
+}
+
+// printListing lists the entries of a folder, folders first
+func printListing(path string, hidden bool) {
+	listing, err := ffi.ListDir(menuContext, backend, path, ffi.ListOptions{DirsFirst: true, ShowHidden: hidden})
+	if err != nil {
+		fmt.Printf("❌ Failed to list '%s': %v\n", path, err)
+		return
+	}
+
+	fmt.Printf("📁 %s/ (%d entries)\n", path, listing.Total)
+	for _, entry := range listing.Entries {
+		mode := ffi.ModeString(entry.Mode)
+		switch entry.Type {
+		case ffi.EntryDir:
+			fmt.Printf("  📁 %s %-10s %s/\n", mode, "", entry.Name)
+		case ffi.EntrySymlink:
+			fmt.Printf("  🔗 %s %-10s %s -> %s\n", mode, "", entry.Name, entry.LinkTarget)
+		default:
+			fmt.Printf("  📄 %s %10s %s\n", mode, formatBytes(uint64(entry.Size)), entry.Name)
+		}
+	}
+}
+
+// maxListedModeChanges limits the mode changes printed before a summary line
+const maxListedModeChanges = 40
+
+// printModeChanges lists the old and new mode of every entry of a ChangeModes result
+func printModeChanges(result ffi.Result) {
+	unchanged := 0
+	listed := 0
+	for _, change := range result.ModeChanges {
+		if change.Old == change.New {
+			unchanged++
+			continue
+		}
+		if listed == maxListedModeChanges {
+			continue
+		}
+		listed++
+		kind := "📄"
+		if change.IsDir {
+			kind = "📁"
+		}
+		fmt.Printf("   %s %04o %s → %04o %s  %s\n", kind, change.Old, ffi.ModeString(change.Old),
+			change.New, ffi.ModeString(change.New), change.Path)
+	}
+	if changed := len(result.ModeChanges) - unchanged; changed > listed {
+		fmt.Printf("   … and %d more\n", changed-listed)
+	}
+	if unchanged > 0 {
+		fmt.Printf("   %d unchanged\n", unchanged)
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Printf("   ⏭️  %s (%s)\n", skipped.Path, skipped.Reason)
+	}
+}
+
+// displayPermissionInfo shows detailed explanation of the permission mode
+func displayPermissionInfo(mode uint32) {
+	fmt.Println("📋 Permission Breakdown:")
+	fmt.Println("────────────────────────────────────────")
+
+	modeStr := fmt.Sprintf("%03o", mode&0777)
 	owner := string(modeStr[0])
 	group := string(modeStr[1])
 	others := string(modeStr[2])
 
+	fmt.Printf("   Mode:   %04o (%s)\n", mode, ffi.ModeString(mode))
 	fmt.Printf("   Owner:  %s (%s)\n", owner, decodePermission(owner))
 	fmt.Printf("   Group:  %s (%s)\n", group, decodePermission(group))
 	fmt.Printf("   Others: %s (%s)\n", others, decodePermission(others))
+	if mode&04000 != 0 {
+		fmt.Println("   Setuid: runs with the permissions of the owner")
+	}
+	if mode&02000 != 0 {
+		fmt.Println("   Setgid: runs with the group, new entries of a folder inherit its group")
+	}
+	if mode&01000 != 0 {
+		fmt.Println("   Sticky: only owners may delete or rename entries of the folder")
+	}
 	fmt.Println()
 
 	// Show common permission patterns
@@ -614,7 +1683,11 @@ func handleMove(scanner *bufio.Scanner) {
 	if !scanner.Scan() {
 		return
 	}
-	src := strings.TrimSpace(scanner.Text())
+	src, match, err := takeMatchOptions(scanner.Text())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
 
 	fmt.Println()
 	displayInputBox("Enter destination path")
@@ -633,12 +1706,32 @@ func handleMove(scanner *bufio.Scanner) {
 		return
 	}
 
+	policy, ok := promptConflictPolicy(scanner, dst)
+	if !ok {
+		return
+	}
+
+	if isBulk(src, match) {
+		question := fmt.Sprintf("Move these %%d entries into %s?", dst)
+		runBulk(scanner, 6, src, match, question, func(ctx context.Context, matches []service.Match) []ffi.Result {
+			return fileService.TransferMatches(ctx, matches, dst, true, ffi.CopyOptions{Conflict: policy})
+		})
+		return
+	}
+
 	fmt.Println()
-	result := ffi.MovePath(src, dst)
+	ctx, stop := interruptContext()
+	result := backend.MovePath(ctx, src, dst, ffi.CopyOptions{Conflict: policy}, newProgressBar(6))
+	stop()
+	finishProgressBar()
+	printConflicts(result)
 	if !result.Success {
 		displayOperationProgress(6, fmt.Sprintf("Failed to move %s: %s", src, result.Message), false)
 	} else {
 		displayOperationProgress(6, fmt.Sprintf("Moved %s to %s", src, dst), true)
+		if result.MovedByCopy {
+			fmt.Println("ℹ️  Destination is on another filesystem: copied, verified and removed the original")
+		}
 	}
 	fmt.Println()
 }
@@ -649,23 +1742,63 @@ func handleCopy(scanner *bufio.Scanner) {
 	if !scanner.Scan() {
 		return
 	}
-	src := strings.TrimSpace(scanner.Text())
+	// -a / --archive preserves permissions, times, ownership and xattrs like cp -a,
+	// --verify and --verify-blake3 compare SHA-256 or BLAKE3 checksums afterwards
+	src, archive := takeFlag(scanner.Text(), "-a", "--archive")
+	src, sha := takeFlag(src, "--verify")
+	src, blake := takeFlag(src, "--verify-blake3")
+	src, match, err := takeMatchOptions(src)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
 
 	fmt.Println()
 	displayInputBox("Enter destination path")
 	if !scanner.Scan() {
 		return
 	}
-	dst := strings.TrimSpace(scanner.Text())
+	dst, archiveDst := takeFlag(scanner.Text(), "-a", "--archive")
+	dst, shaDst := takeFlag(dst, "--verify")
+	dst, blakeDst := takeFlag(dst, "--verify-blake3")
+	archive = archive || archiveDst
+
+	verify := ffi.ChecksumNone
+	switch {
+	case blake || blakeDst:
+		verify = ffi.ChecksumBLAKE3
+	case sha || shaDst:
+		verify = ffi.ChecksumSHA256
+	}
 
 	if src == "" || dst == "" {
 		fmt.Println("❌ Paths cannot be empty")
 		return
 	}
 
+	policy, ok := promptConflictPolicy(scanner, dst)
+	if !ok {
+		return
+	}
+
+	options := ffi.CopyOptions{Conflict: policy, PreserveMetadata: archive, Verify: verify}
+	if isBulk(src, match) {
+		runBulk(scanner, 7, src, match, "", func(ctx context.Context, matches []service.Match) []ffi.Result {
+			return fileService.TransferMatches(ctx, matches, dst, false, options)
+		})
+		return
+	}
+
 	fmt.Println()
-	result := ffi.CopyPath(src, dst)
-	if result.Success {
+	ctx, stop := interruptContext()
+	result := backend.CopyPath(ctx, src, dst, options, newProgressBar(7))
+	stop()
+	finishProgressBar()
+	printConflicts(result)
+	printMismatches(result)
+	if result.Success && result.Strategy != ffi.CopyStrategyNone {
+		displayOperationProgress(7, fmt.Sprintf("Copied %s to %s (%s)", src, dst, result.Strategy), true)
+	} else if result.Success {
 		displayOperationProgress(7, fmt.Sprintf("Copied %s to %s", src, dst), true)
 	} else {
 		displayOperationProgress(7, fmt.Sprintf("Failed to copy %s: %s", src, result.Message), false)
@@ -801,7 +1934,7 @@ func handleTemplateStructure(scanner *bufio.Scanner, template service.StructureT
 
 		fmt.Printf("\n🔨 Creating %s structure...\n", template.Name)
 
-		successCount, errorCount := service.CreateFromTemplate(input, template)
+		successCount, errorCount, _ := fileService.CreateFromTemplate(menuContext, input, template, true)
 
 		fmt.Printf("\n📊 Summary: %d succeeded, %d failed\n", successCount, errorCount)
 
@@ -866,7 +1999,7 @@ func handleCustomStructure(scanner *bufio.Scanner) bool {
 		if strings.HasPrefix(line, "d:") {
 			path := strings.TrimPrefix(line, "d:")
 			path = strings.TrimSpace(path)
-			result = ffi.CreateFolder(path)
+			result = backend.CreateFolder(menuContext, path, ffi.CreateOptions{Parents: true})
 			if result.Success {
 				successCount++
 				fmt.Printf("  ✅ 📁 %s\n", path)
@@ -877,7 +2010,7 @@ func handleCustomStructure(scanner *bufio.Scanner) bool {
 		} else if strings.HasPrefix(line, "f:") {
 			path := strings.TrimPrefix(line, "f:")
 			path = strings.TrimSpace(path)
-			result = ffi.CreateFile(path)
+			result = backend.CreateFile(menuContext, path, ffi.CreateOptions{})
 			if result.Success {
 				successCount++
 				fmt.Printf("  ✅ 📄 %s\n", path)
@@ -951,9 +2084,6 @@ func handleParseTreeStructure(scanner *bufio.Scanner) bool {
 		return false
 	}
 
-	successCount := 0
-	errorCount := 0
-
 	// Sort directories by depth to create parent dirs first
 	sort.Slice(dirs, func(i, j int) bool {
 		depthI := strings.Count(dirs[i], string(filepath.Separator))
@@ -964,30 +2094,39 @@ func handleParseTreeStructure(scanner *bufio.Scanner) bool {
 		return dirs[i] < dirs[j]
 	})
 
-	// Create directories
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	// Create everything or nothing, so a failure does not leave a half-built tree
+	batch := fileService.NewBatch()
+	batch.SetTransactional(true)
 	for _, dir := range dirs {
-		result := ffi.CreateFolder(dir)
-		if result.Success {
-			successCount++
-			fmt.Printf("  ✅ 📁 %s\n", dir)
-		} else {
-			errorCount++
-			fmt.Printf("  ❌ %s: %s\n", dir, result.Message)
-		}
+		batch.AddCreateFolder(dir)
+	}
+	for _, filePath := range filePaths {
+		batch.AddCreateFile(filePath)
 	}
 
-	// Create files
-	for filePath := range files {
-		result := ffi.CreateFile(filePath)
+	paths := append(dirs, filePaths...)
+	for i, result := range batch.Execute() {
+		icon := "📁"
+		if i >= len(dirs) {
+			icon = "📄"
+		}
 		if result.Success {
-			successCount++
-			fmt.Printf("  ✅ 📄 %s\n", filePath)
+			fmt.Printf("  ✅ %s %s\n", icon, paths[i])
 		} else {
-			errorCount++
-			fmt.Printf("  ❌ %s: %s\n", filePath, result.Message)
+			fmt.Printf("  ❌ %s: %s\n", paths[i], result.Message)
 		}
 	}
+	if batch.RolledBack() {
+		fmt.Println("  ↩️  Creation failed, all created items were removed")
+	}
 
+	successCount, errorCount := batch.GetSummary()
 	fmt.Printf("\n📊 Summary: %d succeeded, %d failed\n", successCount, errorCount)
 	return true
 }
@@ -1008,7 +2147,7 @@ func handleInteractiveBuilder(scanner *bufio.Scanner) bool {
 		return false
 	}
 
-	result := ffi.CreateFolder(rootDir)
+	result := backend.CreateFolder(menuContext, rootDir, ffi.CreateOptions{Parents: true})
 	if !result.Success {
 		fmt.Printf("❌ Failed to create root: %s\n", result.Message)
 		return false
@@ -1029,7 +2168,7 @@ func handleInteractiveBuilder(scanner *bufio.Scanner) bool {
 		fmt.Println("  touch <name>  - Create file")
 		fmt.Println("  cd <name>     - Enter subdirectory")
 		fmt.Println("  back          - Go to parent directory")
-		fmt.Println("  list          - List subdirectories")
+		fmt.Println("  list [-a]     - List entries (-a: include hidden)")
 		fmt.Println("  done          - Finish building")
 		fmt.Println()
 
@@ -1054,7 +2193,7 @@ func handleInteractiveBuilder(scanner *bufio.Scanner) bool {
 			}
 			dirName := strings.Join(parts[1:], " ")
 			newPath := filepath.Join(currentPath, dirName)
-			result := ffi.CreateFolder(newPath)
+			result := backend.CreateFolder(menuContext, newPath, ffi.CreateOptions{Parents: true})
 			if result.Success {
 				successCount++
 				fmt.Printf("✅ 📁 %s\n", newPath)
@@ -1070,7 +2209,7 @@ func handleInteractiveBuilder(scanner *bufio.Scanner) bool {
 			}
 			fileName := strings.Join(parts[1:], " ")
 			newPath := filepath.Join(currentPath, fileName)
-			result := ffi.CreateFile(newPath)
+			result := backend.CreateFile(menuContext, newPath, ffi.CreateOptions{})
 			if result.Success {
 				successCount++
 				fmt.Printf("✅ 📄 %s\n", newPath)
@@ -1109,8 +2248,8 @@ func handleInteractiveBuilder(scanner *bufio.Scanner) bool {
 			}
 
 		case "list":
-			fmt.Println("📁 Current directory structure:")
-			fmt.Printf("  %s/\n", currentPath)
+			_, hidden := takeFlag(strings.Join(parts[1:], " "), "-a", "--all")
+			printListing(currentPath, hidden)
 
 		case "done":
 			fmt.Printf("\n📊 Summary: %d succeeded, %d failed\n", successCount, errorCount)
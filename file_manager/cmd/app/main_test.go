@@ -0,0 +1,76 @@
+package main
+
+import (
+	"filemanager/internal/ffi"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunTouch verifies touch accepts any file name and reads the standard input when
+// it is redirected or --stdin is given
+func TestRunTouch(t *testing.T) {
+	memory := ffi.NewMemoryBackend()
+	backend = memory
+	t.Cleanup(func() { backend = nil })
+	root := t.TempDir()
+
+	// Input that is not redirected is not read, so a reader that never ends is left alone
+	stdin, writer := io.Pipe()
+	defer writer.Close()
+	names := []string{"Makefile", "Dockerfile", ".env.local", "app.config.json", "src/main.go"}
+	var paths []string
+	for _, name := range names {
+		paths = append(paths, filepath.Join(root, name))
+	}
+	done := make(chan bool)
+	go func() { done <- runTouch(append([]string{"--mode=600"}, paths...), stdin) }()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("Expected every file to be created")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("touch read the standard input without --stdin")
+	}
+	for _, path := range paths {
+		if info, err := memory.Lstat(path); err != nil || info.Size() != 0 {
+			t.Errorf("%s: expected an empty file, got %v", path, err)
+		}
+	}
+
+	config := filepath.Join(root, "config.yaml")
+	if !runTouch([]string{"--stdin", config}, strings.NewReader("port: 1\n")) {
+		t.Fatal("Expected the file to be created from the standard input")
+	}
+	if info, err := memory.Lstat(config); err != nil || info.Size() != int64(len("port: 1\n")) {
+		t.Errorf("Expected the standard input to be written, got %v", err)
+	}
+
+	// Redirected input is read without --stdin, a character device is not
+	template := filepath.Join(t.TempDir(), "template")
+	if err := os.WriteFile(template, []byte("port: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for path, input := range map[string]string{filepath.Join(root, "from-file"): template, filepath.Join(root, "from-null"): os.DevNull} {
+		file, err := os.Open(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok := runTouch([]string{path}, file)
+		file.Close()
+		if !ok {
+			t.Fatalf("Expected %s to be created", path)
+		}
+	}
+	if data, err := memory.ReadFile(filepath.Join(root, "from-file")); err != nil || string(data) != "port: 2\n" {
+		t.Errorf("Expected the redirected file to be written, got %q (%v)", data, err)
+	}
+
+	if runTouch([]string{"--bogus", config}, strings.NewReader("")) || runTouch([]string{""}, strings.NewReader("")) {
+		t.Error("Expected an unknown option and an empty path to fail")
+	}
+}
@@ -4,9 +4,12 @@ go 1.24
 
 require (
 	github.com/gorilla/mux v1.8.1 // HTTP router for web server
+	lukechampine.com/blake3 v1.4.1 // BLAKE3 checksums for verified copies
 )
 
 // Development dependencies
 require (
-	golang.org/x/sys v0.15.0 // System calls
-)
\ No newline at end of file
+	golang.org/x/sys v0.30.0 // System calls
+)
+
+require github.com/klauspost/cpuid/v2 v2.2.10 // indirect
@@ -0,0 +1,15 @@
+package ffi
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the last access time described by info
+func accessTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+	}
+	return info.ModTime()
+}
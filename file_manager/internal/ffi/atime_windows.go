@@ -0,0 +1,15 @@
+package ffi
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the last access time described by info
+func accessTime(info os.FileInfo) time.Time {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, data.LastAccessTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,124 @@
+package ffi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes content to path so that readers and crashes see either
+// the previous file or the complete new one, never a truncated file
+//
+// The content goes to a temporary file next to path, which is synced and renamed
+// over path before the folder is synced. An exclusive write links the temporary
+// file instead, so a file created by someone else in the meantime is never
+// replaced. A replaced file keeps its permissions and, for root, its owner unless
+// opts.Mode is set; a path that is a symbolic link has what it points to replaced.
+func WriteFileAtomic(path string, content []byte, opts WriteOptions) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	existing, err := os.Lstat(path)
+	switch {
+	case err == nil && opts.Exclusive:
+		return &fs.PathError{Op: "write", Path: path, Err: fs.ErrExist}
+	case err == nil && existing.IsDir():
+		return &fs.PathError{Op: "write", Path: path, Err: errors.New("is a directory")}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return err
+	case err != nil:
+		existing = nil
+	}
+
+	// The temporary file starts with the permissions path ends up with, so the
+	// content is never readable by more users than it will be
+	perm := fs.FileMode(0644)
+	switch {
+	case opts.Mode != 0:
+		perm = fs.FileMode(opts.Mode) & fs.ModePerm
+	case existing != nil:
+		perm = existing.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	temp, err := createTemp(dir, filepath.Base(path), perm)
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	defer os.Remove(tempPath)
+
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = applyWriteMode(tempPath, existing, opts)
+	}
+	if err != nil {
+		// Report the error against path rather than the temporary file
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return &fs.PathError{Op: "write", Path: path, Err: err}
+	}
+
+	if opts.Exclusive {
+		err = linkNew(tempPath, path)
+	} else {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// createTemp creates an empty file with a random name starting with .name in dir
+// Unlike os.CreateTemp it asks for perm rather than 0600, so new files honour the umask.
+func createTemp(dir, name string, perm fs.FileMode) (*os.File, error) {
+	for range 100 {
+		path := filepath.Join(dir, fmt.Sprintf(".%s.tmp-%08x", name, rand.Uint32()))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if !errors.Is(err, fs.ErrExist) {
+			return file, err
+		}
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: filepath.Join(dir, name), Err: fs.ErrExist}
+}
+
+// applyWriteMode gives the temporary file written for a file the permissions the
+// file ends up with: opts.Mode, or those of the file it replaces
+func applyWriteMode(tempPath string, existing fs.FileInfo, opts WriteOptions) error {
+	if opts.Mode != 0 {
+		return changeMode(tempPath, opts.Mode)
+	}
+	if existing == nil {
+		return nil
+	}
+	if err := copyMode(existing, tempPath); err != nil {
+		return err
+	}
+	return copyOwnership(existing, tempPath)
+}
+
+// linkNew gives the file at tempPath the name path unless path exists
+// Filesystems without hard links fall back to checking path before renaming,
+// which leaves a short window for another writer.
+func linkNew(tempPath, path string) error {
+	err := os.Link(tempPath, path)
+	if err == nil || errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if _, statErr := os.Lstat(path); statErr == nil {
+		return &fs.PathError{Op: "write", Path: path, Err: fs.ErrExist}
+	}
+	return os.Rename(tempPath, path)
+}
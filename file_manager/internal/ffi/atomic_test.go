@@ -0,0 +1,102 @@
+package ffi
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// readAll returns the content of a file of backend
+func readAll(t *testing.T, backend Backend, path string) string {
+	t.Helper()
+	file, err := backend.Open(path)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return string(data)
+}
+
+// TestWriteFileOptions verifies every backend replaces files, keeps their mode and honours opts
+func TestWriteFileOptions(t *testing.T) {
+	ctx := context.Background()
+
+	for _, backend := range availableBackends(t) {
+		t.Run(backend.Name(), func(t *testing.T) {
+			root := t.TempDir()
+			file := filepath.Join(root, "config.yaml")
+			backend.CreateFolder(ctx, root, CreateOptions{Parents: true})
+
+			if result := backend.WriteFile(ctx, filepath.Join(root, "missing", "x"), nil, WriteOptions{}); result.Kind != ErrorKindNotFound {
+				t.Errorf("WriteFile without parent: expected %v, got %v", ErrorKindNotFound, result.Kind)
+			}
+			if result := backend.WriteFile(ctx, file, []byte("first"), WriteOptions{Exclusive: true}); !result.Success {
+				t.Fatalf("WriteFile: %s", result.Message)
+			}
+			result := backend.WriteFile(ctx, file, []byte("second"), WriteOptions{Exclusive: true})
+			if result.Kind != ErrorKindAlreadyExists || readAll(t, backend, file) != "first" {
+				t.Errorf("Exclusive WriteFile onto existing: expected %v and the old content, got %v", ErrorKindAlreadyExists, result.Kind)
+			}
+			if result := backend.WriteFile(ctx, root, nil, WriteOptions{}); result.Success {
+				t.Error("WriteFile onto a folder succeeded")
+			}
+
+			if runtime.GOOS == "windows" {
+				return
+			}
+			if result := backend.ChangePermissions(ctx, file, 0600); !result.Success {
+				t.Fatalf("ChangePermissions: %s", result.Message)
+			}
+			if result := backend.WriteFile(ctx, file, []byte("second"), WriteOptions{}); !result.Success {
+				t.Fatalf("WriteFile: %s", result.Message)
+			}
+			if info, _ := backend.Lstat(file); info.Mode().Perm() != 0600 || readAll(t, backend, file) != "second" {
+				t.Errorf("Replaced file: expected mode 0600 and the new content, got %v", info.Mode().Perm())
+			}
+			if result := backend.WriteFile(ctx, file, nil, WriteOptions{Mode: 0640}); !result.Success {
+				t.Fatalf("WriteFile: %s", result.Message)
+			}
+			if info, _ := backend.Lstat(file); info.Mode().Perm() != 0640 {
+				t.Errorf("WriteFile with a mode: expected 0640, got %v", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+// TestWriteFileAtomic verifies links are written through and no temporary file is left behind
+func TestWriteFileAtomic(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "target.txt")
+	link := filepath.Join(root, "link.txt")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Cannot create symbolic links: %v", err)
+	}
+
+	if err := WriteFileAtomic(link, []byte("new"), WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("Expected the link target to be written, got %q", data)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected the link to stay a link, got %v (%v)", info, err)
+	}
+
+	if err := WriteFileAtomic(link, []byte("newer"), WriteOptions{Exclusive: true}); !os.IsExist(err) {
+		t.Errorf("Expected an exclusive write through a link to fail, got %v", err)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 2 {
+		t.Errorf("Expected no temporary files to be left, got %d entries", len(entries))
+	}
+}
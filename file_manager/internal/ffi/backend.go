@@ -0,0 +1,92 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Backend performs file operations on a filesystem
+//
+// Operations that only touch a single entry do nothing once ctx is done;
+// operations that walk a tree stop between files. progress may be nil.
+type Backend interface {
+	// Name returns the name accepted by NewBackend
+	Name() string
+
+	// CreateFolder creates a folder per opts; an existing folder is kept unless opts is exclusive
+	CreateFolder(ctx context.Context, path string, opts CreateOptions) Result
+	// CreateFile creates an empty file per opts, truncating an existing one unless opts
+	// is exclusive
+	CreateFile(ctx context.Context, path string, opts CreateOptions) Result
+	// WriteFile replaces the content of a file atomically, creating it per opts; the
+	// parent folder must exist
+	WriteFile(ctx context.Context, path string, content []byte, opts WriteOptions) Result
+	// RenamePath renames a file or folder
+	RenamePath(ctx context.Context, oldPath, newPath string) Result
+	// DeletePath deletes a file or folder recursively, shredding files per opts and
+	// reporting each removed file
+	DeletePath(ctx context.Context, path string, opts DeleteOptions, progress ProgressFunc) Result
+	// Remove deletes a single file or empty folder
+	Remove(ctx context.Context, path string) Result
+	// ChangePermissions sets the permission bits of a file or folder, e.g. 0755
+	ChangePermissions(ctx context.Context, path string, mode uint32) Result
+	// ChangeOwner sets the owner and group of a file or folder; -1 leaves either unchanged
+	// noFollow changes a symbolic link itself instead of what it points to.
+	ChangeOwner(ctx context.Context, path string, uid, gid int, noFollow bool) Result
+	// MovePath moves a file or folder, resolving an existing destination per opts
+	MovePath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result
+	// CopyPath copies a file or folder, resolving existing destination entries per opts
+	CopyPath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result
+
+	// Lstat describes an entry without following symlinks
+	// Errors wrap fs.ErrNotExist and the other fs errors like those of os.Lstat.
+	Lstat(path string) (fs.FileInfo, error)
+	// ReadDir lists the entries of a folder sorted by name
+	ReadDir(path string) ([]fs.DirEntry, error)
+	// Readlink returns the target of a symbolic link
+	Readlink(path string) (string, error)
+	// Open opens a file for reading
+	Open(path string) (io.ReadCloser, error)
+}
+
+// Names of the available backends
+const (
+	// BackendRust calls the Rust core through cgo; it is not available on Windows
+	BackendRust = "rust"
+	// BackendNative is implemented in pure Go
+	BackendNative = "native"
+	// BackendMemory keeps a filesystem in memory that starts empty and is lost on exit
+	BackendMemory = "memory"
+)
+
+// BackendEnv is the environment variable that selects the backend
+const BackendEnv = "FILEMANAGER_BACKEND"
+
+// NewBackend returns the backend with the given name
+// An empty name selects the platform default: rust, or native on Windows and
+// in builds without cgo. Every call for BackendMemory returns a new, empty filesystem.
+func NewBackend(name string) (Backend, error) {
+	if name == "" {
+		name = defaultBackendName
+	}
+
+	switch strings.ToLower(name) {
+	case BackendRust:
+		return NewRustBackend()
+	case BackendNative:
+		return NewNativeBackend(), nil
+	case BackendMemory:
+		return NewMemoryBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q (available: %s, %s, %s)", name, BackendRust, BackendNative, BackendMemory)
+	}
+}
+
+// BackendFromEnv returns the backend named by BackendEnv, or the platform default
+func BackendFromEnv() (Backend, error) {
+	return NewBackend(os.Getenv(BackendEnv))
+}
@@ -0,0 +1,14 @@
+//go:build windows || !cgo
+// +build windows !cgo
+
+package ffi
+
+import "errors"
+
+// defaultBackendName is the backend used when none is selected
+const defaultBackendName = BackendNative
+
+// NewRustBackend fails on Windows and in builds without cgo, where the Rust core is not linked in
+func NewRustBackend() (Backend, error) {
+	return nil, errors.New("the rust backend is not available on Windows or without cgo")
+}
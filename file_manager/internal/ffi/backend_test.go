@@ -0,0 +1,82 @@
+package ffi
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// availableBackends returns every backend that can run on this platform
+func availableBackends(t *testing.T) []Backend {
+	t.Helper()
+
+	var backends []Backend
+	for _, name := range []string{BackendRust, BackendNative, BackendMemory} {
+		backend, err := NewBackend(name)
+		if err != nil {
+			t.Logf("Skipping %s backend: %v", name, err)
+			continue
+		}
+		backends = append(backends, backend)
+	}
+	return backends
+}
+
+// TestBackendsAgree runs the same operations on every backend and compares the outcome
+func TestBackendsAgree(t *testing.T) {
+	ctx := context.Background()
+
+	for _, backend := range availableBackends(t) {
+		t.Run(backend.Name(), func(t *testing.T) {
+			root := t.TempDir()
+			file := filepath.Join(root, "a", "b", "file.txt")
+
+			if result := backend.CreateFile(ctx, file, CreateOptions{}); result.Kind != ErrorKindNotFound {
+				t.Errorf("CreateFile without parent: expected %v, got %v", ErrorKindNotFound, result.Kind)
+			}
+			if result := backend.CreateFolder(ctx, filepath.Dir(file), CreateOptions{Parents: true}); !result.Success {
+				t.Fatalf("CreateFolder: %s", result.Message)
+			}
+			if result := backend.CreateFile(ctx, file, CreateOptions{}); !result.Success {
+				t.Fatalf("CreateFile: %s", result.Message)
+			}
+			if result := backend.CreateFile(ctx, filepath.Join(file, "child"), CreateOptions{}); result.Success {
+				t.Error("CreateFile below a file succeeded")
+			}
+
+			copied := filepath.Join(root, "copy")
+			if result := backend.CopyPath(ctx, filepath.Join(root, "a"), copied, CopyOptions{}, nil); !result.Success {
+				t.Fatalf("CopyPath: %s", result.Message)
+			}
+			result := backend.CopyPath(ctx, filepath.Join(root, "a"), copied, CopyOptions{Conflict: ConflictFail}, nil)
+			if result.Kind != ErrorKindAlreadyExists {
+				t.Errorf("CopyPath onto existing: expected %v, got %v", ErrorKindAlreadyExists, result.Kind)
+			}
+
+			moved := filepath.Join(root, "moved.txt")
+			if result := backend.MovePath(ctx, filepath.Join(copied, "b", "file.txt"), moved, CopyOptions{}, nil); !result.Success {
+				t.Fatalf("MovePath: %s", result.Message)
+			}
+			if result := backend.RenamePath(ctx, filepath.Join(root, "missing"), moved); result.Kind != ErrorKindNotFound {
+				t.Errorf("RenamePath of missing source: expected %v, got %v", ErrorKindNotFound, result.Kind)
+			}
+
+			if runtime.GOOS != "windows" {
+				if result := backend.ChangePermissions(ctx, moved, 0600); !result.Success {
+					t.Fatalf("ChangePermissions: %s", result.Message)
+				}
+				if info, err := backend.Lstat(moved); err != nil || info.Mode().Perm() != 0600 {
+					t.Errorf("ChangePermissions: unexpected mode %v (%v)", info.Mode().Perm(), err)
+				}
+			}
+
+			if result := backend.DeletePath(ctx, filepath.Join(root, "a"), DeleteOptions{}, nil); !result.Success || result.Progress.FilesDone != 1 {
+				t.Errorf("DeletePath: %s (progress %+v)", result.Message, result.Progress)
+			}
+			if result := backend.DeletePath(ctx, filepath.Join(root, "a"), DeleteOptions{}, nil); result.Kind != ErrorKindNotFound {
+				t.Errorf("DeletePath of missing path: expected %v, got %v", ErrorKindNotFound, result.Kind)
+			}
+		})
+	}
+}
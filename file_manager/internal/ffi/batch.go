@@ -0,0 +1,404 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrorPolicy decides what a batch does after an operation fails
+type ErrorPolicy int
+
+const (
+	// ContinueOnError runs every operation whose parent succeeded
+	ContinueOnError ErrorPolicy = iota
+	// FailFast stops starting new operations after the first failure
+	FailFast
+)
+
+// batchEntry is a queued operation together with the path it creates
+// undo reverts a successful run and is nil for operations that cannot be undone
+type batchEntry struct {
+	path string
+	run  func(ctx context.Context) Result
+	undo func(ctx context.Context) Result
+}
+
+// BatchOperation represents a batch of file operations
+//
+// Operations run on a pool of workers. An operation whose path lies below
+// the path of another queued operation only starts after that operation
+// succeeded, so parents are always created before their children.
+// Results are returned in the order the operations were added.
+//
+// A transactional batch is all-or-nothing: when any operation fails, the
+// operations that already completed are undone in reverse order.
+type BatchOperation struct {
+	backend       Backend
+	operations    []batchEntry
+	results       []Result
+	parallelism   int
+	policy        ErrorPolicy
+	transactional bool
+	rolledBack    bool
+
+	// createdDirs collects the parents created by folder operations,
+	// which are removed once every operation has been undone
+	createdDirs   []string
+	createdDirsMu sync.Mutex
+}
+
+// NewBatchOperation creates a new batch operation handler running on backend
+// It runs one operation at a time and continues on errors until configured otherwise
+func NewBatchOperation(backend Backend) *BatchOperation {
+	return &BatchOperation{
+		backend:     backend,
+		operations:  make([]batchEntry, 0),
+		results:     make([]Result, 0),
+		parallelism: 1,
+		policy:      ContinueOnError,
+	}
+}
+
+// SetParallelism sets how many operations may run at the same time
+// Values below 1 are treated as 1
+func (b *BatchOperation) SetParallelism(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	b.parallelism = workers
+}
+
+// SetErrorPolicy sets how the batch reacts to failed operations
+func (b *BatchOperation) SetErrorPolicy(policy ErrorPolicy) {
+	b.policy = policy
+}
+
+// SetTransactional makes the batch all-or-nothing
+// A failure stops the batch and undoes every completed operation in reverse order
+func (b *BatchOperation) SetTransactional(enabled bool) {
+	b.transactional = enabled
+}
+
+// RolledBack reports whether the last execution was undone
+func (b *BatchOperation) RolledBack() bool {
+	return b.rolledBack
+}
+
+// Add queues an arbitrary operation that creates or modifies path
+// Operations added this way cannot be rolled back
+func (b *BatchOperation) Add(path string, run func(ctx context.Context) Result) {
+	b.AddUndoable(path, run, nil)
+}
+
+// AddUndoable queues an operation together with the step that reverts it
+func (b *BatchOperation) AddUndoable(path string, run func(ctx context.Context) Result, undo func(ctx context.Context) Result) {
+	b.operations = append(b.operations, batchEntry{path: filepath.Clean(path), run: run, undo: undo})
+}
+
+// AddCreateFolder adds a create folder operation to the batch
+// Undoing it removes the folder and any parents it had to create
+func (b *BatchOperation) AddCreateFolder(path string) {
+	var existed bool
+	b.AddUndoable(path, func(ctx context.Context) Result {
+		missing := missingDirs(b.backend, path)
+		existed = len(missing) == 0
+
+		result := b.backend.CreateFolder(ctx, path, CreateOptions{Parents: true})
+		if result.Success && !existed {
+			b.createdDirsMu.Lock()
+			b.createdDirs = append(b.createdDirs, missing[1:]...)
+			b.createdDirsMu.Unlock()
+		}
+		return result
+	}, func(ctx context.Context) Result {
+		if existed {
+			return Result{Success: true, Message: fmt.Sprintf("Kept existing folder: %s", path)}
+		}
+		return b.removeCreated(ctx, path)
+	})
+}
+
+// AddCreateFile adds a create file operation to the batch
+func (b *BatchOperation) AddCreateFile(path string) {
+	b.AddCreateFileWithContent(path, nil)
+}
+
+// AddCreateFileWithContent adds an operation that creates a file and writes content to it
+// Undoing it removes the file unless it existed before. A transactional batch keeps
+// the content of a file it replaces, so undoing puts that content back.
+func (b *BatchOperation) AddCreateFileWithContent(path string, content []byte) {
+	var existed bool
+	var previous []byte
+	b.AddUndoable(path, func(ctx context.Context) Result {
+		info, err := b.backend.Lstat(path)
+		existed = err == nil
+		if existed && b.transactional && !info.IsDir() {
+			if previous, err = b.readFile(path); err != nil {
+				return Result{
+					Success: false,
+					Message: fmt.Sprintf("Cannot keep the content of '%s' to roll back: %v", path, err),
+					Kind:    KindOf(err),
+				}
+			}
+		}
+
+		if len(content) == 0 {
+			return b.backend.CreateFile(ctx, path, CreateOptions{})
+		}
+		return b.backend.WriteFile(ctx, path, content, WriteOptions{})
+	}, func(ctx context.Context) Result {
+		if !existed {
+			return b.removeCreated(ctx, path)
+		}
+		// WriteFile keeps the mode and owner of the file it replaces
+		if result := b.backend.WriteFile(ctx, path, previous, WriteOptions{}); !result.Success {
+			return result
+		}
+		return Result{Success: true, Message: fmt.Sprintf("Restored existing file: %s", path)}
+	})
+}
+
+// readFile returns the content of the file at path, following links as a write does
+func (b *BatchOperation) readFile(path string) ([]byte, error) {
+	file, err := b.backend.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// AddMove adds a move operation to the batch
+// Undoing it moves the entry back to src
+func (b *BatchOperation) AddMove(src, dst string) {
+	b.AddUndoable(dst, func(ctx context.Context) Result {
+		return b.backend.MovePath(ctx, src, dst, CopyOptions{}, nil)
+	}, func(ctx context.Context) Result {
+		return b.backend.MovePath(ctx, dst, src, CopyOptions{}, nil)
+	})
+}
+
+// AddRename adds a rename operation to the batch
+// Undoing it restores the old name
+func (b *BatchOperation) AddRename(oldPath, newPath string) {
+	b.AddUndoable(newPath, func(ctx context.Context) Result {
+		return b.backend.RenamePath(ctx, oldPath, newPath)
+	}, func(ctx context.Context) Result {
+		return b.backend.RenamePath(ctx, newPath, oldPath)
+	})
+}
+
+// Execute runs all batched operations and returns the results
+func (b *BatchOperation) Execute() []Result {
+	return b.ExecuteContext(context.Background())
+}
+
+// ExecuteContext runs all batched operations, stopping once ctx is done,
+// and returns the results in the order the operations were added
+func (b *BatchOperation) ExecuteContext(ctx context.Context) []Result {
+	b.results = make([]Result, len(b.operations))
+	b.rolledBack = false
+	b.createdDirs = nil
+	if len(b.operations) == 0 {
+		return b.results
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	parents := b.parents()
+	children := make([][]int, len(b.operations))
+	ready := make(chan int, len(b.operations))
+	for i, parent := range parents {
+		if parent < 0 {
+			ready <- i
+		} else {
+			children[parent] = append(children[parent], i)
+		}
+	}
+
+	// completed records successful operations in the order they finished
+	var completed []int
+	var completedMu sync.Mutex
+	stopOnError := b.policy == FailFast || b.transactional
+
+	var pending sync.WaitGroup
+	pending.Add(len(b.operations))
+	go func() {
+		pending.Wait()
+		close(ready)
+	}()
+
+	// A dry run records its plan in the order operations run
+	parallelism := b.parallelism
+	if _, ok := b.backend.(*DryRun); ok {
+		parallelism = 1
+	}
+
+	var workers sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range ready {
+				b.results[i] = b.run(ctx, i, parents[i])
+				if b.results[i].Success {
+					completedMu.Lock()
+					completed = append(completed, i)
+					completedMu.Unlock()
+				} else if stopOnError {
+					cancel(fmt.Errorf("%s failed: %s", b.operations[i].path, b.results[i].Message))
+				}
+				// Children are queued only after the result is stored,
+				// so they can read it without further synchronisation
+				for _, child := range children[i] {
+					ready <- child
+				}
+				pending.Done()
+			}
+		}()
+	}
+	workers.Wait()
+
+	if b.transactional && len(completed) < len(b.operations) {
+		// The rollback runs even when ctx was cancelled, and with its values
+		b.rollback(context.WithoutCancel(ctx), completed)
+	}
+	return b.results
+}
+
+// rollback undoes the completed operations in reverse order and
+// replaces their results with the outcome of the undo step
+func (b *BatchOperation) rollback(ctx context.Context, completed []int) {
+	defer b.removeCreatedParents(ctx)
+
+	b.rolledBack = true
+
+	for n := len(completed) - 1; n >= 0; n-- {
+		i := completed[n]
+		entry := b.operations[i]
+
+		if entry.undo == nil {
+			b.results[i] = Result{
+				Success: false,
+				Message: fmt.Sprintf("Completed but cannot be rolled back: %s", entry.path),
+				Kind:    ErrorKindIO,
+			}
+			continue
+		}
+
+		if undo := entry.undo(ctx); undo.Success {
+			b.results[i] = Result{
+				Success: false,
+				Message: fmt.Sprintf("Rolled back: %s", entry.path),
+				Kind:    ErrorKindCancelled,
+			}
+		} else {
+			b.results[i] = Result{
+				Success: false,
+				Message: fmt.Sprintf("Rollback of %s failed: %s", entry.path, undo.Message),
+				Kind:    undo.Kind,
+			}
+		}
+	}
+}
+
+// removeCreatedParents removes the parents created implicitly by folder operations,
+// deepest first. Directories that still hold other entries are kept.
+func (b *BatchOperation) removeCreatedParents(ctx context.Context) {
+	sort.Slice(b.createdDirs, func(i, j int) bool {
+		return len(b.createdDirs[i]) > len(b.createdDirs[j])
+	})
+	for _, dir := range b.createdDirs {
+		b.backend.Remove(ctx, dir)
+	}
+	b.createdDirs = nil
+}
+
+// removeCreated removes a single file or empty folder created by the batch
+// An entry that is already gone counts as removed
+func (b *BatchOperation) removeCreated(ctx context.Context, path string) Result {
+	result := b.backend.Remove(ctx, path)
+	if result.Kind == ErrorKindNotFound {
+		return Result{Success: true, Message: fmt.Sprintf("Removed: %s", path)}
+	}
+	return result
+}
+
+// run executes operation i unless the batch was stopped or its parent failed
+func (b *BatchOperation) run(ctx context.Context, i, parent int) Result {
+	if ctx.Err() != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Skipped %s: %v", b.operations[i].path, context.Cause(ctx)),
+			Kind:    ErrorKindCancelled,
+		}
+	}
+	if parent >= 0 && !b.results[parent].Success {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Skipped %s: parent %s was not created", b.operations[i].path, b.operations[parent].path),
+			Kind:    ErrorKindNotFound,
+		}
+	}
+	return b.operations[i].run(ctx)
+}
+
+// parents returns, for every operation, the index of the closest queued
+// operation on an ancestor path, or -1 when it has none
+func (b *BatchOperation) parents() []int {
+	byPath := make(map[string]int, len(b.operations))
+	for i, op := range b.operations {
+		if _, exists := byPath[op.path]; !exists {
+			byPath[op.path] = i
+		}
+	}
+
+	parents := make([]int, len(b.operations))
+	for i, op := range b.operations {
+		parents[i] = -1
+		for dir := filepath.Dir(op.path); ; dir = filepath.Dir(dir) {
+			if index, ok := byPath[dir]; ok && index != i {
+				parents[i] = index
+				break
+			}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+	return parents
+}
+
+// FailureKind returns the kind of the first failure of the last run, passing over
+// operations that were rolled back or skipped because of it, or ErrorKindNone when
+// every operation succeeded
+func (b *BatchOperation) FailureKind() ErrorKind {
+	kind := ErrorKindNone
+	for _, result := range b.results {
+		if result.Success {
+			continue
+		}
+		if result.Kind != ErrorKindCancelled {
+			return result.Kind
+		}
+		kind = result.Kind
+	}
+	return kind
+}
+
+// GetSummary returns success and failure counts
+func (b *BatchOperation) GetSummary() (success int, failed int) {
+	for _, result := range b.results {
+		if result.Success {
+			success++
+		} else {
+			failed++
+		}
+	}
+	return
+}
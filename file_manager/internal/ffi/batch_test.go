@@ -0,0 +1,169 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestBatchParentsBeforeChildren verifies nested entries succeed when run in parallel
+func TestBatchParentsBeforeChildren(t *testing.T) {
+	root := t.TempDir()
+
+	batch := NewBatchOperation(testBackend(t))
+	batch.SetParallelism(8)
+
+	var paths []string
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", i))
+		// Children are queued before their parent to exercise the dependency order
+		file := filepath.Join(dir, "file.txt")
+		batch.AddCreateFile(file)
+		batch.AddCreateFolder(dir)
+		paths = append(paths, file, dir)
+	}
+
+	results := batch.Execute()
+	if len(results) != len(paths) {
+		t.Fatalf("Expected %d results, got %d", len(paths), len(results))
+	}
+	for i, result := range results {
+		if !result.Success {
+			t.Errorf("%s failed: %s", paths[i], result.Message)
+		}
+	}
+	if success, failed := batch.GetSummary(); success != len(paths) || failed != 0 {
+		t.Errorf("Unexpected summary: %d succeeded, %d failed", success, failed)
+	}
+}
+
+// TestBatchSkipsChildrenOfFailedParent verifies children are not attempted after their parent failed
+func TestBatchSkipsChildrenOfFailedParent(t *testing.T) {
+	root := t.TempDir()
+	blocker := filepath.Join(root, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := NewBatchOperation(testBackend(t))
+	batch.SetParallelism(4)
+	batch.AddCreateFolder(filepath.Join(blocker, "dir"))
+	batch.AddCreateFile(filepath.Join(blocker, "dir", "file.txt"))
+	batch.AddCreateFolder(filepath.Join(root, "ok"))
+
+	results := batch.Execute()
+	if results[0].Success || results[1].Success || !results[2].Success {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	if results[1].Kind != ErrorKindNotFound {
+		t.Errorf("Expected skipped child to report NOT_FOUND, got %s", results[1].Kind)
+	}
+}
+
+// TestBatchFailFast verifies no new operations start after the first failure
+func TestBatchFailFast(t *testing.T) {
+	batch := NewBatchOperation(testBackend(t))
+	batch.SetErrorPolicy(FailFast)
+
+	ran := 0
+	batch.Add("/a", func(ctx context.Context) Result {
+		ran++
+		return Result{Success: false, Message: "boom", Kind: ErrorKindIO}
+	})
+	for i := 0; i < 3; i++ {
+		batch.Add(fmt.Sprintf("/b%d", i), func(ctx context.Context) Result {
+			ran++
+			return Result{Success: true}
+		})
+	}
+
+	results := batch.Execute()
+	if ran != 1 {
+		t.Errorf("Expected 1 operation to run, got %d", ran)
+	}
+	for _, result := range results[1:] {
+		if result.Kind != ErrorKindCancelled {
+			t.Errorf("Expected remaining operations to be cancelled, got %+v", result)
+		}
+	}
+}
+
+// TestBatchTransactionalRollback verifies completed operations are undone when one fails
+func TestBatchTransactionalRollback(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldName := filepath.Join(root, "old.txt")
+	if err := os.WriteFile(oldName, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newName := filepath.Join(root, "new.txt")
+	// Files the batch writes over get their content back
+	written, truncated := filepath.Join(root, "written.txt"), filepath.Join(root, "truncated.txt")
+	for _, path := range []string{written, truncated} {
+		if err := os.WriteFile(path, []byte("mine"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch := NewBatchOperation(testBackend(t))
+	batch.SetTransactional(true)
+	batch.AddCreateFolder(existing)
+	batch.AddCreateFileWithContent(written, []byte("template"))
+	batch.AddCreateFile(truncated)
+	batch.AddCreateFolder(filepath.Join(root, "a", "b", "c"))
+	batch.AddCreateFileWithContent(filepath.Join(root, "a", "b", "c", "file.txt"), []byte("content"))
+	batch.AddRename(oldName, newName)
+	batch.Add(filepath.Join(root, "fails"), func(ctx context.Context) Result {
+		return Result{Success: false, Message: "boom", Kind: ErrorKindIO}
+	})
+
+	results := batch.Execute()
+	if !batch.RolledBack() {
+		t.Fatal("Expected the batch to be rolled back")
+	}
+	for i, result := range results {
+		if result.Success {
+			t.Errorf("Result %d still reports success after rollback: %+v", i, result)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "a")); !os.IsNotExist(err) {
+		t.Errorf("Created folders were not removed: %v", err)
+	}
+	if _, err := os.Stat(existing); err != nil {
+		t.Errorf("Pre-existing folder was removed: %v", err)
+	}
+	if _, err := os.Stat(oldName); err != nil {
+		t.Errorf("Rename was not reverted: %v", err)
+	}
+	if _, err := os.Stat(newName); !os.IsNotExist(err) {
+		t.Errorf("Renamed file still exists: %v", err)
+	}
+	for _, path := range []string{written, truncated} {
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != "mine" {
+			t.Errorf("%s: expected the old content back, got %q (%v)", path, data, err)
+		}
+		if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("%s: expected mode 0600 to be kept, got %v", path, info.Mode().Perm())
+		}
+	}
+}
+
+// testBackend returns the backend selected by FILEMANAGER_BACKEND,
+// so the tests can be run against every implementation
+func testBackend(t *testing.T) Backend {
+	t.Helper()
+
+	backend, err := BackendFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
@@ -0,0 +1,168 @@
+package ffi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"lukechampine.com/blake3"
+)
+
+// ChecksumAlgorithm selects how a copy is verified after it finished
+type ChecksumAlgorithm int
+
+const (
+	// ChecksumNone leaves the copy unverified
+	ChecksumNone ChecksumAlgorithm = iota
+	// ChecksumSHA256 compares SHA-256 checksums
+	ChecksumSHA256
+	// ChecksumBLAKE3 compares BLAKE3 checksums, which are considerably faster to compute
+	ChecksumBLAKE3
+)
+
+// checksumAlgorithmNames maps the names accepted by ParseChecksumAlgorithm to algorithms
+var checksumAlgorithmNames = map[string]ChecksumAlgorithm{
+	"none":   ChecksumNone,
+	"sha256": ChecksumSHA256,
+	"blake3": ChecksumBLAKE3,
+}
+
+// ParseChecksumAlgorithm parses an algorithm name such as "blake3"
+// An empty name selects ChecksumNone
+func ParseChecksumAlgorithm(name string) (ChecksumAlgorithm, error) {
+	if name == "" {
+		return ChecksumNone, nil
+	}
+	algo, ok := checksumAlgorithmNames[strings.ToLower(name)]
+	if !ok {
+		return ChecksumNone, fmt.Errorf("unknown checksum algorithm %q", name)
+	}
+	return algo, nil
+}
+
+// String returns the name accepted by ParseChecksumAlgorithm
+func (a ChecksumAlgorithm) String() string {
+	for name, algo := range checksumAlgorithmNames {
+		if algo == a {
+			return name
+		}
+	}
+	return fmt.Sprintf("ChecksumAlgorithm(%d)", int(a))
+}
+
+// newHash returns a hash computing the checksum of a
+func (a ChecksumAlgorithm) newHash() hash.Hash {
+	if a == ChecksumBLAKE3 {
+		return blake3.New(32, nil)
+	}
+	return sha256.New()
+}
+
+// Mismatch describes a copied file that differs from its source
+type Mismatch struct {
+	Source string
+	Dest   string
+	// Reason tells whether the checksums differ or a file could not be read
+	Reason string
+}
+
+// checksumFile computes the checksum of a file read through backend
+func checksumFile(backend Backend, path string, algo ChecksumAlgorithm) ([]byte, error) {
+	file, err := backend.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := algo.newHash()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// verifyCopied compares the regular files below src with their copies below dst
+// when result is a successful copy and opts.Verify is set
+// Entries a conflict skipped are left out and renamed ones are looked up under
+// their new name. Symlinks are not compared. Mismatches turn result into a
+// failure of kind ErrorKindChecksumMismatch.
+func verifyCopied(ctx context.Context, backend Backend, src, dst string, opts CopyOptions, result Result) Result {
+	algo := opts.Verify
+	if algo == ChecksumNone || !result.Success {
+		return result
+	}
+
+	conflicts := make(map[string]Conflict, len(result.Conflicts))
+	for _, conflict := range result.Conflicts {
+		conflicts[filepath.Clean(conflict.Source)] = conflict
+	}
+
+	var mismatches []Mismatch
+	var verify func(src, dst string) error
+	verify = func(src, dst string) error {
+		if conflict, ok := conflicts[src]; ok {
+			switch conflict.Action {
+			case ConflictSkipped:
+				return nil
+			case ConflictRenamed:
+				dst = filepath.Clean(conflict.Dest)
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		info, err := backend.Lstat(src)
+		if err != nil {
+			mismatches = append(mismatches, Mismatch{Source: src, Dest: dst, Reason: err.Error()})
+			return nil
+		}
+		if info.IsDir() {
+			entries, err := backend.ReadDir(src)
+			if err != nil {
+				mismatches = append(mismatches, Mismatch{Source: src, Dest: dst, Reason: err.Error()})
+				return nil
+			}
+			for _, entry := range entries {
+				if err := verify(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		srcSum, err := checksumFile(backend, src, algo)
+		if err == nil {
+			var dstSum []byte
+			if dstSum, err = checksumFile(backend, dst, algo); err == nil && !bytes.Equal(srcSum, dstSum) {
+				err = fmt.Errorf("%s checksum differs", algo)
+			}
+		}
+		if err != nil {
+			mismatches = append(mismatches, Mismatch{Source: src, Dest: dst, Reason: err.Error()})
+		}
+		return nil
+	}
+
+	if err := verify(filepath.Clean(src), filepath.Clean(dst)); err != nil {
+		return cancelledResult(ctx, result.Progress)
+	}
+
+	if len(mismatches) > 0 {
+		result.Success = false
+		result.Kind = ErrorKindChecksumMismatch
+		result.Message = fmt.Sprintf("%s, but %d file(s) failed %s verification", result.Message, len(mismatches), algo)
+		result.Mismatches = mismatches
+		return result
+	}
+	result.Message = fmt.Sprintf("%s (verified with %s)", result.Message, algo)
+	return result
+}
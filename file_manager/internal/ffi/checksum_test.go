@@ -0,0 +1,69 @@
+package ffi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestVerifiedCopy verifies copies are checked with both algorithms, following renamed
+// destinations and leaving skipped ones out
+func TestVerifiedCopy(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	dst := filepath.Join(root, "dst")
+	for _, dir := range []string{filepath.Join(src, "sub"), dst} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("aaaa"), 0644)
+	os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("bb"), 0644)
+	os.WriteFile(filepath.Join(dst, "a.txt"), []byte("old"), 0644)
+
+	backend := testBackend(t)
+	for _, policy := range []ConflictPolicy{ConflictKeepBoth, ConflictSkip} {
+		for _, algo := range []ChecksumAlgorithm{ChecksumSHA256, ChecksumBLAKE3} {
+			result := backend.CopyPath(ctx, src, dst, CopyOptions{Conflict: policy, Verify: algo}, nil)
+			if !result.Success || len(result.Mismatches) != 0 {
+				t.Errorf("%s with %s: %s %+v", policy, algo, result.Message, result.Mismatches)
+			}
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "a.txt")); string(data) != "old" {
+		t.Errorf("Skipped file was changed to %q", data)
+	}
+}
+
+// TestVerifyMismatch verifies files that differ from their source are reported
+func TestVerifyMismatch(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryBackend()
+	m.CreateFolder(ctx, "/src/sub", CreateOptions{Parents: true})
+	m.WriteFile(ctx, "/src/same.txt", []byte("same"), WriteOptions{})
+	m.WriteFile(ctx, "/src/sub/changed.txt", []byte("original"), WriteOptions{})
+	if result := m.CopyPath(ctx, "/src", "/dst", CopyOptions{}, nil); !result.Success {
+		t.Fatal(result.Message)
+	}
+	m.WriteFile(ctx, "/dst/sub/changed.txt", []byte("corrupted"), WriteOptions{})
+	m.Remove(ctx, "/dst/same.txt")
+	m.CreateFolder(ctx, "/dst/same.txt", CreateOptions{Parents: true})
+
+	copied := Result{Success: true, Message: "Successfully copied '/src' to '/dst'"}
+	result := verifyCopied(ctx, m, "/src", "/dst", CopyOptions{Verify: ChecksumBLAKE3}, copied)
+	if result.Success || result.Kind != ErrorKindChecksumMismatch {
+		t.Fatalf("Expected %v, got %v (%s)", ErrorKindChecksumMismatch, result.Kind, result.Message)
+	}
+	if len(result.Mismatches) != 2 || result.Mismatches[1].Dest != "/dst/sub/changed.txt" {
+		t.Errorf("Unexpected mismatches: %+v", result.Mismatches)
+	}
+
+	if algo, err := ParseChecksumAlgorithm("SHA256"); err != nil || algo != ChecksumSHA256 {
+		t.Errorf("ParseChecksumAlgorithm: %v, %v", algo, err)
+	}
+	if _, err := ParseChecksumAlgorithm("md5"); err == nil {
+		t.Error("Expected an error for an unknown algorithm")
+	}
+}
@@ -0,0 +1,154 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// ChmodOptions controls ChangeModes
+type ChmodOptions struct {
+	// FileMode is applied to files and DirMode to folders; a zero ModeSpec leaves
+	// entries of that kind unchanged
+	FileMode ModeSpec
+	DirMode  ModeSpec
+	// Recursive applies the modes to everything below a folder too
+	Recursive bool
+	// Preview lists the changes in Result.ModeChanges without making them
+	Preview bool
+}
+
+// ModeChange is the change of the permission bits, such as 04755, of one entry
+type ModeChange struct {
+	Path  string
+	IsDir bool
+	Old   uint32
+	New   uint32
+}
+
+// ChangeModes applies opts to path, and below it when opts.Recursive is set, through
+// backend.ChangePermissions
+// Every visited entry is listed in Result.ModeChanges, including the ones whose mode
+// stays the same. Symbolic links are not followed and are listed in Result.Skipped.
+// Entries that fail do not stop the walk; the first failure decides the error kind.
+func ChangeModes(ctx context.Context, backend Backend, path string, opts ChmodOptions) Result {
+	if opts.FileMode.IsZero() && opts.DirMode.IsZero() {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("No mode given for '%s'", path),
+			Kind:    ErrorKindInvalidPath,
+		}
+	}
+
+	c := &chmodWalk{ctx: ctx, backend: backend, opts: opts}
+	c.visit(path)
+
+	result := Result{ModeChanges: c.changes, Skipped: c.skipped}
+	switch {
+	case c.failed > 0:
+		result.Message = fmt.Sprintf("Changed permissions of %d entries under '%s', %d failed: %s",
+			c.changed, path, c.failed, c.firstFailure.Message)
+		result.Kind = c.firstFailure.Kind
+	case opts.Preview:
+		result.Success = true
+		result.Message = fmt.Sprintf("Would change permissions of %d of %d entries under '%s'", c.changed, len(c.changes), path)
+	default:
+		result.Success = true
+		result.Message = fmt.Sprintf("Changed permissions of %d of %d entries under '%s'", c.changed, len(c.changes), path)
+	}
+	return result
+}
+
+// chmodWalk holds the state of a ChangeModes walk
+type chmodWalk struct {
+	ctx     context.Context
+	backend Backend
+	opts    ChmodOptions
+
+	changes      []ModeChange
+	skipped      []SkippedFile
+	changed      int
+	failed       int
+	firstFailure Result
+}
+
+// visit changes the mode of path and, for folders of a recursive walk, its entries
+func (c *chmodWalk) visit(path string) {
+	if c.ctx.Err() != nil {
+		c.fail(cancelledResult(c.ctx, Progress{}))
+		return
+	}
+
+	info, err := c.backend.Lstat(path)
+	if err != nil {
+		c.fail(Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access path '%s': %v", path, err),
+			Kind:    KindOf(err),
+		})
+		return
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		c.skipped = append(c.skipped, SkippedFile{Path: path, Reason: "symbolic links have no permissions of their own"})
+		return
+	}
+
+	spec := c.opts.FileMode
+	if info.IsDir() {
+		spec = c.opts.DirMode
+	}
+
+	old := PermissionBits(info.Mode())
+	change := ModeChange{Path: path, IsDir: info.IsDir(), Old: old, New: old}
+	if !spec.IsZero() {
+		change.New = spec.Apply(old, info.IsDir())
+	}
+	c.changes = append(c.changes, change)
+
+	descend := info.IsDir() && c.opts.Recursive
+	var entries []fs.DirEntry
+	if descend {
+		// A folder is listed before its mode may take away the right to list it
+		entries, err = c.backend.ReadDir(path)
+	}
+
+	if change.New != change.Old {
+		if c.opts.Preview {
+			c.changed++
+		} else if result := c.backend.ChangePermissions(c.ctx, path, change.New); result.Success {
+			c.changed++
+		} else {
+			c.fail(result)
+		}
+	}
+
+	if !descend {
+		return
+	}
+	if err != nil {
+		// The new mode may have granted the right to list it
+		if entries, err = c.backend.ReadDir(path); err != nil {
+			c.fail(Result{
+				Success: false,
+				Message: fmt.Sprintf("Failed to list '%s': %v", path, err),
+				Kind:    KindOf(err),
+			})
+			return
+		}
+	}
+	for _, entry := range entries {
+		c.visit(filepath.Join(path, entry.Name()))
+		if c.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// fail records a failed entry
+func (c *chmodWalk) fail(result Result) {
+	if c.failed == 0 {
+		c.firstFailure = result
+	}
+	c.failed++
+}
@@ -0,0 +1,154 @@
+package ffi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictPolicy decides what copy and move do when the destination already exists
+// The numeric values match the conflict policies of the Rust core
+type ConflictPolicy int
+
+const (
+	// ConflictOverwrite replaces existing files and merges existing directories
+	ConflictOverwrite ConflictPolicy = iota
+	// ConflictFail fails with ErrorKindAlreadyExists
+	ConflictFail
+	// ConflictSkip leaves existing entries untouched
+	ConflictSkip
+	// ConflictKeepBoth writes to a free "name (n).ext" path next to the existing entry
+	ConflictKeepBoth
+	// ConflictOverwriteIfNewer replaces existing entries only with more recently modified ones
+	ConflictOverwriteIfNewer
+)
+
+// conflictPolicyNames maps the names accepted by ParseConflictPolicy to policies
+var conflictPolicyNames = map[string]ConflictPolicy{
+	"overwrite":          ConflictOverwrite,
+	"fail":               ConflictFail,
+	"skip":               ConflictSkip,
+	"keep-both":          ConflictKeepBoth,
+	"overwrite-if-newer": ConflictOverwriteIfNewer,
+}
+
+// ParseConflictPolicy parses a policy name such as "keep-both"
+// An empty name selects ConflictOverwrite
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	if name == "" {
+		return ConflictOverwrite, nil
+	}
+	policy, ok := conflictPolicyNames[strings.ToLower(name)]
+	if !ok {
+		return ConflictOverwrite, fmt.Errorf("unknown conflict policy %q", name)
+	}
+	return policy, nil
+}
+
+// String returns the name accepted by ParseConflictPolicy
+func (p ConflictPolicy) String() string {
+	for name, policy := range conflictPolicyNames {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+}
+
+// ConflictAction tells how a single conflict was resolved
+// The numeric values match the conflict actions of the Rust core
+type ConflictAction int
+
+const (
+	ConflictOverwritten ConflictAction = iota + 1
+	ConflictSkipped
+	ConflictRenamed
+)
+
+// String implements fmt.Stringer
+func (a ConflictAction) String() string {
+	switch a {
+	case ConflictOverwritten:
+		return "overwritten"
+	case ConflictSkipped:
+		return "skipped"
+	case ConflictRenamed:
+		return "renamed"
+	default:
+		return fmt.Sprintf("ConflictAction(%d)", int(a))
+	}
+}
+
+// Conflict describes an entry that already existed at the destination
+// Dest is the path that was written, which differs from the requested one for renames
+type Conflict struct {
+	Source string
+	Dest   string
+	Action ConflictAction
+}
+
+// lstatFunc describes an entry without following symlinks, like os.Lstat
+type lstatFunc func(path string) (fs.FileInfo, error)
+
+// resolveConflict decides where src should be written given that dst may already exist
+// srcInfo describes what will be written, a link itself or its target.
+// It returns the target path, or skip when dst must be left alone. Conflicts are
+// passed to record. With merge set, directories existing on both sides are merged,
+// except for ConflictKeepBoth which picks a new name. lstat inspects the filesystem
+// the entries live on.
+func resolveConflict(lstat lstatFunc, src string, srcInfo fs.FileInfo, dst string, policy ConflictPolicy, merge bool, record func(Conflict)) (target string, skip bool, err error) {
+	dstInfo, err := lstat(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dst, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	mergeDirs := merge && srcInfo.IsDir() && dstInfo.IsDir()
+
+	var action ConflictAction
+	switch {
+	case policy == ConflictFail:
+		return "", false, &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrExist}
+	case policy == ConflictKeepBoth:
+		target := keepBothPath(lstat, dst)
+		record(Conflict{Source: src, Dest: target, Action: ConflictRenamed})
+		return target, false, nil
+	case mergeDirs:
+		// Directories are merged, conflicts are resolved for their entries
+		return dst, false, nil
+	case policy == ConflictSkip:
+		action = ConflictSkipped
+	case policy == ConflictOverwriteIfNewer && !srcInfo.ModTime().After(dstInfo.ModTime()):
+		action = ConflictSkipped
+	default:
+		action = ConflictOverwritten
+	}
+
+	if action == ConflictOverwritten && srcInfo.IsDir() != dstInfo.IsDir() {
+		return "", false, &fs.PathError{Op: "copy", Path: dst, Err: fmt.Errorf("cannot replace: file and directory types differ: %w", fs.ErrExist)}
+	}
+
+	record(Conflict{Source: src, Dest: dst, Action: action})
+	return dst, action == ConflictSkipped, nil
+}
+
+// keepBothPath finds a free path next to path in the form "name (n).ext"
+func keepBothPath(lstat lstatFunc, path string) string {
+	dir, name := filepath.Split(path)
+
+	// A leading dot starts a hidden name rather than an extension
+	stem, ext := name, ""
+	if index := strings.LastIndex(name, "."); index > 0 {
+		stem, ext = name[:index], name[index:]
+	}
+
+	for n := 1; ; n++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+		if _, err := lstat(candidate); err != nil {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+)
+
+// cancelledResult builds the result of an operation that was stopped by its context
+func cancelledResult(ctx context.Context, progress Progress) Result {
+	return Result{
+		Success: false,
+		Message: fmt.Sprintf("Operation cancelled after %d of %d files: %v",
+			progress.FilesDone, progress.FilesTotal, context.Cause(ctx)),
+		Kind:     ErrorKindCancelled,
+		Progress: progress,
+	}
+}
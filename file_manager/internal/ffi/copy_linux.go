@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+package ffi
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyContents copies the size bytes of src into the empty file dst, reporting to tracker
+// A reflink clone is tried first, then copy_file_range and finally a buffered copy.
+// Only the data segments of sparse files are copied, so holes stay holes.
+func copyContents(src, dst *os.File, size int64, tracker *progressTracker) (CopyStrategy, error) {
+	// Any failure means the filesystems cannot share extents and the data has to be copied
+	if unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())) == nil {
+		tracker.addBytes(uint64(size))
+		return CopyStrategyReflink, nil
+	}
+
+	segments, err := dataSegments(src, size)
+	if err != nil {
+		return CopyStrategyNone, err
+	}
+
+	// copyRange reports the data bytes it actually copies, which are fewer when the
+	// source shrinks; the holes are reported here
+	strategy := CopyStrategyNone
+	var data int64
+	for _, segment := range segments {
+		used, err := copyRange(src, dst, segment[0], segment[1], strategy, tracker)
+		if err != nil {
+			return strategy, err
+		}
+		strategy = max(strategy, used)
+		data += segment[1] - segment[0]
+	}
+
+	// Trailing holes are not written, extending the file recreates them
+	if err := dst.Truncate(size); err != nil {
+		return strategy, err
+	}
+	if holes := size - data; holes > 0 {
+		tracker.addBytes(uint64(holes))
+	}
+	return strategy, nil
+}
+
+// dataSegments returns the start and end offsets of the ranges of file holding data
+// Filesystems without SEEK_DATA support report the whole file as data.
+func dataSegments(file *os.File, size int64) ([][2]int64, error) {
+	fd := int(file.Fd())
+	var segments [][2]int64
+
+	for offset := int64(0); offset < size; {
+		start, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		switch {
+		case errors.Is(err, unix.ENXIO):
+			// No data after offset, the rest of the file is a hole
+			return segments, nil
+		case errors.Is(err, unix.EINVAL), errors.Is(err, unix.EOPNOTSUPP):
+			return [][2]int64{{0, size}}, nil
+		case err != nil:
+			return nil, &os.PathError{Op: "seek", Path: file.Name(), Err: err}
+		case start >= size:
+			return segments, nil
+		}
+
+		end, err := unix.Seek(fd, start, unix.SEEK_HOLE)
+		if err != nil || end > size {
+			end = size
+		}
+		segments = append(segments, [2]int64{start, end})
+		offset = end
+	}
+	return segments, nil
+}
+
+// copyRange copies the bytes from start to end of src to the same offsets of dst
+// copy_file_range is used unless an earlier segment already had to be buffered or
+// the kernel refuses it for these files.
+func copyRange(src, dst *os.File, start, end int64, previous CopyStrategy, tracker *progressTracker) (CopyStrategy, error) {
+	offset := start
+
+	if previous != CopyStrategyBuffered {
+		for offset < end {
+			offIn, offOut := offset, offset
+			n, err := unix.CopyFileRange(int(src.Fd()), &offIn, int(dst.Fd()), &offOut, int(min(end-offset, 8<<20)), 0)
+			if err != nil || n == 0 {
+				// Unsupported, or the source shrank; the buffered copy sorts out which
+				break
+			}
+			offset += int64(n)
+			tracker.addBytes(uint64(n))
+		}
+		if offset == end {
+			return CopyStrategyCopyFileRange, nil
+		}
+	}
+
+	buffer := make([]byte, min(end-offset, 1<<20))
+	for offset < end {
+		n, err := src.ReadAt(buffer[:min(end-offset, int64(len(buffer)))], offset)
+		if n > 0 {
+			if _, err := dst.WriteAt(buffer[:n], offset); err != nil {
+				return CopyStrategyBuffered, err
+			}
+			offset += int64(n)
+			tracker.addBytes(uint64(n))
+		}
+		if err != nil {
+			// Reaching the end early means the source shrank while it was copied
+			if err == io.EOF {
+				break
+			}
+			return CopyStrategyBuffered, err
+		}
+	}
+	return CopyStrategyBuffered, nil
+}
@@ -0,0 +1,80 @@
+package ffi
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestSparseCopy verifies the disk backends keep the holes of sparse files
+// and report how the data was copied
+func TestSparseCopy(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	src := filepath.Join(root, "sparse.img")
+	file, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(64 << 20)
+	file.Truncate(size)
+	file.WriteAt([]byte("head"), 0)
+	file.WriteAt([]byte("middle"), size/2)
+	file.Close()
+	srcData, _ := os.ReadFile(src)
+	srcInfo, _ := os.Stat(src)
+
+	for _, backend := range availableBackends(t) {
+		if backend.Name() == BackendMemory {
+			continue
+		}
+		t.Run(backend.Name(), func(t *testing.T) {
+			dst := filepath.Join(root, backend.Name()+".img")
+			result := backend.CopyPath(ctx, src, dst, CopyOptions{}, nil)
+			if !result.Success || result.Strategy == CopyStrategyNone || result.Progress.BytesDone != uint64(size) {
+				t.Fatalf("CopyPath: %s (strategy %q, progress %+v)", result.Message, result.Strategy, result.Progress)
+			}
+
+			data, _ := os.ReadFile(dst)
+			if !bytes.Equal(data, srcData) {
+				t.Error("Copy differs from the source")
+			}
+			dstInfo, _ := os.Stat(dst)
+			srcBlocks := srcInfo.Sys().(*syscall.Stat_t).Blocks
+			if blocks := dstInfo.Sys().(*syscall.Stat_t).Blocks; blocks > srcBlocks+8 {
+				t.Errorf("Holes were filled: %d blocks allocated, source has %d", blocks, srcBlocks)
+			}
+		})
+	}
+}
+
+// TestCopyContentsChangedSize verifies the bytes reported never exceed the size the
+// copy started with when the source shrank or grew since it was measured
+func TestCopyContentsChangedSize(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	if err := os.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int64{2, 64 << 10} {
+		srcFile, err := os.Open(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dstFile, err := os.Create(filepath.Join(root, "dst"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracker := newProgressTracker(context.Background(), nil)
+		_, err = copyContents(srcFile, dstFile, size, tracker)
+		srcFile.Close()
+		dstFile.Close()
+		if err != nil || tracker.state.BytesDone > uint64(size) {
+			t.Errorf("Size %d: reported %d bytes (%v)", size, tracker.state.BytesDone, err)
+		}
+	}
+}
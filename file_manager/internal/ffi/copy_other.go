@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package ffi
+
+import (
+	"io"
+	"os"
+)
+
+// copyContents copies src into the empty file dst through a buffer, reporting to tracker
+func copyContents(src, dst *os.File, size int64, tracker *progressTracker) (CopyStrategy, error) {
+	n, err := io.Copy(io.MultiWriter(dst, tracker), src)
+	if n == 0 {
+		return CopyStrategyNone, err
+	}
+	return CopyStrategyBuffered, err
+}
@@ -0,0 +1,141 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// CreateOptions controls how CreateFolder and CreateFile create an entry
+// The zero value creates the entry with the default mode in an existing folder and
+// succeeds when the entry already exists, truncating an existing file.
+type CreateOptions struct {
+	// Mode sets the permission bits of the new entry, e.g. 0700; 0 gives folders
+	// 0755 and files 0644, less the umask
+	Mode uint32
+	// Exclusive fails with ErrorKindAlreadyExists when the entry exists
+	Exclusive bool
+	// Parents creates the missing parent folders, like mkdir -p
+	Parents bool
+	// Owner gives the new entry another owner or group; the zero value leaves them
+	Owner OwnerSpec
+}
+
+// missingDirs returns path and its parents that do not exist on b, deepest first
+func missingDirs(b Backend, path string) []string {
+	var missing []string
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := b.Lstat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	return missing
+}
+
+// createEntry creates the folder or file path on b per opts, with create making
+// the entry itself once its parent folder exists
+//
+// Mode and Owner only apply to an entry that did not exist. create is expected to
+// make the entry with the permission bits of Mode already; they are set again
+// afterwards to undo the umask and add the special bits. When Mode or Owner cannot
+// be applied, the entry and the parents created for it are removed again, so the
+// call either creates everything or nothing.
+func createEntry(ctx context.Context, b Backend, path string, opts CreateOptions, isDir bool, create func() Result) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+
+	kind := "file"
+	if isDir {
+		kind = "folder"
+	}
+	if info, err := b.Lstat(path); err == nil {
+		switch {
+		case opts.Exclusive:
+			return Result{
+				Success: false,
+				Message: fmt.Sprintf("Cannot create %s '%s': it already exists", kind, path),
+				Kind:    ErrorKindAlreadyExists,
+			}
+		case isDir && info.Mode()&fs.ModeSymlink != 0:
+			// A link to a folder is fine, anything else fails in create
+			return create()
+		case isDir && !info.IsDir():
+			return Result{
+				Success: false,
+				Message: fmt.Sprintf("Cannot create folder '%s': a file of that name exists", path),
+				Kind:    ErrorKindAlreadyExists,
+			}
+		case isDir:
+			return Result{Success: true, Message: fmt.Sprintf("Folder '%s' already exists", path)}
+		}
+		return create()
+	}
+
+	// missing lists the new entry and the parents created for it, deepest first
+	missing := missingDirs(b, path)
+	if len(missing) > 1 && !opts.Parents {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot create %s '%s': folder '%s' does not exist", kind, path, missing[len(missing)-1]),
+			Kind:    ErrorKindNotFound,
+		}
+	}
+	// The parents get the default mode, and create makes only the entry itself
+	if len(missing) > 1 {
+		if result := b.CreateFolder(ctx, missing[1], CreateOptions{Parents: true}); !result.Success {
+			result.Message = fmt.Sprintf("Cannot create the folder of %s '%s': %s", kind, path, result.Message)
+			return result
+		}
+	}
+
+	result := create()
+	if !result.Success || (opts.Mode == 0 && opts.Owner.IsZero()) {
+		if !result.Success {
+			removeCreated(context.WithoutCancel(ctx), b, missing[1:])
+		}
+		return result
+	}
+
+	var failed Result
+	if opts.Mode != 0 {
+		if chmod := b.ChangePermissions(ctx, path, opts.Mode); !chmod.Success {
+			failed = chmod
+		}
+	}
+	if failed.Message == "" && !opts.Owner.IsZero() {
+		if chown := b.ChangeOwner(ctx, path, opts.Owner.UID, opts.Owner.GID, true); !chown.Success {
+			failed = chown
+		}
+	}
+	if failed.Message != "" {
+		removeCreated(context.WithoutCancel(ctx), b, missing)
+		failed.Message += fmt.Sprintf("; %s '%s' was not created", kind, path)
+		return failed
+	}
+	return result
+}
+
+// CreateFileWithContent creates a file per opts on backend and writes content to it
+// The content goes through WriteFile, so an existing file is replaced atomically
+// and takes opts.Mode too.
+func CreateFileWithContent(ctx context.Context, backend Backend, path string, content []byte, opts CreateOptions) Result {
+	return createEntry(ctx, backend, path, opts, false, func() Result {
+		return backend.WriteFile(ctx, path, content, WriteOptions{Exclusive: opts.Exclusive, Mode: opts.Mode})
+	})
+}
+
+// removeCreated removes the entries of paths, deepest first, stopping at one that
+// cannot be removed
+func removeCreated(ctx context.Context, b Backend, paths []string) {
+	for _, path := range paths {
+		if !b.Remove(ctx, path).Success {
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestCreateOptions verifies every backend honours exclusive creates, missing parents and modes
+func TestCreateOptions(t *testing.T) {
+	ctx := context.Background()
+
+	for _, backend := range availableBackends(t) {
+		t.Run(backend.Name(), func(t *testing.T) {
+			root := t.TempDir()
+			backend.CreateFolder(ctx, root, CreateOptions{Parents: true})
+			dir := filepath.Join(root, "a", "b")
+			file := filepath.Join(root, "c", "file.txt")
+
+			if result := backend.CreateFolder(ctx, dir, CreateOptions{}); result.Kind != ErrorKindNotFound {
+				t.Errorf("CreateFolder without parents: expected %v, got %v", ErrorKindNotFound, result.Kind)
+			}
+			if result := backend.CreateFolder(ctx, dir, CreateOptions{Parents: true, Mode: 0700}); !result.Success {
+				t.Fatalf("CreateFolder: %s", result.Message)
+			}
+			if result := backend.CreateFolder(ctx, dir, CreateOptions{}); !result.Success {
+				t.Errorf("CreateFolder of an existing folder: %s", result.Message)
+			}
+			if result := backend.CreateFolder(ctx, dir, CreateOptions{Exclusive: true}); result.Kind != ErrorKindAlreadyExists {
+				t.Errorf("Exclusive CreateFolder of an existing folder: expected %v, got %v", ErrorKindAlreadyExists, result.Kind)
+			}
+
+			if result := backend.CreateFile(ctx, file, CreateOptions{Parents: true, Exclusive: true, Mode: 0600}); !result.Success {
+				t.Fatalf("CreateFile: %s", result.Message)
+			}
+			if result := backend.CreateFile(ctx, file, CreateOptions{Exclusive: true}); result.Kind != ErrorKindAlreadyExists {
+				t.Errorf("Exclusive CreateFile of an existing file: expected %v, got %v", ErrorKindAlreadyExists, result.Kind)
+			}
+			if result := backend.CreateFolder(ctx, file, CreateOptions{}); result.Kind != ErrorKindAlreadyExists {
+				t.Errorf("CreateFolder onto a file: expected %v, got %v", ErrorKindAlreadyExists, result.Kind)
+			}
+
+			if runtime.GOOS == "windows" {
+				return
+			}
+			for path, want := range map[string]os.FileMode{dir: 0700, filepath.Dir(dir): 0755, file: 0600} {
+				if info, err := backend.Lstat(path); err != nil || info.Mode().Perm() != want {
+					t.Errorf("%s: expected mode %v, got %v (%v)", path, want, info.Mode().Perm(), err)
+				}
+			}
+			owner, _ := ParseOwnerSpec(fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+			if result := backend.CreateFile(ctx, filepath.Join(root, "owned"), CreateOptions{Owner: owner}); !result.Success {
+				t.Errorf("CreateFile with an owner: %s", result.Message)
+			}
+		})
+	}
+}
+
+// TestCreateRestricted verifies entries and the temporary files of writes are created
+// with the requested permissions rather than tightened after they exist
+func TestCreateRestricted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Permission bits are not kept on Windows")
+	}
+	root := t.TempDir()
+	for _, test := range []struct {
+		isDir bool
+		opts  CreateOptions
+	}{
+		{false, CreateOptions{Mode: 0600}},
+		{false, CreateOptions{Mode: 0600, Exclusive: true}},
+		{true, CreateOptions{Mode: 0700}},
+	} {
+		path := filepath.Join(root, fmt.Sprintf("entry-%v-%v", test.isDir, test.opts.Exclusive))
+		if result := createOnDisk(path, test.isDir, test.opts); !result.Success {
+			t.Fatal(result.Message)
+		}
+		if info, err := os.Lstat(path); err != nil || info.Mode().Perm()&^os.FileMode(test.opts.Mode) != 0 {
+			t.Errorf("%s: expected at most mode %o, got %v (%v)", path, test.opts.Mode, info.Mode().Perm(), err)
+		}
+	}
+
+	temp, err := createTemp(root, "secret", 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp.Close()
+	if info, err := os.Lstat(temp.Name()); err != nil || info.Mode().Perm()&^0600 != 0 {
+		t.Errorf("Expected the temporary file to be private, got %v (%v)", info.Mode().Perm(), err)
+	}
+}
+
+// failingOwner is a backend that cannot change owners
+type failingOwner struct {
+	*MemoryBackend
+}
+
+func (failingOwner) ChangeOwner(ctx context.Context, path string, uid, gid int, noFollow bool) Result {
+	return Result{Success: false, Message: "operation not permitted", Kind: ErrorKindPermissionDenied}
+}
+
+// TestCreateRollback verifies an owner that cannot be set removes the file and the folders made for it
+func TestCreateRollback(t *testing.T) {
+	ctx := context.Background()
+	backend := failingOwner{NewMemoryBackend()}
+	backend.CreateFolder(ctx, "/work", CreateOptions{})
+
+	opts := CreateOptions{Parents: true, Owner: OwnerSpec{UID: 1000, GID: -1, text: "1000"}}
+	result := CreateFileWithContent(ctx, backend, "/work/new/deep/config.yaml", []byte("port: 1"), opts)
+	if result.Success || result.Kind != ErrorKindPermissionDenied {
+		t.Fatalf("Expected the owner to fail the create, got %+v", result)
+	}
+	if _, err := backend.Lstat("/work/new"); err == nil {
+		t.Error("Expected the folders made for the file to be removed")
+	}
+	if _, err := backend.Lstat("/work"); err != nil {
+		t.Errorf("Expected the existing folder to be kept: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package ffi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// diskEntries implements the Backend methods that inspect, write or remove a
+// single entry on disk with the os package; it is shared by the disk backends
+type diskEntries struct{}
+
+// WriteFile implements Backend with WriteFileAtomic
+func (diskEntries) WriteFile(ctx context.Context, path string, content []byte, opts WriteOptions) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+
+	if err := WriteFileAtomic(path, content, opts); err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to write file '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully wrote %d bytes to '%s'", len(content), path),
+	}
+}
+
+// createOnDisk creates the folder or empty file path in an existing folder per opts
+// The entry is created with the permission bits of opts.Mode, less the umask, so it
+// never exists with looser permissions than asked for. An exclusive create fails
+// when anything exists at path, even an entry made since it was checked; otherwise
+// a file is truncated and a folder, or a link to one, is kept.
+func createOnDisk(path string, isDir bool, opts CreateOptions) Result {
+	perm := fs.FileMode(0644)
+	if isDir {
+		perm = 0755
+	}
+	if opts.Mode != 0 {
+		perm = fs.FileMode(opts.Mode) & fs.ModePerm
+	}
+
+	var err error
+	if isDir {
+		err = os.Mkdir(path, perm)
+		if errors.Is(err, fs.ErrExist) && !opts.Exclusive {
+			if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+				err = nil
+			}
+		}
+	} else {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if opts.Exclusive {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+		}
+		var file *os.File
+		if file, err = os.OpenFile(path, flags, perm); err == nil {
+			err = file.Close()
+		}
+	}
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully created '%s'", path),
+	}
+}
+
+// Remove implements Backend
+func (diskEntries) Remove(ctx context.Context, path string) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+
+	if err := os.Remove(path); err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to remove '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Removed: %s", path),
+	}
+}
+
+// Lstat implements Backend
+func (diskEntries) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+// ReadDir implements Backend
+func (diskEntries) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+// Readlink implements Backend
+func (diskEntries) Readlink(path string) (string, error) {
+	return os.Readlink(path)
+}
+
+// Open implements Backend
+func (diskEntries) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
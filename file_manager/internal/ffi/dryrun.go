@@ -0,0 +1,195 @@
+package ffi
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// Kinds of PlannedAction, named after the API operations
+const (
+	ActionCreateFolder = "createFolder"
+	ActionCreateFile   = "createFile"
+	ActionWriteFile    = "writeFile"
+	ActionRename       = "rename"
+	ActionMove         = "move"
+	ActionCopyFile     = "copyFile"
+	ActionDelete       = "delete"
+	ActionShred        = "shred"
+	ActionChmod        = "chmod"
+	ActionChown        = "chown"
+)
+
+// PlannedAction is a single filesystem change recorded by a dry run
+type PlannedAction struct {
+	Op   string
+	Path string
+	// Dest is the new path of renames and moves and the target of file copies
+	Dest string
+	// Size is the number of bytes written by writes and file copies
+	Size int64
+	// Mode holds the permission bits set by chmod
+	Mode uint32
+	// Owner holds the "uid:gid" set by chown, with - for an unchanged id
+	Owner string
+}
+
+// Plan describes what a dry run would do
+type Plan struct {
+	// Actions lists the changes in the order they would happen
+	Actions []PlannedAction
+	// Existing lists the folders and files that were to be created but already exist
+	Existing []string
+	// Conflicts lists the destination entries copies and moves would overwrite, skip or rename
+	Conflicts []Conflict
+}
+
+// DryRun is a Backend that plans operations instead of running them
+//
+// Operations run on an in-memory overlay of a base backend: entries are read
+// from the base when first needed and every change stays in memory, so later
+// operations see the effects of earlier ones while nothing is written. The
+// changes are recorded in a Plan. Symlinks of the base are treated as files.
+// Batches on a DryRun run one operation at a time so the plan has a stable order.
+type DryRun struct {
+	overlay *MemoryBackend
+	name    string
+
+	mu   sync.Mutex
+	plan Plan
+}
+
+// NewDryRun returns a backend that plans operations on top of base
+func NewDryRun(base Backend) *DryRun {
+	d := &DryRun{overlay: newMemoryOverlay(base), name: base.Name()}
+	d.overlay.observe = d.record
+	return d
+}
+
+// Plan returns what the operations run so far would do
+func (d *DryRun) Plan() Plan {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return Plan{
+		Actions:   append([]PlannedAction(nil), d.plan.Actions...),
+		Existing:  append([]string(nil), d.plan.Existing...),
+		Conflicts: append([]Conflict(nil), d.plan.Conflicts...),
+	}
+}
+
+// TakePlan returns the plan like Plan and starts a new one
+// The planned changes stay in effect for later operations.
+func (d *DryRun) TakePlan() Plan {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	plan := d.plan
+	d.plan = Plan{}
+	return plan
+}
+
+func (d *DryRun) record(action PlannedAction) {
+	d.mu.Lock()
+	d.plan.Actions = append(d.plan.Actions, action)
+	d.mu.Unlock()
+}
+
+// noteExisting adds path to the existing entries of the plan if it exists
+func (d *DryRun) noteExisting(path string) {
+	if _, err := d.overlay.Lstat(path); err != nil {
+		return
+	}
+	d.mu.Lock()
+	d.plan.Existing = append(d.plan.Existing, path)
+	d.mu.Unlock()
+}
+
+// noteConflicts adds the conflicts of result to the plan
+func (d *DryRun) noteConflicts(result Result) Result {
+	d.mu.Lock()
+	d.plan.Conflicts = append(d.plan.Conflicts, result.Conflicts...)
+	d.mu.Unlock()
+	return result
+}
+
+// Name implements Backend, returning the name of the base backend
+func (d *DryRun) Name() string {
+	return d.name
+}
+
+// CreateFolder implements Backend
+func (d *DryRun) CreateFolder(ctx context.Context, path string, opts CreateOptions) Result {
+	d.noteExisting(path)
+	return d.overlay.CreateFolder(ctx, path, opts)
+}
+
+// CreateFile implements Backend
+func (d *DryRun) CreateFile(ctx context.Context, path string, opts CreateOptions) Result {
+	d.noteExisting(path)
+	return d.overlay.CreateFile(ctx, path, opts)
+}
+
+// WriteFile implements Backend
+func (d *DryRun) WriteFile(ctx context.Context, path string, content []byte, opts WriteOptions) Result {
+	d.noteExisting(path)
+	return d.overlay.WriteFile(ctx, path, content, opts)
+}
+
+// RenamePath implements Backend
+func (d *DryRun) RenamePath(ctx context.Context, oldPath, newPath string) Result {
+	return d.overlay.RenamePath(ctx, oldPath, newPath)
+}
+
+// DeletePath implements Backend
+func (d *DryRun) DeletePath(ctx context.Context, path string, opts DeleteOptions, progress ProgressFunc) Result {
+	return d.overlay.DeletePath(ctx, path, opts, progress)
+}
+
+// ChangeOwner implements Backend
+func (d *DryRun) ChangeOwner(ctx context.Context, path string, uid, gid int, noFollow bool) Result {
+	return d.overlay.ChangeOwner(ctx, path, uid, gid, noFollow)
+}
+
+// Remove implements Backend
+func (d *DryRun) Remove(ctx context.Context, path string) Result {
+	return d.overlay.Remove(ctx, path)
+}
+
+// ChangePermissions implements Backend
+func (d *DryRun) ChangePermissions(ctx context.Context, path string, mode uint32) Result {
+	return d.overlay.ChangePermissions(ctx, path, mode)
+}
+
+// MovePath implements Backend
+func (d *DryRun) MovePath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result {
+	return d.noteConflicts(d.overlay.MovePath(ctx, src, dst, opts, progress))
+}
+
+// CopyPath implements Backend
+// Nothing is written, so opts.Verify is ignored.
+func (d *DryRun) CopyPath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result {
+	opts.Verify = ChecksumNone
+	return d.noteConflicts(d.overlay.CopyPath(ctx, src, dst, opts, progress))
+}
+
+// Lstat implements Backend
+func (d *DryRun) Lstat(path string) (fs.FileInfo, error) {
+	return d.overlay.Lstat(path)
+}
+
+// ReadDir implements Backend
+func (d *DryRun) ReadDir(path string) ([]fs.DirEntry, error) {
+	return d.overlay.ReadDir(path)
+}
+
+// Readlink implements Backend
+func (d *DryRun) Readlink(path string) (string, error) {
+	return d.overlay.Readlink(path)
+}
+
+// Open implements Backend
+func (d *DryRun) Open(path string) (io.ReadCloser, error) {
+	return d.overlay.Open(path)
+}
@@ -0,0 +1,86 @@
+package ffi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestDryRunPlansCopy verifies a recursive copy is planned file by file with its
+// conflicts while the disk stays untouched
+func TestDryRunPlansCopy(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	dst := filepath.Join(root, "dst")
+	for _, dir := range []string{filepath.Join(src, "sub"), dst} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("aaaa"), 0644)
+	os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("bb"), 0644)
+	os.WriteFile(filepath.Join(dst, "a.txt"), []byte("old"), 0644)
+
+	dryRun := NewDryRun(testBackend(t))
+	result := dryRun.CopyPath(ctx, src, dst, CopyOptions{}, nil)
+	if !result.Success || result.Progress.BytesDone != 6 || result.Progress.BytesTotal != 6 {
+		t.Fatalf("CopyPath: %s (progress %+v)", result.Message, result.Progress)
+	}
+
+	plan := dryRun.Plan()
+	expected := []PlannedAction{
+		{Op: ActionCopyFile, Path: filepath.Join(src, "a.txt"), Dest: filepath.Join(dst, "a.txt"), Size: 4},
+		{Op: ActionCreateFolder, Path: filepath.Join(dst, "sub")},
+		{Op: ActionCopyFile, Path: filepath.Join(src, "sub", "b.txt"), Dest: filepath.Join(dst, "sub", "b.txt"), Size: 2},
+	}
+	if !reflect.DeepEqual(plan.Actions, expected) {
+		t.Errorf("Unexpected actions:\n%+v", plan.Actions)
+	}
+	if len(plan.Conflicts) != 1 || plan.Conflicts[0].Action != ConflictOverwritten {
+		t.Errorf("Unexpected conflicts: %+v", plan.Conflicts)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dst, "a.txt")); string(data) != "old" {
+		t.Errorf("Dry run changed the destination to %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "sub")); !os.IsNotExist(err) {
+		t.Errorf("Dry run created a folder: %v", err)
+	}
+}
+
+// TestDryRunSeesEarlierChanges verifies planned changes are visible to later operations
+func TestDryRunSeesEarlierChanges(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing")
+	if err := os.MkdirAll(filepath.Join(existing, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(existing, "nested", "file.txt"), nil, 0644)
+
+	dryRun := NewDryRun(testBackend(t))
+	dryRun.CreateFolder(ctx, existing, CreateOptions{Parents: true})
+	if result := dryRun.DeletePath(ctx, existing, DeleteOptions{}, nil); !result.Success || result.Progress.FilesDone != 1 {
+		t.Fatalf("DeletePath: %s (progress %+v)", result.Message, result.Progress)
+	}
+	if result := dryRun.CreateFile(ctx, filepath.Join(existing, "nested", "file.txt"), CreateOptions{}); result.Kind != ErrorKindNotFound {
+		t.Errorf("Creating below a planned deletion: expected %v, got %v", ErrorKindNotFound, result.Kind)
+	}
+
+	plan := dryRun.TakePlan()
+	if !reflect.DeepEqual(plan.Existing, []string{existing}) {
+		t.Errorf("Unexpected existing entries: %v", plan.Existing)
+	}
+	if len(plan.Actions) != 3 || plan.Actions[0].Op != ActionDelete || plan.Actions[0].Path != filepath.Join(existing, "nested", "file.txt") {
+		t.Errorf("Unexpected actions: %+v", plan.Actions)
+	}
+	if len(dryRun.Plan().Actions) != 0 {
+		t.Error("TakePlan did not start a new plan")
+	}
+	if _, err := os.Stat(filepath.Join(existing, "nested", "file.txt")); err != nil {
+		t.Errorf("Dry run deleted a file: %v", err)
+	}
+}
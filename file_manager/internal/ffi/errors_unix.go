@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package ffi
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// kindFromError classifies a Go error into an ErrorKind (Unix implementation)
+func kindFromError(err error) ErrorKind {
+	var errno syscall.Errno
+
+	switch {
+	case err == nil:
+		return ErrorKindNone
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorKindNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorKindPermissionDenied
+	case errors.Is(err, fs.ErrExist):
+		return ErrorKindAlreadyExists
+	case errors.As(err, &errno):
+		switch errno {
+		case syscall.ENOTEMPTY:
+			return ErrorKindNotEmpty
+		case syscall.EXDEV:
+			return ErrorKindCrossDevice
+		case syscall.EINVAL, syscall.ENAMETOOLONG, syscall.ENOTDIR, syscall.EISDIR:
+			return ErrorKindInvalidPath
+		}
+	}
+	return ErrorKindIO
+}
@@ -0,0 +1,43 @@
+//go:build windows
+// +build windows
+
+package ffi
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// Win32 error codes not exported by the syscall package
+const (
+	errorNotSameDevice syscall.Errno = 17
+	errorInvalidName   syscall.Errno = 123
+	errorBadPathname   syscall.Errno = 161
+)
+
+// kindFromError classifies a Go error into an ErrorKind (Windows implementation)
+func kindFromError(err error) ErrorKind {
+	var errno syscall.Errno
+
+	switch {
+	case err == nil:
+		return ErrorKindNone
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorKindNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorKindPermissionDenied
+	case errors.Is(err, fs.ErrExist):
+		return ErrorKindAlreadyExists
+	case errors.As(err, &errno):
+		switch errno {
+		case syscall.ERROR_DIR_NOT_EMPTY:
+			return ErrorKindNotEmpty
+		case errorNotSameDevice:
+			return ErrorKindCrossDevice
+		case errorInvalidName, errorBadPathname:
+			return ErrorKindInvalidPath
+		}
+	}
+	return ErrorKindIO
+}
@@ -0,0 +1,228 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry types reported by EntryInfo
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+	EntryOther   = "other"
+)
+
+// EntryInfo describes a file, folder or link without following it
+type EntryInfo struct {
+	Name string
+	Path string
+	// Type is EntryFile, EntryDir, EntrySymlink or EntryOther
+	Type string
+	Size int64
+	// Mode holds the permission bits, such as 04755
+	Mode uint32
+	// UID and GID are -1 and Owner and Group empty where there are no Unix owners;
+	// Owner and Group fall back to the IDs when they have no name
+	UID        int
+	GID        int
+	Owner      string
+	Group      string
+	ModTime    time.Time
+	AccessTime time.Time
+	// LinkTarget is where a symbolic link points
+	LinkTarget string
+}
+
+// Sort keys for ListOptions
+const (
+	SortByName     = "name"
+	SortBySize     = "size"
+	SortByModified = "modified"
+	SortByType     = "type"
+)
+
+// ListOptions controls ListDir
+type ListOptions struct {
+	// SortBy is SortByName, SortBySize, SortByModified or SortByType; empty sorts by name
+	SortBy     string
+	Descending bool
+	// DirsFirst lists folders before everything else, whatever the order
+	DirsFirst bool
+	// ShowHidden includes dotfiles
+	ShowHidden bool
+	// Offset skips that many entries and a positive Limit returns at most that many
+	Offset int
+	Limit  int
+}
+
+// Listing is a page of the entries of a folder
+type Listing struct {
+	Path    string
+	Entries []EntryInfo
+	// Total is the number of entries of every page together
+	Total int
+}
+
+// Stat describes path through backend without following a link
+func Stat(backend Backend, path string) (EntryInfo, error) {
+	info, err := backend.Lstat(path)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	return newEntryInfo(backend, path, info, ownerNames{}), nil
+}
+
+// ListDir returns the entries of the folder at path sorted and paged as opts says
+// Entries that vanish while the folder is read are left out.
+func ListDir(ctx context.Context, backend Backend, path string, opts ListOptions) (Listing, error) {
+	switch opts.SortBy {
+	case "", SortByName, SortBySize, SortByModified, SortByType:
+	default:
+		return Listing{}, &fs.PathError{Op: "list", Path: path, Err: fmt.Errorf("unknown sort key %q: %w", opts.SortBy, errInvalid)}
+	}
+
+	dirEntries, err := backend.ReadDir(path)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	names := ownerNames{}
+	entries := make([]EntryInfo, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if ctx.Err() != nil {
+			return Listing{}, ctx.Err()
+		}
+		if !opts.ShowHidden && strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		entryPath := filepath.Join(path, dirEntry.Name())
+		info, err := backend.Lstat(entryPath)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, newEntryInfo(backend, entryPath, info, names))
+	}
+	sortEntries(entries, opts)
+
+	listing := Listing{Path: path, Total: len(entries)}
+	start := min(max(opts.Offset, 0), len(entries))
+	end := len(entries)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, end)
+	}
+	listing.Entries = entries[start:end]
+	return listing, nil
+}
+
+// sortEntries orders entries as opts says, by name when the keys are equal
+func sortEntries(entries []EntryInfo, opts ListOptions) {
+	compare := func(a, b EntryInfo) int {
+		switch opts.SortBy {
+		case SortBySize:
+			return compareInts(a.Size, b.Size)
+		case SortByModified:
+			return a.ModTime.Compare(b.ModTime)
+		case SortByType:
+			return strings.Compare(a.Type, b.Type)
+		}
+		return 0
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if opts.DirsFirst && (a.Type == EntryDir) != (b.Type == EntryDir) {
+			return a.Type == EntryDir
+		}
+		c := compare(a, b)
+		if c == 0 {
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+		if opts.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// compareInts returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// newEntryInfo builds the EntryInfo of path from its FileInfo
+func newEntryInfo(backend Backend, path string, info fs.FileInfo, names ownerNames) EntryInfo {
+	entry := EntryInfo{
+		Name:       info.Name(),
+		Path:       path,
+		Type:       EntryOther,
+		Size:       info.Size(),
+		Mode:       PermissionBits(info.Mode()),
+		ModTime:    info.ModTime(),
+		AccessTime: accessTime(info),
+	}
+	switch {
+	case info.Mode().IsRegular():
+		entry.Type = EntryFile
+	case info.IsDir():
+		entry.Type = EntryDir
+		entry.Size = 0
+	case info.Mode()&fs.ModeSymlink != 0:
+		entry.Type = EntrySymlink
+		entry.LinkTarget, _ = backend.Readlink(path)
+	}
+
+	entry.UID, entry.GID = fileOwner(info)
+	if entry.UID >= 0 {
+		entry.Owner = names.user(entry.UID)
+		entry.Group = names.group(entry.GID)
+	}
+	return entry
+}
+
+// ownerNames caches the names of user and group IDs during a listing
+type ownerNames map[string]string
+
+// user returns the name of a uid, or the uid itself when it has none
+func (n ownerNames) user(uid int) string {
+	id := strconv.Itoa(uid)
+	return n.lookup("u"+id, func() string {
+		if u, err := user.LookupId(id); err == nil {
+			return u.Username
+		}
+		return id
+	})
+}
+
+// group returns the name of a gid, or the gid itself when it has none
+func (n ownerNames) group(gid int) string {
+	id := strconv.Itoa(gid)
+	return n.lookup("g"+id, func() string {
+		if g, err := user.LookupGroupId(id); err == nil {
+			return g.Name
+		}
+		return id
+	})
+}
+
+// lookup returns the cached name under key, resolving it on first use
+func (n ownerNames) lookup(key string, resolve func() string) string {
+	name, ok := n[key]
+	if !ok {
+		name = resolve()
+		n[key] = name
+	}
+	return name
+}
@@ -0,0 +1,104 @@
+package ffi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListDir(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range availableBackends(t) {
+		t.Run(backend.Name(), func(t *testing.T) {
+			root := t.TempDir()
+			backend.CreateFolder(ctx, filepath.Join(root, "src"), CreateOptions{Parents: true})
+			backend.WriteFile(ctx, filepath.Join(root, "b.txt"), []byte("0123456789"), WriteOptions{})
+			backend.WriteFile(ctx, filepath.Join(root, "A.txt"), []byte("abc"), WriteOptions{})
+			backend.WriteFile(ctx, filepath.Join(root, ".env"), []byte("KEY=1"), WriteOptions{})
+
+			names := func(listing Listing) []string {
+				var names []string
+				for _, entry := range listing.Entries {
+					names = append(names, entry.Name)
+				}
+				return names
+			}
+
+			listing, err := ListDir(ctx, backend, root, ListOptions{DirsFirst: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(listing); listing.Total != 3 || len(got) != 3 || got[0] != "src" || got[1] != "A.txt" || got[2] != "b.txt" {
+				t.Errorf("Unexpected listing of %d entries: %v", listing.Total, got)
+			}
+			if entry := listing.Entries[1]; entry.Type != EntryFile || entry.Size != 3 || entry.Path != filepath.Join(root, "A.txt") {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+
+			listing, err = ListDir(ctx, backend, root, ListOptions{SortBy: SortBySize, Descending: true, ShowHidden: true, Offset: 1, Limit: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(listing); listing.Total != 4 || len(got) != 2 || got[0] != ".env" || got[1] != "A.txt" {
+				t.Errorf("Unexpected page of %d entries: %v", listing.Total, got)
+			}
+
+			if _, err := ListDir(ctx, backend, root, ListOptions{SortBy: "colour"}); KindOf(err) != ErrorKindInvalidPath {
+				t.Errorf("Expected %v for an unknown sort key, got %v", ErrorKindInvalidPath, err)
+			}
+			if _, err := ListDir(ctx, backend, filepath.Join(root, "missing"), ListOptions{}); KindOf(err) != ErrorKindNotFound {
+				t.Errorf("Expected %v, got %v", ErrorKindNotFound, err)
+			}
+		})
+	}
+}
+
+func TestStatLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Creating symbolic links needs privileges on Windows")
+	}
+	root := t.TempDir()
+	target := filepath.Join(root, "notes.txt")
+	link := filepath.Join(root, "latest")
+	if err := os.WriteFile(target, []byte("notes"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	backends := map[string]Backend{}
+	for _, backend := range availableBackends(t) {
+		if backend.Name() != BackendMemory {
+			backends[backend.Name()] = backend
+		}
+	}
+	// A dry run reads links from the disk through its overlay
+	backends["dryRun"] = NewDryRun(testBackend(t))
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			entry, err := Stat(backend, link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Type != EntrySymlink || entry.LinkTarget != target {
+				t.Errorf("Unexpected link %+v", entry)
+			}
+
+			entry, err = Stat(backend, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Type != EntryFile || entry.Mode != 0640 || entry.ModTime.IsZero() {
+				t.Errorf("Unexpected file %+v", entry)
+			}
+			// The overlay of a dry run does not model owners
+			if name != "dryRun" && (entry.UID != os.Getuid() || entry.Owner == "") {
+				t.Errorf("Unexpected owner %d %q", entry.UID, entry.Owner)
+			}
+		})
+	}
+}
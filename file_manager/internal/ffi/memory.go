@@ -0,0 +1,966 @@
+package ffi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryBackend implements Backend on a filesystem held in memory
+//
+// It lets tests run without the Rust library or a temporary directory and lets
+// callers preview what operations would do. Failures carry the same error kinds
+// as the disk backends, and the owner read and write permission bits are enforced
+// the way an unprivileged user sees them. Relative paths form a tree of their own
+// next to absolute ones. Symlinks, hard links, ownership and extended attributes
+// are not modelled, so the symlink policy of CopyOptions has no effect.
+//
+// A MemoryBackend is safe for concurrent use.
+type MemoryBackend struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+
+	// source, when set, provides the entries that were not created in memory;
+	// loaded holds the paths looked up in it and listed the folders read from it
+	source Backend
+	loaded map[string]bool
+	listed map[string]bool
+
+	// observe, when set, is told about every change
+	observe func(PlannedAction)
+}
+
+// memNode is a file or folder of a MemoryBackend
+// Files loaded from a source have a size and origin but no data.
+type memNode struct {
+	mode    fs.FileMode
+	data    []byte
+	size    int64
+	modTime time.Time
+	// origin is the source path holding the contents of a loaded file
+	origin string
+	// target is where a symbolic link loaded from the source points
+	target string
+}
+
+// Errors for conditions that have no fs error, named after their errno
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+	errInvalid  = errors.New("invalid argument")
+)
+
+// NewMemoryBackend returns a backend on a new, empty in-memory filesystem
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{nodes: make(map[string]*memNode)}
+}
+
+// newMemoryOverlay returns a backend that starts out with the entries of source
+// Entries are read from source when first needed and changes stay in memory.
+func newMemoryOverlay(source Backend) *MemoryBackend {
+	m := NewMemoryBackend()
+	m.source = source
+	m.loaded = make(map[string]bool)
+	m.listed = make(map[string]bool)
+	return m
+}
+
+// Name implements Backend
+func (m *MemoryBackend) Name() string {
+	return BackendMemory
+}
+
+// CreateFolder implements Backend
+func (m *MemoryBackend) CreateFolder(ctx context.Context, path string, opts CreateOptions) Result {
+	return createEntry(ctx, m, path, opts, true, func() Result {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		perm := fs.FileMode(0755)
+		if opts.Mode != 0 {
+			perm = fs.FileMode(opts.Mode) & fs.ModePerm
+		}
+		if err := m.mkdirAll(memKey(path), perm); err != nil {
+			return memFailure(err, "Failed to create folder '%s'", path)
+		}
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Successfully created folder '%s'", path),
+		}
+	})
+}
+
+// CreateFile implements Backend
+func (m *MemoryBackend) CreateFile(ctx context.Context, path string, opts CreateOptions) Result {
+	return createEntry(ctx, m, path, opts, false, func() Result {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		// An existing file is truncated and keeps its mode
+		write := WriteOptions{Exclusive: opts.Exclusive}
+		if m.node(memKey(path)) == nil {
+			write.Mode = opts.Mode
+		}
+		if err := m.writeFile(memKey(path), nil, write); err != nil {
+			return memFailure(err, "Failed to create file '%s'", path)
+		}
+		m.emit(PlannedAction{Op: ActionCreateFile, Path: path})
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Successfully created file '%s'", path),
+		}
+	})
+}
+
+// WriteFile implements Backend
+func (m *MemoryBackend) WriteFile(ctx context.Context, path string, content []byte, opts WriteOptions) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.writeFile(memKey(path), content, opts); err != nil {
+		return memFailure(err, "Failed to write file '%s'", path)
+	}
+	m.emit(PlannedAction{Op: ActionWriteFile, Path: path, Size: int64(len(content))})
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully wrote %d bytes to '%s'", len(content), path),
+	}
+}
+
+// RenamePath implements Backend
+// Like rename(2) it replaces an existing file or empty folder of the same type
+func (m *MemoryBackend) RenamePath(ctx context.Context, oldPath, newPath string) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.rename(memKey(oldPath), memKey(newPath)); err != nil {
+		return memFailure(err, "Failed to rename '%s' to '%s'", oldPath, newPath)
+	}
+	m.emit(PlannedAction{Op: ActionRename, Path: oldPath, Dest: newPath})
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully renamed '%s' to '%s'", oldPath, newPath),
+	}
+}
+
+// DeletePath implements Backend
+func (m *MemoryBackend) DeletePath(ctx context.Context, path string, opts DeleteOptions, progress ProgressFunc) Result {
+	tracker := newProgressTracker(ctx, progress)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memKey(path)
+	if _, err := m.find(key); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return tracker.finish(Result{
+				Success: false,
+				Message: fmt.Sprintf("Path '%s' does not exist", path),
+				Kind:    ErrorKindNotFound,
+			})
+		}
+		return tracker.finish(memFailure(&fs.PathError{Op: "lstat", Path: path, Err: err}, "Failed to access path '%s'", path))
+	}
+
+	tracker.setTotals(m.scan(key))
+	if err := m.removeTree(key, opts, tracker); err != nil {
+		return tracker.finish(memFailure(err, "Failed to delete '%s'", path))
+	}
+	if opts.ShredPasses > 0 {
+		return tracker.finish(shredResult(path, opts, nil))
+	}
+	return tracker.finish(Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully deleted '%s'", path),
+	})
+}
+
+// Remove implements Backend
+func (m *MemoryBackend) Remove(ctx context.Context, path string) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.remove(memKey(path)); err != nil {
+		return memFailure(err, "Failed to remove '%s'", path)
+	}
+	m.emit(PlannedAction{Op: ActionDelete, Path: path})
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Removed: %s", path),
+	}
+}
+
+// ChangePermissions implements Backend
+func (m *MemoryBackend) ChangePermissions(ctx context.Context, path string, mode uint32) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.find(memKey(path))
+	if err != nil {
+		return memFailure(&fs.PathError{Op: "chmod", Path: path, Err: err}, "Failed to change permissions for '%s'", path)
+	}
+	node.mode = node.mode.Type() | memFileMode(mode)
+	m.emit(PlannedAction{Op: ActionChmod, Path: path, Mode: mode})
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully changed permissions for '%s' to %o", path, mode),
+	}
+}
+
+// ChangeOwner implements Backend
+// Ownership is not modelled, so only the existence of path is checked.
+func (m *MemoryBackend) ChangeOwner(ctx context.Context, path string, uid, gid int, noFollow bool) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.find(memKey(path)); err != nil {
+		return memFailure(&fs.PathError{Op: "chown", Path: path, Err: err}, "Failed to change owner of '%s'", path)
+	}
+	m.emit(PlannedAction{Op: ActionChown, Path: path, Owner: ownerString(uid, gid)})
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully changed owner of '%s' to %s", path, ownerString(uid, gid)),
+	}
+}
+
+// MovePath implements Backend
+// Like the Rust core it renames the source as a whole, so the destination folder must exist
+func (m *MemoryBackend) MovePath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result {
+	tracker := newProgressTracker(ctx, progress)
+	tracker.options = opts
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return tracker.finish(m.move(src, dst, tracker))
+}
+
+// move moves src to dst after resolving a conflict, reporting a single step to tracker
+func (m *MemoryBackend) move(src, dst string, tracker *progressTracker) Result {
+	tracker.setTotals(1, 0)
+	if err := tracker.startFile(src); err != nil {
+		return cancelledResult(tracker.ctx, tracker.state)
+	}
+
+	srcInfo, err := m.lstat(src)
+	if err != nil {
+		return memFailure(err, "Cannot move '%s'", src)
+	}
+	target, skip, err := resolveConflict(m.lstat, src, srcInfo, dst, tracker.options.Conflict, false, tracker.record)
+	if err != nil {
+		return memFailure(err, "Cannot move '%s' to '%s'", src, dst)
+	}
+	if skip {
+		tracker.finishFile()
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Skipped '%s': destination exists", src),
+		}
+	}
+
+	if err := m.rename(memKey(src), memKey(target)); err != nil {
+		return memFailure(err, "Failed to move '%s' to '%s'", src, target)
+	}
+	m.emit(PlannedAction{Op: ActionMove, Path: src, Dest: target})
+	tracker.finishFile()
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully moved '%s' to '%s'", src, target),
+	}
+}
+
+// CopyPath implements Backend
+// Permission bits are always copied; PreserveMetadata also keeps modification times
+func (m *MemoryBackend) CopyPath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result {
+	tracker := newProgressTracker(ctx, progress)
+	tracker.options = opts
+	m.mu.Lock()
+	result := tracker.finish(m.copy(src, dst, tracker))
+	m.mu.Unlock()
+
+	return verifyCopied(ctx, m, src, dst, opts, result)
+}
+
+// copy copies src to dst, reporting to tracker
+func (m *MemoryBackend) copy(src, dst string, tracker *progressTracker) Result {
+	srcKey, dstKey := memKey(src), memKey(dst)
+	node, err := m.find(srcKey)
+	if err != nil {
+		return memFailure(&fs.PathError{Op: "lstat", Path: src, Err: err}, "Failed to access source '%s'", src)
+	}
+
+	// On disk such a copy would recurse until paths become too long
+	if node.mode.IsDir() && memWithin(dstKey, srcKey) {
+		err := &fs.PathError{Op: "copy", Path: dst, Err: fmt.Errorf("destination lies inside the source: %w", errInvalid)}
+		return memFailure(err, "Cannot copy '%s' to '%s'", src, dst)
+	}
+
+	tracker.setTotals(m.scan(srcKey))
+	result := m.copyEntry(srcKey, dstKey, tracker)
+	if result.Success && len(tracker.conflicts) > 0 {
+		result.Message = fmt.Sprintf("Successfully copied '%s' to '%s' (%d conflicts resolved)", src, dst, len(tracker.conflicts))
+	}
+	return result
+}
+
+// copyEntry copies a file or folder after resolving a conflict at dst
+func (m *MemoryBackend) copyEntry(src, dst string, tracker *progressTracker) Result {
+	info, err := m.lstat(src)
+	if err != nil {
+		return memFailure(err, "Failed to access source '%s'", src)
+	}
+
+	target, skip, err := resolveConflict(m.lstat, src, info, dst, tracker.options.Conflict, true, tracker.record)
+	if err != nil {
+		return memFailure(err, "Cannot copy '%s' to '%s'", src, dst)
+	}
+	if skip {
+		tracker.skip(m.scan(src))
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Skipped '%s': destination exists", src),
+		}
+	}
+
+	if info.IsDir() {
+		return m.copyDirectory(src, target, tracker)
+	}
+	return m.copyFile(src, target, tracker)
+}
+
+// copyDirectory copies a folder recursively, merging into an existing one
+func (m *MemoryBackend) copyDirectory(src, dst string, tracker *progressTracker) Result {
+	srcNode := m.nodes[src]
+	if srcNode.mode&0400 == 0 {
+		return memFailure(&fs.PathError{Op: "open", Path: src, Err: fs.ErrPermission}, "Failed to read source directory '%s'", src)
+	}
+	if err := m.mkdirAll(dst, 0755); err != nil {
+		return memFailure(err, "Failed to create destination directory '%s'", dst)
+	}
+
+	for _, child := range m.children(src) {
+		result := m.copyEntry(child, filepath.Join(dst, filepath.Base(child)), tracker)
+		if !result.Success {
+			return result
+		}
+	}
+
+	// Folders get their metadata last, copying entries updates their times
+	if tracker.options.PreserveMetadata {
+		dstNode := m.nodes[dst]
+		dstNode.mode = srcNode.mode
+		dstNode.modTime = srcNode.modTime
+	}
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully copied directory '%s' to '%s'", src, dst),
+	}
+}
+
+// copyFile copies a single file, replacing an existing one at dst
+func (m *MemoryBackend) copyFile(src, dst string, tracker *progressTracker) Result {
+	if err := tracker.startFile(src); err != nil {
+		return cancelledResult(tracker.ctx, tracker.state)
+	}
+
+	srcNode := m.nodes[src]
+	if srcNode.mode&0400 == 0 {
+		return memFailure(&fs.PathError{Op: "open", Path: src, Err: fs.ErrPermission}, "Failed to open source file '%s'", src)
+	}
+	if err := m.mkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return memFailure(err, "Failed to create directory '%s'", filepath.Dir(dst))
+	}
+
+	// Replace an existing file instead of writing into it, which fails for read-only files
+	if m.nodes[dst] != nil {
+		if err := m.remove(dst); err != nil {
+			return memFailure(err, "Failed to replace '%s'", dst)
+		}
+	}
+	if err := m.writeFile(dst, srcNode.data, WriteOptions{}); err != nil {
+		return memFailure(err, "Failed to create destination file '%s'", dst)
+	}
+
+	dstNode := m.nodes[dst]
+	dstNode.mode = srcNode.mode
+	dstNode.size = srcNode.size
+	dstNode.origin = srcNode.origin
+	if tracker.options.PreserveMetadata {
+		dstNode.modTime = srcNode.modTime
+	}
+	m.emit(PlannedAction{Op: ActionCopyFile, Path: src, Dest: dst, Size: srcNode.size})
+	tracker.addBytes(uint64(srcNode.size))
+	tracker.finishFile()
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully copied file '%s' to '%s'", src, dst),
+	}
+}
+
+// Lstat implements Backend
+func (m *MemoryBackend) Lstat(path string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lstat(path)
+}
+
+// Readlink implements Backend
+// Only links loaded from a source exist, as links cannot be created in memory.
+func (m *MemoryBackend) Readlink(path string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.find(memKey(path))
+	if err == nil && node.mode&fs.ModeSymlink == 0 {
+		err = errInvalid
+	}
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: path, Err: err}
+	}
+	return node.target, nil
+}
+
+// ReadFile returns the contents of a file
+func (m *MemoryBackend) ReadFile(path string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.find(memKey(path))
+	switch {
+	case err != nil:
+	case node.mode.IsDir():
+		err = errIsDir
+	case node.mode&0400 == 0:
+		err = fs.ErrPermission
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	return append([]byte(nil), node.data...), nil
+}
+
+// Open implements Backend
+// Files read from a source are opened there, others are read from a copy of their contents.
+func (m *MemoryBackend) Open(path string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, err := m.find(memKey(path))
+	switch {
+	case err != nil:
+	case node.mode.IsDir():
+		err = errIsDir
+	case node.mode&0400 == 0:
+		err = fs.ErrPermission
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	if node.origin != "" {
+		return m.source.Open(node.origin)
+	}
+	return io.NopCloser(bytes.NewReader(append([]byte(nil), node.data...))), nil
+}
+
+// ReadDir implements Backend
+func (m *MemoryBackend) ReadDir(path string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memKey(path)
+	node, err := m.find(key)
+	switch {
+	case err != nil:
+	case !node.mode.IsDir():
+		err = errNotDir
+	case node.mode&0400 == 0:
+		err = fs.ErrPermission
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	children := m.children(key)
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		info, _ := m.lstat(child)
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+// Paths lists every file and folder in lexical order, without the roots
+// A backend with a source only lists the entries read from it so far.
+func (m *MemoryBackend) Paths() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	paths := make([]string, 0, len(m.nodes))
+	for key := range m.nodes {
+		if !isMemRoot(key) {
+			paths = append(paths, key)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// The methods below expect m.mu to be held
+
+// lstat describes the entry at path
+func (m *MemoryBackend) lstat(path string) (fs.FileInfo, error) {
+	key := memKey(path)
+	node, err := m.find(key)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: path, Err: err}
+	}
+	return memFileInfo{
+		name:    filepath.Base(key),
+		size:    node.size,
+		mode:    node.mode,
+		modTime: node.modTime,
+	}, nil
+}
+
+// node returns the entry stored under key, loading it from the source or
+// creating a root folder on first use
+func (m *MemoryBackend) node(key string) *memNode {
+	if node := m.nodes[key]; node != nil {
+		return node
+	}
+	if m.source != nil && !m.loaded[key] {
+		m.loaded[key] = true
+		if m.load(key) {
+			return m.nodes[key]
+		}
+	}
+	if isMemRoot(key) {
+		node := &memNode{mode: fs.ModeDir | 0755, modTime: time.Now()}
+		m.nodes[key] = node
+		return node
+	}
+	return nil
+}
+
+// load copies the entry at key from the source
+// Nothing is loaded below a parent that is missing in memory or is not a folder.
+func (m *MemoryBackend) load(key string) bool {
+	if !isMemRoot(key) {
+		if parent := m.node(filepath.Dir(key)); parent == nil || !parent.mode.IsDir() {
+			return false
+		}
+	}
+	info, err := m.source.Lstat(key)
+	if err != nil {
+		return false
+	}
+	node := &memNode{mode: info.Mode(), size: info.Size(), modTime: info.ModTime()}
+	if info.Mode().IsRegular() {
+		node.origin = key
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		node.target, _ = m.source.Readlink(key)
+	}
+	m.nodes[key] = node
+	return true
+}
+
+// loadTree loads everything below key from the source
+func (m *MemoryBackend) loadTree(key string) {
+	for _, child := range m.children(key) {
+		if m.nodes[child].mode.IsDir() {
+			m.loadTree(child)
+		}
+	}
+}
+
+// emit tells the observer about a change
+func (m *MemoryBackend) emit(action PlannedAction) {
+	if m.observe != nil {
+		m.observe(action)
+	}
+}
+
+// find returns the entry at key, failing like a path lookup on disk
+// when it or one of its parents is missing or a parent is a file
+func (m *MemoryBackend) find(key string) (*memNode, error) {
+	if err := m.checkParents(key); err != nil {
+		return nil, err
+	}
+	node := m.node(key)
+	if node == nil {
+		return nil, fs.ErrNotExist
+	}
+	return node, nil
+}
+
+// checkParents checks that every parent of key is an existing folder
+func (m *MemoryBackend) checkParents(key string) error {
+	if isMemRoot(key) {
+		return nil
+	}
+	for _, dir := range memAncestors(filepath.Dir(key)) {
+		node := m.node(dir)
+		if node == nil {
+			return fs.ErrNotExist
+		}
+		if !node.mode.IsDir() {
+			return errNotDir
+		}
+	}
+	return nil
+}
+
+// checkWritable checks that entries may be added to or removed from the parent of key
+func (m *MemoryBackend) checkWritable(key string) error {
+	if parent := m.node(filepath.Dir(key)); parent != nil && parent.mode&0200 == 0 {
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// touchParent updates the modification time of the folder holding key
+func (m *MemoryBackend) touchParent(key string) {
+	if parent := m.node(filepath.Dir(key)); parent != nil {
+		parent.modTime = time.Now()
+	}
+}
+
+// mkdirAll creates the folder key and its missing parents with perm
+func (m *MemoryBackend) mkdirAll(key string, perm fs.FileMode) error {
+	for _, dir := range memAncestors(key) {
+		if node := m.node(dir); node != nil {
+			if node.mode.IsDir() {
+				continue
+			}
+			if dir == key {
+				return &fs.PathError{Op: "mkdir", Path: key, Err: fs.ErrExist}
+			}
+			return &fs.PathError{Op: "mkdir", Path: key, Err: errNotDir}
+		}
+		if err := m.checkWritable(dir); err != nil {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: err}
+		}
+		m.nodes[dir] = &memNode{mode: fs.ModeDir | perm, modTime: time.Now()}
+		m.touchParent(dir)
+		m.emit(PlannedAction{Op: ActionCreateFolder, Path: dir})
+	}
+	return nil
+}
+
+// writeFile creates or truncates the file key and writes content to it
+func (m *MemoryBackend) writeFile(key string, content []byte, opts WriteOptions) error {
+	if err := m.checkParents(key); err != nil {
+		return &fs.PathError{Op: "open", Path: key, Err: err}
+	}
+
+	node := m.node(key)
+	switch {
+	case node == nil:
+		if err := m.checkWritable(key); err != nil {
+			return &fs.PathError{Op: "open", Path: key, Err: err}
+		}
+		node = &memNode{mode: 0644}
+		m.nodes[key] = node
+		m.touchParent(key)
+	case opts.Exclusive:
+		return &fs.PathError{Op: "open", Path: key, Err: fs.ErrExist}
+	case node.mode.IsDir():
+		return &fs.PathError{Op: "open", Path: key, Err: errIsDir}
+	case node.mode&0200 == 0:
+		return &fs.PathError{Op: "open", Path: key, Err: fs.ErrPermission}
+	}
+
+	if opts.Mode != 0 {
+		node.mode = node.mode.Type() | memFileMode(opts.Mode)
+	}
+	node.data = append([]byte(nil), content...)
+	node.size = int64(len(content))
+	node.origin = ""
+	node.modTime = time.Now()
+	return nil
+}
+
+// rename moves the entry at oldKey and everything below it to newKey
+func (m *MemoryBackend) rename(oldKey, newKey string) error {
+	fail := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldKey, New: newKey, Err: err}
+	}
+
+	src, err := m.find(oldKey)
+	if err == nil && (isMemRoot(oldKey) || isMemRoot(newKey)) {
+		err = errInvalid
+	}
+	if err == nil {
+		err = m.checkParents(newKey)
+	}
+	if err == nil {
+		err = m.checkWritable(oldKey)
+	}
+	if err == nil {
+		err = m.checkWritable(newKey)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if oldKey == newKey {
+		return nil
+	}
+	if src.mode.IsDir() && memWithin(newKey, oldKey) {
+		return fail(errInvalid)
+	}
+	if dst := m.node(newKey); dst != nil {
+		switch {
+		case src.mode.IsDir() && !dst.mode.IsDir():
+			return fail(errNotDir)
+		case !src.mode.IsDir() && dst.mode.IsDir():
+			return fail(errIsDir)
+		case dst.mode.IsDir() && len(m.children(newKey)) > 0:
+			return fail(errNotEmpty)
+		}
+		delete(m.nodes, newKey)
+	}
+
+	// The subtree is fully loaded, so the moved entries need nothing from the source
+	for _, key := range m.subtree(oldKey) {
+		moved := newKey + key[len(oldKey):]
+		m.nodes[moved] = m.nodes[key]
+		delete(m.nodes, key)
+		if m.source != nil {
+			m.loaded[moved] = true
+			m.listed[moved] = true
+		}
+	}
+	m.touchParent(oldKey)
+	m.touchParent(newKey)
+	return nil
+}
+
+// remove deletes a single file or empty folder
+func (m *MemoryBackend) remove(key string) error {
+	node, err := m.find(key)
+	if err == nil && isMemRoot(key) {
+		err = errInvalid
+	}
+	if err == nil {
+		err = m.checkWritable(key)
+	}
+	if err == nil && node.mode.IsDir() && len(m.children(key)) > 0 {
+		err = errNotEmpty
+	}
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: key, Err: err}
+	}
+
+	delete(m.nodes, key)
+	m.touchParent(key)
+	return nil
+}
+
+// removeTree removes key depth-first, reporting each removed file
+// Files are overwritten first when opts asks for shredding.
+func (m *MemoryBackend) removeTree(key string, opts DeleteOptions, tracker *progressTracker) error {
+	node := m.nodes[key]
+	if node.mode.IsDir() {
+		for _, child := range m.children(key) {
+			if err := m.removeTree(child, opts, tracker); err != nil {
+				return err
+			}
+		}
+		if err := m.remove(key); err != nil {
+			return err
+		}
+		m.emit(PlannedAction{Op: ActionDelete, Path: key})
+		return nil
+	}
+
+	if err := tracker.startFile(key); err != nil {
+		return err
+	}
+	if err := m.remove(key); err != nil {
+		return err
+	}
+	if opts.ShredPasses > 0 {
+		// Files of an overlay that were never read have no contents in memory
+		for pass := 0; pass < opts.ShredPasses; pass++ {
+			rand.Read(node.data)
+		}
+		m.emit(PlannedAction{Op: ActionShred, Path: key, Size: node.size})
+	} else {
+		m.emit(PlannedAction{Op: ActionDelete, Path: key})
+	}
+	tracker.addBytes(uint64(node.size))
+	tracker.finishFile()
+	return nil
+}
+
+// scan counts the files and bytes below key; folders are not counted as files
+func (m *MemoryBackend) scan(key string) (files uint64, bytes uint64) {
+	for _, k := range m.subtree(key) {
+		if node := m.nodes[k]; !node.mode.IsDir() {
+			files++
+			bytes += uint64(node.size)
+		}
+	}
+	return files, bytes
+}
+
+// children returns the keys of the entries directly inside key in lexical order
+func (m *MemoryBackend) children(key string) []string {
+	if m.source != nil && !m.listed[key] {
+		m.listed[key] = true
+		if entries, err := m.source.ReadDir(key); err == nil {
+			for _, entry := range entries {
+				m.node(filepath.Join(key, entry.Name()))
+			}
+		}
+	}
+
+	var keys []string
+	for k := range m.nodes {
+		if k != key && filepath.Dir(k) == key {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// subtree returns key and the keys of everything below it in lexical order
+func (m *MemoryBackend) subtree(key string) []string {
+	if m.source != nil {
+		m.loadTree(key)
+	}
+
+	var keys []string
+	for k := range m.nodes {
+		if memWithin(k, key) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// memKey returns the key a path is stored under
+func memKey(path string) string {
+	return filepath.Clean(path)
+}
+
+// isMemRoot reports whether key is "." or a filesystem root, which always exist
+func isMemRoot(key string) bool {
+	return key == "." || filepath.Dir(key) == key
+}
+
+// memAncestors returns the keys from the topmost parent of key down to key, without roots
+func memAncestors(key string) []string {
+	var keys []string
+	for ; !isMemRoot(key); key = filepath.Dir(key) {
+		keys = append(keys, key)
+	}
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return keys
+}
+
+// memWithin reports whether key is dir or lies below it
+func memWithin(key, dir string) bool {
+	switch {
+	case key == dir:
+		return true
+	case dir == ".":
+		return !filepath.IsAbs(key)
+	case strings.HasSuffix(dir, string(filepath.Separator)):
+		return strings.HasPrefix(key, dir)
+	default:
+		return strings.HasPrefix(key, dir+string(filepath.Separator))
+	}
+}
+
+// memFileMode converts Unix permission bits such as 04755 to an fs.FileMode
+func memFileMode(mode uint32) fs.FileMode {
+	fileMode := fs.FileMode(mode) & fs.ModePerm
+	if mode&04000 != 0 {
+		fileMode |= fs.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		fileMode |= fs.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		fileMode |= fs.ModeSticky
+	}
+	return fileMode
+}
+
+// memFailure builds the result of a failed operation
+// The message is formatted from format and args followed by err.
+func memFailure(err error, format string, args ...interface{}) Result {
+	return Result{
+		Success: false,
+		Message: fmt.Sprintf(format, args...) + ": " + err.Error(),
+		Kind:    memErrorKind(err),
+	}
+}
+
+// memErrorKind classifies the errors of a MemoryBackend like kindFromError does on disk
+func memErrorKind(err error) ErrorKind {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorKindNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorKindPermissionDenied
+	case errors.Is(err, fs.ErrExist):
+		return ErrorKindAlreadyExists
+	case errors.Is(err, errNotEmpty):
+		return ErrorKindNotEmpty
+	case errors.Is(err, errNotDir), errors.Is(err, errIsDir), errors.Is(err, errInvalid):
+		return ErrorKindInvalidPath
+	}
+	return ErrorKindIO
+}
+
+// memFileInfo implements fs.FileInfo for entries of a MemoryBackend
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return i.modTime }
+func (i memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memFileInfo) Sys() interface{}   { return nil }
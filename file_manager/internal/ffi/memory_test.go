@@ -0,0 +1,143 @@
+package ffi
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+// TestMemoryErrorKinds verifies the in-memory backend fails like the disk backends
+func TestMemoryErrorKinds(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryBackend()
+	m.CreateFolder(ctx, "/root/full/nested", CreateOptions{Parents: true})
+	m.CreateFolder(ctx, "/root/empty", CreateOptions{Parents: true})
+	m.WriteFile(ctx, "/root/file.txt", []byte("data"), WriteOptions{})
+
+	tests := []struct {
+		name   string
+		result Result
+		kind   ErrorKind
+	}{
+		{"folder over file", m.CreateFolder(ctx, "/root/file.txt", CreateOptions{Parents: true}), ErrorKindAlreadyExists},
+		{"folder below file", m.CreateFolder(ctx, "/root/file.txt/dir", CreateOptions{Parents: true}), ErrorKindInvalidPath},
+		{"file over folder", m.CreateFile(ctx, "/root/empty", CreateOptions{}), ErrorKindInvalidPath},
+		{"file without parent", m.CreateFile(ctx, "/root/missing/file.txt", CreateOptions{}), ErrorKindNotFound},
+		{"rename onto non-empty folder", m.RenamePath(ctx, "/root/empty", "/root/full"), ErrorKindNotEmpty},
+		{"rename folder onto file", m.RenamePath(ctx, "/root/empty", "/root/file.txt"), ErrorKindInvalidPath},
+		{"rename into itself", m.RenamePath(ctx, "/root/full", "/root/full/nested/full"), ErrorKindInvalidPath},
+		{"remove non-empty folder", m.Remove(ctx, "/root/full"), ErrorKindNotEmpty},
+		{"move without destination folder", m.MovePath(ctx, "/root/file.txt", "/root/missing/file.txt", CopyOptions{}, nil), ErrorKindNotFound},
+		{"copy into itself", m.CopyPath(ctx, "/root/full", "/root/full/nested/copy", CopyOptions{}, nil), ErrorKindInvalidPath},
+		{"chmod missing", m.ChangePermissions(ctx, "/root/missing", 0644), ErrorKindNotFound},
+		{"delete missing", m.DeletePath(ctx, "/root/missing", DeleteOptions{}, nil), ErrorKindNotFound},
+	}
+
+	for _, test := range tests {
+		if test.result.Success || test.result.Kind != test.kind {
+			t.Errorf("%s: expected %v, got %v (%s)", test.name, test.kind, test.result.Kind, test.result.Message)
+		}
+	}
+
+	// Renaming onto an empty folder replaces it like rename(2)
+	if result := m.RenamePath(ctx, "/root/full/nested", "/root/empty"); !result.Success {
+		t.Errorf("Rename onto empty folder: %s", result.Message)
+	}
+}
+
+// TestMemoryPermissions verifies the owner permission bits are enforced
+func TestMemoryPermissions(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryBackend()
+	m.CreateFolder(ctx, "locked", CreateOptions{Parents: true})
+	m.WriteFile(ctx, "locked/file.txt", []byte("data"), WriteOptions{})
+	m.ChangePermissions(ctx, "locked", 0555)
+
+	if result := m.CreateFile(ctx, "locked/new.txt", CreateOptions{}); result.Kind != ErrorKindPermissionDenied {
+		t.Errorf("Create in read-only folder: expected %v, got %v", ErrorKindPermissionDenied, result.Kind)
+	}
+	if result := m.DeletePath(ctx, "locked", DeleteOptions{}, nil); result.Kind != ErrorKindPermissionDenied {
+		t.Errorf("Delete from read-only folder: expected %v, got %v", ErrorKindPermissionDenied, result.Kind)
+	}
+
+	m.ChangePermissions(ctx, "locked/file.txt", 0200)
+	if result := m.CopyPath(ctx, "locked/file.txt", "copy.txt", CopyOptions{}, nil); result.Kind != ErrorKindPermissionDenied {
+		t.Errorf("Copy of unreadable file: expected %v, got %v", ErrorKindPermissionDenied, result.Kind)
+	}
+	if _, err := m.ReadFile("locked/file.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("ReadFile of unreadable file: expected permission error, got %v", err)
+	}
+}
+
+// TestMemoryCopyAndMove verifies copies merge folders, resolve conflicts and
+// report progress, and that moves carry whole trees
+func TestMemoryCopyAndMove(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryBackend()
+	m.CreateFolder(ctx, "src/sub", CreateOptions{Parents: true})
+	m.WriteFile(ctx, "src/a.txt", []byte("new"), WriteOptions{})
+	m.WriteFile(ctx, "src/sub/b.txt", []byte("bb"), WriteOptions{})
+	m.ChangePermissions(ctx, "src/sub/b.txt", 0600)
+	m.CreateFolder(ctx, "dst", CreateOptions{Parents: true})
+	m.WriteFile(ctx, "dst/a.txt", []byte("old"), WriteOptions{})
+
+	result := m.CopyPath(ctx, "src", "dst", CopyOptions{Conflict: ConflictKeepBoth}, nil)
+	if !result.Success {
+		t.Fatalf("CopyPath: %s", result.Message)
+	}
+	if result.Progress.FilesDone != 2 || result.Progress.BytesDone != 5 {
+		t.Errorf("Unexpected progress: %+v", result.Progress)
+	}
+
+	// The existing folder was renamed rather than merged
+	expected := []string{"dst", "dst (1)", "dst (1)/a.txt", "dst (1)/sub", "dst (1)/sub/b.txt", "dst/a.txt", "src", "src/a.txt", "src/sub", "src/sub/b.txt"}
+	if paths := m.Paths(); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Unexpected tree after copy:\n%v", paths)
+	}
+	if info, err := m.Lstat("dst (1)/sub/b.txt"); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Copied file has mode %v (%v)", info.Mode().Perm(), err)
+	}
+
+	result = m.CopyPath(ctx, "src", "dst", CopyOptions{Conflict: ConflictSkip}, nil)
+	if !result.Success || len(result.Conflicts) != 1 || result.Conflicts[0].Action != ConflictSkipped {
+		t.Errorf("Copy with skip: %s (%+v)", result.Message, result.Conflicts)
+	}
+	if data, _ := m.ReadFile("dst/a.txt"); string(data) != "old" {
+		t.Errorf("Skipped file was changed to %q", data)
+	}
+
+	if result := m.MovePath(ctx, "src", "dst/src", CopyOptions{}, nil); !result.Success {
+		t.Fatalf("MovePath: %s", result.Message)
+	}
+	if data, err := m.ReadFile("dst/src/sub/b.txt"); err != nil || string(data) != "bb" {
+		t.Errorf("Moved file reads %q (%v)", data, err)
+	}
+	if _, err := m.Lstat("src"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Source still exists after move: %v", err)
+	}
+}
+
+// TestMemoryDeleteCancelled verifies deletion stops between files once cancelled
+func TestMemoryDeleteCancelled(t *testing.T) {
+	m := NewMemoryBackend()
+	m.CreateFolder(context.Background(), "tree", CreateOptions{Parents: true})
+	for _, name := range []string{"tree/1.txt", "tree/2.txt", "tree/3.txt"} {
+		m.WriteFile(context.Background(), name, []byte("x"), WriteOptions{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := m.DeletePath(ctx, "tree", DeleteOptions{}, func(progress Progress) {
+		if progress.FilesDone == 1 {
+			cancel()
+		}
+	})
+
+	if result.Kind != ErrorKindCancelled {
+		t.Fatalf("Expected %v, got %v (%s)", ErrorKindCancelled, result.Kind, result.Message)
+	}
+	if paths := m.Paths(); len(paths) != 3 {
+		t.Errorf("Expected the folder and two files to remain, got %v", paths)
+	}
+}
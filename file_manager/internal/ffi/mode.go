@@ -0,0 +1,239 @@
+package ffi
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// ModeSpec is a chmod mode: an octal mode of up to four digits such as 755 or 4755,
+// or comma-separated symbolic clauses such as u+x,go-w or a=rX
+//
+// A clause names the classes it changes (u, g, o or a, all of them when left out,
+// regardless of the umask) followed by one or more operations: + adds, - removes
+// and = sets the permissions r, w, x, X (execute for folders and files that are
+// executable for anyone), s (setuid and setgid) and t (sticky), or copies those of
+// another class as in g=u. The zero value is not a mode.
+type ModeSpec struct {
+	text    string
+	octal   bool
+	mode    uint32
+	clauses []modeClause
+}
+
+// modeClause is a symbolic clause; who masks the permission bits of its classes
+type modeClause struct {
+	who     uint32
+	actions []modeAction
+}
+
+// modeAction is a single operation of a clause
+// copy names the class whose permissions are copied, or is 0 when perms are given.
+type modeAction struct {
+	op    byte
+	perms string
+	copy  byte
+}
+
+// Permission bits of every class, including their special bit
+var classBits = map[byte]uint32{
+	'u': 04700,
+	'g': 02070,
+	'o': 01007,
+	'a': 07777,
+}
+
+// ParseModeSpec parses a chmod mode such as "0644", "u+x,go-w" or "a=rX"
+func ParseModeSpec(text string) (ModeSpec, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ModeSpec{}, fmt.Errorf("empty mode")
+	}
+
+	if text[0] >= '0' && text[0] <= '9' {
+		mode, err := strconv.ParseUint(text, 8, 32)
+		if err != nil || len(text) > 4 {
+			return ModeSpec{}, fmt.Errorf("invalid octal mode %q (expected up to 4 digits 0-7 such as 755 or 4755)", text)
+		}
+		return ModeSpec{text: text, octal: true, mode: uint32(mode)}, nil
+	}
+
+	spec := ModeSpec{text: text}
+	for _, part := range strings.Split(text, ",") {
+		clause, err := parseModeClause(part)
+		if err != nil {
+			return ModeSpec{}, fmt.Errorf("invalid mode %q: %w", text, err)
+		}
+		spec.clauses = append(spec.clauses, clause)
+	}
+	return spec, nil
+}
+
+// parseModeClause parses a clause such as "go-w" or "u=rwx+s"
+func parseModeClause(text string) (modeClause, error) {
+	var clause modeClause
+	i := 0
+	for ; i < len(text) && strings.IndexByte("ugoa", text[i]) >= 0; i++ {
+		clause.who |= classBits[text[i]]
+	}
+	if clause.who == 0 {
+		clause.who = classBits['a']
+	}
+
+	for i < len(text) {
+		op := text[i]
+		if op != '+' && op != '-' && op != '=' {
+			return modeClause{}, fmt.Errorf("expected +, - or = at %q", text[i:])
+		}
+		i++
+
+		action := modeAction{op: op}
+		start := i
+		if i < len(text) && strings.IndexByte("ugo", text[i]) >= 0 {
+			action.copy = text[i]
+			i++
+		} else {
+			for ; i < len(text) && strings.IndexByte("rwxXst", text[i]) >= 0; i++ {
+			}
+			action.perms = text[start:i]
+		}
+		clause.actions = append(clause.actions, action)
+	}
+
+	if len(clause.actions) == 0 {
+		return modeClause{}, fmt.Errorf("clause %q has no operation", text)
+	}
+	return clause, nil
+}
+
+// IsZero reports whether s is the zero ModeSpec, which does not describe a mode
+func (s ModeSpec) IsZero() bool {
+	return s.text == ""
+}
+
+// String returns the mode as it was parsed
+func (s ModeSpec) String() string {
+	return s.text
+}
+
+// Apply returns the permission bits, such as 0755, that the mode gives an entry
+// whose bits are mode
+func (s ModeSpec) Apply(mode uint32, isDir bool) uint32 {
+	if s.octal {
+		return s.mode
+	}
+
+	mode &= 07777
+	for _, clause := range s.clauses {
+		for _, action := range clause.actions {
+			bits := action.bits(mode, isDir) & clause.who
+			switch action.op {
+			case '+':
+				mode |= bits
+			case '-':
+				mode &^= bits
+			case '=':
+				mode = mode&^clause.who | bits
+			}
+		}
+	}
+	return mode
+}
+
+// bits returns the permission bits an action adds, removes or sets for every class
+func (a modeAction) bits(mode uint32, isDir bool) uint32 {
+	if a.copy != 0 {
+		var class uint32
+		switch a.copy {
+		case 'u':
+			class = mode >> 6 & 7
+		case 'g':
+			class = mode >> 3 & 7
+		case 'o':
+			class = mode & 7
+		}
+		return class * 0111
+	}
+
+	var bits uint32
+	for i := 0; i < len(a.perms); i++ {
+		switch a.perms[i] {
+		case 'r':
+			bits |= 0444
+		case 'w':
+			bits |= 0222
+		case 'x':
+			bits |= 0111
+		case 'X':
+			if isDir || mode&0111 != 0 {
+				bits |= 0111
+			}
+		case 's':
+			bits |= 06000
+		case 't':
+			bits |= 01000
+		}
+	}
+	return bits
+}
+
+// ParseNewMode parses the mode of an entry being created, such as "600" or "u+x"
+// Symbolic modes change 0644 for files and 0755 for folders. A mode without any
+// bits is refused, as 0 leaves the default mode in WriteOptions.
+func ParseNewMode(text string, isDir bool) (uint32, error) {
+	spec, err := ParseModeSpec(text)
+	if err != nil {
+		return 0, err
+	}
+	base := uint32(0644)
+	if isDir {
+		base = 0755
+	}
+	mode := spec.Apply(base, isDir)
+	if mode == 0 {
+		return 0, fmt.Errorf("mode %q leaves no permissions; use chmod after creating the entry", text)
+	}
+	return mode, nil
+}
+
+// PermissionBits returns the Unix permission bits, such as 04755, of an fs.FileMode
+func PermissionBits(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return bits
+}
+
+// ModeString formats permission bits like ls does, e.g. "rwsr-xr-t" for 05755
+func ModeString(mode uint32) string {
+	const letters = "rwxrwxrwx"
+	text := []byte("---------")
+	for i := range text {
+		if mode&(1<<uint(8-i)) != 0 {
+			text[i] = letters[i]
+		}
+	}
+
+	special := func(i int, bit uint32, set, setNoExec byte) {
+		if mode&bit == 0 {
+			return
+		}
+		if text[i] == '-' {
+			text[i] = setNoExec
+		} else {
+			text[i] = set
+		}
+	}
+	special(2, 04000, 's', 'S')
+	special(5, 02000, 's', 'S')
+	special(8, 01000, 't', 'T')
+	return string(text)
+}
@@ -0,0 +1,119 @@
+package ffi
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestModeSpec verifies octal and symbolic modes, special bits and X
+func TestModeSpec(t *testing.T) {
+	tests := []struct {
+		spec  string
+		mode  uint32
+		isDir bool
+		want  uint32
+	}{
+		{"755", 0600, false, 0755},
+		{"4755", 0644, false, 04755},
+		{"u+x,go-w", 0666, false, 0744},
+		{"a=rX", 0750, true, 0555},
+		{"a=rX", 0640, false, 0444},
+		{"a=rX", 0740, false, 0555},
+		{"+x", 0644, false, 0755},
+		{"g=u", 0740, false, 0770},
+		{"u+s,g+s", 0755, false, 06755},
+		{"+t", 0777, true, 01777},
+		{"o+s,u+t", 0755, false, 0755},
+		{"u=rw,go=", 04755, false, 0600},
+		{"go-rwx+r", 0777, false, 0744},
+	}
+	for _, test := range tests {
+		spec, err := ParseModeSpec(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		if got := spec.Apply(test.mode, test.isDir); got != test.want {
+			t.Errorf("%s on %04o: expected %04o, got %04o", test.spec, test.mode, test.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "8", "77777", "u", "u+x,", "z+x", "u+q"} {
+		if _, err := ParseModeSpec(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+
+	if got := ModeString(05754); got != "rwsr-xr-T" {
+		t.Errorf("ModeString: got %q", got)
+	}
+}
+
+// TestParseNewMode verifies symbolic modes of new entries start from the default modes
+func TestParseNewMode(t *testing.T) {
+	if mode, err := ParseNewMode("600", false); err != nil || mode != 0600 {
+		t.Errorf("600: got %04o (%v)", mode, err)
+	}
+	if mode, err := ParseNewMode("u+x", false); err != nil || mode != 0744 {
+		t.Errorf("u+x on a file: got %04o (%v)", mode, err)
+	}
+	if mode, err := ParseNewMode("o-rx", true); err != nil || mode != 0750 {
+		t.Errorf("o-rx on a folder: got %04o (%v)", mode, err)
+	}
+	if _, err := ParseNewMode("a=", false); err == nil {
+		t.Error("Expected a mode without bits to be refused")
+	}
+}
+
+// TestChangeModes verifies recursive changes with separate folder and file modes,
+// and that a preview changes nothing
+func TestChangeModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+	ctx := context.Background()
+	for _, backend := range availableBackends(t) {
+		t.Run(backend.Name(), func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "project")
+			backend.CreateFolder(ctx, filepath.Join(root, "bin"), CreateOptions{Parents: true})
+			backend.WriteFile(ctx, filepath.Join(root, "bin", "run"), []byte("#!/bin/sh"), WriteOptions{})
+			backend.WriteFile(ctx, filepath.Join(root, "README"), nil, WriteOptions{})
+			backend.ChangePermissions(ctx, filepath.Join(root, "bin", "run"), 0744)
+
+			fileMode, _ := ParseModeSpec("go=u-w")
+			dirMode, _ := ParseModeSpec("750")
+			opts := ChmodOptions{FileMode: fileMode, DirMode: dirMode, Recursive: true, Preview: true}
+
+			preview := ChangeModes(ctx, backend, root, opts)
+			if !preview.Success || len(preview.ModeChanges) != 4 {
+				t.Fatalf("Unexpected preview: %s %+v", preview.Message, preview.ModeChanges)
+			}
+			want := map[string]uint32{
+				root:                              0750,
+				filepath.Join(root, "bin"):        0750,
+				filepath.Join(root, "bin", "run"): 0755,
+				filepath.Join(root, "README"):     0644,
+			}
+			for _, change := range preview.ModeChanges {
+				if change.New != want[change.Path] {
+					t.Errorf("%s: expected %04o, got %04o", change.Path, want[change.Path], change.New)
+				}
+			}
+			if info, _ := backend.Lstat(root); PermissionBits(info.Mode()) == 0750 {
+				t.Error("Preview changed a mode")
+			}
+
+			opts.Preview = false
+			if result := ChangeModes(ctx, backend, root, opts); !result.Success {
+				t.Fatal(result.Message)
+			}
+			for path, mode := range want {
+				if info, err := backend.Lstat(path); err != nil || PermissionBits(info.Mode()) != mode {
+					t.Errorf("%s: expected %04o, got %v (%v)", path, mode, info.Mode(), err)
+				}
+			}
+		})
+	}
+}
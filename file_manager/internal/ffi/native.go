@@ -0,0 +1,851 @@
+package ffi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// nativeBackend implements Backend in pure Go on top of the os package
+// It is the only backend on Windows and can be selected on other platforms
+// to avoid the Rust library. Hard links inside a copied tree are copied as
+// separate files and extended attributes are not preserved.
+type nativeBackend struct {
+	diskEntries
+}
+
+// NewNativeBackend returns the pure Go backend
+func NewNativeBackend() Backend {
+	return nativeBackend{}
+}
+
+// Name implements Backend
+func (nativeBackend) Name() string {
+	return BackendNative
+}
+
+// CreateFolder implements Backend
+func (n nativeBackend) CreateFolder(ctx context.Context, path string, opts CreateOptions) Result {
+	return createEntry(ctx, n, path, opts, true, func() Result {
+		return createOnDisk(path, true, opts)
+	})
+}
+
+// CreateFile implements Backend
+func (n nativeBackend) CreateFile(ctx context.Context, path string, opts CreateOptions) Result {
+	return createEntry(ctx, n, path, opts, false, func() Result {
+		return createOnDisk(path, false, opts)
+	})
+}
+
+// DeletePath implements Backend
+func (nativeBackend) DeletePath(ctx context.Context, path string, opts DeleteOptions, progress ProgressFunc) Result {
+	if opts.ShredPasses > 0 {
+		return shredPath(ctx, path, opts, progress)
+	}
+	tracker := newProgressTracker(ctx, progress)
+	return tracker.finish(deletePath(path, tracker))
+}
+
+// deletePath deletes a file or directory, reporting to tracker
+func deletePath(path string, tracker *progressTracker) Result {
+	// First, check if the path exists; a link is deleted itself, even when dangling
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Result{
+				Success: false,
+				Message: fmt.Sprintf("Path '%s' does not exist", path),
+				Kind:    ErrorKindNotFound,
+			}
+		}
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access path '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	files, bytes, err := scanTree(path)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access path '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	tracker.setTotals(files, bytes)
+
+	// Handle directory deletion
+	if fileInfo.IsDir() {
+		err = removeTree(path, tracker)
+		if err != nil {
+			return Result{
+				Success: false,
+				Message: fmt.Sprintf("Failed to delete directory '%s': %v", path, err),
+				Kind:    kindFromError(err),
+			}
+		}
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Successfully deleted directory '%s'", path),
+		}
+	}
+
+	// Handle file deletion
+	err = removeTree(path, tracker)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete file '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully deleted file '%s'", path),
+	}
+}
+
+// ChangePermissions implements Backend
+// On Windows only the write bits matter, they control the read-only attribute
+func (nativeBackend) ChangePermissions(ctx context.Context, path string, mode uint32) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+
+	if err := changeMode(path, mode); err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to change permissions for '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully changed permissions for '%s' to %o", path, mode),
+	}
+}
+
+// ChangeOwner implements Backend
+func (nativeBackend) ChangeOwner(ctx context.Context, path string, uid, gid int, noFollow bool) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+
+	chown := os.Chown
+	if noFollow {
+		chown = os.Lchown
+	}
+	if err := chown(path, uid, gid); err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to change owner of '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully changed owner of '%s' to %s", path, ownerString(uid, gid)),
+	}
+}
+
+// MovePath implements Backend
+// A rename is reported as a single completed step; the copy+delete fallback reports per file
+func (nativeBackend) MovePath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result {
+	tracker := newProgressTracker(ctx, progress)
+	tracker.options = opts
+	return tracker.finish(movePath(src, dst, tracker))
+}
+
+// movePath moves a file or directory, reporting to tracker
+func movePath(src, dst string, tracker *progressTracker) Result {
+	// First check if source exists; a link moves itself, even when dangling
+	_, err := os.Lstat(src)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Source path '%s' does not exist: %v", src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	// Create parent directory if it doesn't exist
+	dstDir := filepath.Dir(dst)
+	if _, err := os.Stat(dstDir); os.IsNotExist(err) {
+		err = os.MkdirAll(dstDir, 0755)
+		if err != nil {
+			return Result{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create destination directory '%s': %v", dstDir, err),
+				Kind:    kindFromError(err),
+			}
+		}
+	}
+
+	if err := tracker.startFile(src); err != nil {
+		return cancelledResult(tracker.ctx, tracker.state)
+	}
+
+	// The source moves as a whole, so existing directories are not merged
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot move '%s': %v", src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	target, skip, err := resolveConflict(os.Lstat, src, srcInfo, dst, tracker.options.Conflict, false, tracker.record)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot move '%s' to '%s': %v", src, dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	if skip {
+		tracker.setTotals(1, 0)
+		tracker.finishFile()
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Skipped '%s': destination exists", src),
+		}
+	}
+	dst = target
+
+	err = os.Rename(src, dst)
+	if err != nil && kindFromError(err) == ErrorKindCrossDevice {
+		return moveByCopy(src, srcInfo, dst, tracker)
+	}
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to move '%s' to '%s': %v", src, dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	tracker.setTotals(1, 0)
+	tracker.finishFile()
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully moved '%s' to '%s'", src, dst),
+	}
+}
+
+// moveByCopy moves src to dst on another filesystem
+// The source is copied to a staging path next to dst with its metadata, the copy is
+// compared against the source by size and SHA-256 checksum and renamed into place, and
+// only then is the source deleted. On failure the staged copy is removed.
+func moveByCopy(src string, srcInfo os.FileInfo, dst string, tracker *progressTracker) Result {
+	staging := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s.moving-%d", filepath.Base(dst), os.Getpid()))
+	os.RemoveAll(staging)
+
+	tracker.options = CopyOptions{Conflict: ConflictOverwrite, Symlinks: SymlinkPreserve, PreserveMetadata: true}
+	copyResult := copyPath(src, staging, tracker)
+	if !copyResult.Success {
+		os.RemoveAll(staging)
+		return copyResult
+	}
+
+	err := verifyCopy(src, staging)
+	if err == nil {
+		err = os.Rename(staging, dst)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to move '%s' to '%s': %v", src, dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	// The copy is complete, so removing the source is not cancellable
+	if srcInfo.IsDir() {
+		err = os.RemoveAll(src)
+	} else {
+		err = os.Remove(src)
+	}
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Copied to '%s' but failed to remove source '%s': %v", dst, src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	return Result{
+		Success:     true,
+		Message:     fmt.Sprintf("Successfully moved '%s' to '%s' (copied across filesystems and verified)", src, dst),
+		MovedByCopy: true,
+		Strategy:    tracker.strategy,
+	}
+}
+
+// verifyCopy checks that dst is an exact copy of src without following symlinks
+// Directories must hold the same entries, symlinks the same target and files the
+// same size and SHA-256 checksum
+func verifyCopy(src, dst string) error {
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Lstat(dst)
+	if err != nil {
+		return err
+	}
+	mismatch := func(reason string) error {
+		return fmt.Errorf("verification failed for '%s': %s", dst, reason)
+	}
+
+	if srcInfo.Mode().Type() != dstInfo.Mode().Type() {
+		return mismatch("file type differs")
+	}
+
+	switch {
+	case srcInfo.Mode()&os.ModeSymlink != 0:
+		srcLink, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		dstLink, err := os.Readlink(dst)
+		if err != nil {
+			return err
+		}
+		if srcLink != dstLink {
+			return mismatch("symlink target differs")
+		}
+		return nil
+
+	case srcInfo.IsDir():
+		srcEntries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		dstEntries, err := os.ReadDir(dst)
+		if err != nil {
+			return err
+		}
+		if len(srcEntries) != len(dstEntries) {
+			return mismatch("directory entries differ")
+		}
+		for i, entry := range srcEntries {
+			if entry.Name() != dstEntries[i].Name() {
+				return mismatch("directory entries differ")
+			}
+			if err := verifyCopy(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if srcInfo.Size() != dstInfo.Size() {
+		return mismatch("size differs")
+	}
+	srcSum, err := sha256File(src)
+	if err != nil {
+		return err
+	}
+	dstSum, err := sha256File(dst)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(srcSum, dstSum) {
+		return mismatch("checksum differs")
+	}
+	return nil
+}
+
+// sha256File computes the SHA-256 checksum of a file's contents
+func sha256File(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// CopyPath implements Backend
+func (n nativeBackend) CopyPath(ctx context.Context, src, dst string, opts CopyOptions, progress ProgressFunc) Result {
+	tracker := newProgressTracker(ctx, progress)
+	tracker.options = opts
+	result := tracker.finish(copyPath(src, dst, tracker))
+	result.Strategy = tracker.strategy
+	return verifyCopied(ctx, n, src, dst, opts, result)
+}
+
+// copyPath copies a file or directory, reporting to tracker
+func copyPath(src, dst string, tracker *progressTracker) Result {
+	// Check the source exists
+	_, err := os.Lstat(src)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access source '%s': %v", src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	files, bytes, err := scanTree(src)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access source '%s': %v", src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	tracker.setTotals(files, bytes)
+
+	result := copyEntry(src, dst, tracker)
+	if result.Success && len(tracker.conflicts) > 0 {
+		result.Message = fmt.Sprintf("Successfully copied '%s' to '%s' (%d conflicts resolved)", src, dst, len(tracker.conflicts))
+	}
+	return result
+}
+
+// copyEntry copies a file, directory or symlink after resolving a conflict at dst
+// Hard links inside the source are copied as separate files
+func copyEntry(src, dst string, tracker *progressTracker) Result {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access source '%s': %v", src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch tracker.options.Symlinks {
+		case SymlinkSkip:
+			tracker.skip(1, 0)
+			return Result{Success: true, Message: fmt.Sprintf("Skipped symlink '%s'", src)}
+		case SymlinkPreserve:
+			return copySymlink(src, info, dst, tracker)
+		case SymlinkFollow:
+			if info, err = os.Stat(src); err != nil {
+				return Result{
+					Success: false,
+					Message: fmt.Sprintf("Failed to follow symlink '%s': %v", src, err),
+					Kind:    kindFromError(err),
+				}
+			}
+			if info.IsDir() && tracker.isAncestor(info) {
+				tracker.skip(1, 0)
+				return Result{Success: true, Message: fmt.Sprintf("Skipped symlink loop '%s'", src)}
+			}
+
+			// The scan counted the link itself, count what it points to instead
+			files, bytes := uint64(1), uint64(info.Size())
+			if info.IsDir() {
+				if resolved, err := filepath.EvalSymlinks(src); err == nil {
+					files, bytes, _ = scanTree(resolved)
+				}
+			}
+			tracker.addTotals(files-min(files, 1), bytes)
+		}
+	}
+
+	target, skip, err := resolveConflict(os.Lstat, src, info, dst, tracker.options.Conflict, true, tracker.record)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot copy '%s' to '%s': %v", src, dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	if skip {
+		files, bytes, _ := scanTree(src)
+		tracker.skip(files, bytes)
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Skipped '%s': destination exists", src),
+		}
+	}
+
+	// If source is a directory, copy it recursively
+	if info.IsDir() {
+		tracker.ancestors = append(tracker.ancestors, info)
+		result := copyDirectory(src, target, tracker)
+		tracker.ancestors = tracker.ancestors[:len(tracker.ancestors)-1]
+		if !result.Success {
+			return result
+		}
+		// Directories get their metadata last, copying entries would update their times
+		return preserveMetadata(info, target, tracker, result)
+	}
+
+	// Replace an existing file instead of writing into it, which fails for read-only files
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to replace '%s': %v", target, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	result := copyFile(src, target, tracker)
+	if !result.Success {
+		return result
+	}
+	return preserveMetadata(info, target, tracker, result)
+}
+
+// preserveMetadata copies the ownership, mode and times described by info to dst
+// when the options ask for it, returning result on success. Ownership is only
+// copied when running as root on Unix; extended attributes are not copied.
+func preserveMetadata(info os.FileInfo, dst string, tracker *progressTracker, result Result) Result {
+	if !tracker.options.PreserveMetadata {
+		return result
+	}
+
+	// Ownership changes clear setuid bits, so the mode is set afterwards
+	err := copyOwnership(info, dst)
+	if err == nil {
+		err = copyMode(info, dst)
+	}
+	if err == nil {
+		err = os.Chtimes(dst, accessTime(info), info.ModTime())
+	}
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to preserve metadata of '%s': %v", dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	return result
+}
+
+// copyFile copies a single file from src to dst
+func copyFile(src, dst string, tracker *progressTracker) Result {
+	if err := tracker.startFile(src); err != nil {
+		return cancelledResult(tracker.ctx, tracker.state)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to open source file '%s': %v", src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	defer srcFile.Close()
+
+	// Create parent directories if they don't exist
+	dstDir := filepath.Dir(dst)
+	if _, err := os.Stat(dstDir); os.IsNotExist(err) {
+		err = os.MkdirAll(dstDir, 0755)
+		if err != nil {
+			return Result{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create directory '%s': %v", dstDir, err),
+				Kind:    kindFromError(err),
+			}
+		}
+	}
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create destination file '%s': %v", dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	defer dstFile.Close()
+
+	srcInfo, err := srcFile.Stat()
+	if err == nil {
+		var strategy CopyStrategy
+		strategy, err = copyContents(srcFile, dstFile, srcInfo.Size(), tracker)
+		tracker.strategy = max(tracker.strategy, strategy)
+	}
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to copy data from '%s' to '%s': %v", src, dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	// Preserve file mode
+	os.Chmod(dst, srcInfo.Mode())
+	tracker.finishFile()
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully copied file '%s' to '%s'", src, dst),
+	}
+}
+
+// copySymlink recreates a symlink at dst
+func copySymlink(src string, info os.FileInfo, dst string, tracker *progressTracker) Result {
+	target, skip, err := resolveConflict(os.Lstat, src, info, dst, tracker.options.Conflict, false, tracker.record)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot copy '%s' to '%s': %v", src, dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	if skip {
+		tracker.skip(1, 0)
+		return Result{Success: true, Message: fmt.Sprintf("Skipped '%s': destination exists", src)}
+	}
+
+	if err := tracker.startFile(src); err != nil {
+		return cancelledResult(tracker.ctx, tracker.state)
+	}
+
+	link, err := os.Readlink(src)
+	if err == nil {
+		if err = os.Remove(target); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = os.Symlink(link, target)
+	}
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to copy symlink '%s' to '%s': %v", src, target, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	tracker.finishFile()
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully copied symlink '%s' to '%s'", src, target),
+	}
+}
+
+// copyDirectory copies a directory recursively
+func copyDirectory(src, dst string, tracker *progressTracker) Result {
+	// Create the destination directory
+	err := os.MkdirAll(dst, 0755)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create destination directory '%s': %v", dst, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	// Read the source directory
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read source directory '%s': %v", src, err),
+			Kind:    kindFromError(err),
+		}
+	}
+
+	// Copy each entry
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		result := copyEntry(srcPath, dstPath, tracker)
+		if !result.Success {
+			return result
+		}
+	}
+
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully copied directory '%s' to '%s'", src, dst),
+	}
+}
+
+// progressTracker accumulates progress and conflicts of an operation and
+// forwards progress to an optional ProgressFunc
+type progressTracker struct {
+	ctx       context.Context
+	state     Progress
+	progress  ProgressFunc
+	options   CopyOptions
+	conflicts []Conflict
+
+	// ancestors are the source directories currently being copied
+	ancestors []os.FileInfo
+
+	// strategy is the slowest strategy file contents were copied with so far
+	strategy CopyStrategy
+}
+
+// newProgressTracker creates a tracker bound to ctx; progress may be nil
+func newProgressTracker(ctx context.Context, progress ProgressFunc) *progressTracker {
+	return &progressTracker{ctx: ctx, progress: progress}
+}
+
+// finish attaches the final progress to result, reporting failures caused by
+// cancellation as cancelled results
+func (t *progressTracker) finish(result Result) Result {
+	if !result.Success && t.ctx.Err() != nil {
+		return cancelledResult(t.ctx, t.state)
+	}
+	result.Progress = t.state
+	result.Conflicts = t.conflicts
+	return result
+}
+
+// record remembers a resolved conflict
+func (t *progressTracker) record(conflict Conflict) {
+	t.conflicts = append(t.conflicts, conflict)
+}
+
+// isAncestor reports whether info is a directory that is currently being copied
+func (t *progressTracker) isAncestor(info os.FileInfo) bool {
+	for _, ancestor := range t.ancestors {
+		if os.SameFile(ancestor, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// addTotals grows the expected totals, e.g. when a followed link turns out to be a directory
+func (t *progressTracker) addTotals(files, bytes uint64) {
+	t.state.FilesTotal += files
+	t.state.BytesTotal += bytes
+	t.emit()
+}
+
+// skip counts entries as done without processing them
+func (t *progressTracker) skip(files, bytes uint64) {
+	t.state.FilesDone += files
+	t.state.BytesDone += bytes
+	t.emit()
+}
+
+func (t *progressTracker) setTotals(files, bytes uint64) {
+	t.state.FilesTotal = files
+	t.state.BytesTotal = bytes
+	t.emit()
+}
+
+// startFile marks path as the entry being processed
+// It returns the context error once cancelled, so callers stop between files
+func (t *progressTracker) startFile(path string) error {
+	t.state.CurrentPath = path
+	t.emit()
+	return t.ctx.Err()
+}
+
+func (t *progressTracker) addBytes(bytes uint64) {
+	t.state.BytesDone += bytes
+	t.emit()
+}
+
+func (t *progressTracker) finishFile() {
+	t.state.FilesDone++
+	t.emit()
+}
+
+func (t *progressTracker) emit() {
+	if t.progress != nil {
+		t.progress(t.state)
+	}
+}
+
+// Write implements io.Writer so copies can report bytes as they are written
+func (t *progressTracker) Write(p []byte) (int, error) {
+	t.addBytes(uint64(len(p)))
+	return len(p), nil
+}
+
+// scanTree counts the files and bytes below path without following links
+// Directories themselves are not counted as files, links count as files without bytes
+func scanTree(path string) (files uint64, bytes uint64, err error) {
+	err = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		files++
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			bytes += uint64(info.Size())
+		}
+		return nil
+	})
+	return files, bytes, err
+}
+
+// removeTree removes path depth-first, reporting each removed file
+func removeTree(path string, tracker *progressTracker) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := removeTree(filepath.Join(path, entry.Name()), tracker); err != nil {
+				return err
+			}
+		}
+		return os.Remove(path)
+	}
+
+	if err := tracker.startFile(path); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	tracker.addBytes(uint64(info.Size()))
+	tracker.finishFile()
+	return nil
+}
+
+// RenamePath implements Backend
+func (nativeBackend) RenamePath(ctx context.Context, oldPath, newPath string) Result {
+	if ctx.Err() != nil {
+		return cancelledResult(ctx, Progress{})
+	}
+
+	err := os.Rename(oldPath, newPath)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to rename '%s' to '%s': %v", oldPath, newPath, err),
+			Kind:    kindFromError(err),
+		}
+	}
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Successfully renamed '%s' to '%s'", oldPath, newPath),
+	}
+}
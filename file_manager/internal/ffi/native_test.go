@@ -0,0 +1,59 @@
+package ffi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMoveByCopy verifies the cross-filesystem fallback of the native backend copies
+// and verifies the source before removing it, and leaves no staged copy on failure
+func TestMoveByCopy(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	files := map[string]string{"a.txt": "alpha", "sub/b.txt": "beta"}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srcInfo, _ := os.Lstat(src)
+	dst := filepath.Join(root, "dst")
+
+	result := moveByCopy(src, srcInfo, dst, newProgressTracker(context.Background(), nil))
+	if !result.Success || !result.MovedByCopy || result.Strategy == CopyStrategyNone {
+		t.Fatalf("Expected a verified move by copy, got %+v", result)
+	}
+	if _, err := os.Lstat(src); !os.IsNotExist(err) {
+		t.Errorf("Expected the source to be removed, got %v", err)
+	}
+	for name, content := range files {
+		if data, err := os.ReadFile(filepath.Join(dst, name)); err != nil || string(data) != content {
+			t.Errorf("%s: expected %q, got %q (%v)", name, content, data, err)
+		}
+	}
+
+	// A destination the staged copy cannot be renamed onto fails the move
+	file := filepath.Join(root, "file.txt")
+	if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileInfo, _ := os.Lstat(file)
+	result = moveByCopy(file, fileInfo, dst, newProgressTracker(context.Background(), nil))
+	if result.Success || result.MovedByCopy {
+		t.Fatalf("Expected the move onto a folder to fail, got %+v", result)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "data" {
+		t.Errorf("Expected the source to be kept, got %q (%v)", data, err)
+	}
+	entries, _ := os.ReadDir(root)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".moving-") {
+			t.Errorf("Staged copy %s was left behind", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package ffi
+
+import (
+	"os"
+	"syscall"
+)
+
+// changeMode applies mode to path for the native backend (Unix implementation)
+// The raw mode is passed on so setuid, setgid and sticky bits are kept
+func changeMode(path string, mode uint32) error {
+	if err := syscall.Chmod(path, mode); err != nil {
+		return &os.PathError{Op: "chmod", Path: path, Err: err}
+	}
+	return nil
+}
+
+// copyMode copies the permission bits described by info to dst (Unix implementation)
+func copyMode(info os.FileInfo, dst string) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return changeMode(dst, uint32(stat.Mode)&07777)
+	}
+	return os.Chmod(dst, info.Mode().Perm())
+}
+
+// copyOwnership copies the owner and group described by info to dst
+// Only root may give files away, so other users keep owning their copies
+func copyOwnership(info os.FileInfo, dst string) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(dst, int(stat.Uid), int(stat.Gid))
+}
+
+// syncDir flushes the entries of a folder to disk so a rename in it survives a crash
+// Filesystems that cannot sync folders are left as they are.
+func syncDir(path string) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	dir.Sync()
+	dir.Close()
+}
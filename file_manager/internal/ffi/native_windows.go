@@ -0,0 +1,56 @@
+//go:build windows
+// +build windows
+
+package ffi
+
+import (
+	"os"
+	"syscall"
+)
+
+// changeMode applies mode to path for the native backend (Windows implementation)
+// Only the write bits matter: without them the read-only attribute is set
+func changeMode(path string, mode uint32) error {
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		return err
+	}
+	return setReadOnly(path, mode&0222 == 0)
+}
+
+// copyMode copies the read-only attribute described by info to dst (Windows implementation)
+func copyMode(info os.FileInfo, dst string) error {
+	return os.Chmod(dst, info.Mode().Perm())
+}
+
+// copyOwnership does nothing on Windows, files belong to the user creating them
+func copyOwnership(info os.FileInfo, dst string) error {
+	return nil
+}
+
+// setReadOnly sets the read-only attribute on a file or directory (Windows implementation)
+func setReadOnly(path string, readOnly bool) error {
+	// Convert path to UTF16 for Windows API
+	path16, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	// Get current attributes
+	attrs, err := syscall.GetFileAttributes(path16)
+	if err != nil {
+		return err
+	}
+
+	// Set or clear the read-only bit
+	if readOnly {
+		attrs |= syscall.FILE_ATTRIBUTE_READONLY
+	} else {
+		attrs &^= syscall.FILE_ATTRIBUTE_READONLY
+	}
+
+	// Set the new attributes
+	return syscall.SetFileAttributes(path16, attrs)
+}
+
+// syncDir does nothing on Windows, where folders cannot be opened for syncing
+func syncDir(path string) {}
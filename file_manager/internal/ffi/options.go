@@ -0,0 +1,72 @@
+package ffi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CopyOptions controls how copy and move treat their source and destination
+// The zero value overwrites existing entries and preserves symlinks
+type CopyOptions struct {
+	Conflict ConflictPolicy
+	Symlinks SymlinkPolicy
+	// PreserveMetadata keeps permission bits and times like cp -a does, along with
+	// ownership when running as root and extended attributes on Linux
+	PreserveMetadata bool
+	// Verify compares the checksums of every copied file with its source once a
+	// copy finished; moves are verified by the backends on their own when needed
+	Verify ChecksumAlgorithm
+}
+
+// SymlinkPolicy decides what a copy does with symbolic links
+// The numeric values match the symlink policies of the Rust core
+type SymlinkPolicy int
+
+const (
+	// SymlinkPreserve recreates links pointing at the same target
+	SymlinkPreserve SymlinkPolicy = iota
+	// SymlinkFollow copies what links point to, skipping links that form a loop
+	SymlinkFollow
+	// SymlinkSkip leaves links out of the copy
+	SymlinkSkip
+)
+
+// symlinkPolicyNames maps the names accepted by ParseSymlinkPolicy to policies
+var symlinkPolicyNames = map[string]SymlinkPolicy{
+	"preserve": SymlinkPreserve,
+	"follow":   SymlinkFollow,
+	"skip":     SymlinkSkip,
+}
+
+// ParseSymlinkPolicy parses a policy name such as "follow"
+// An empty name selects SymlinkPreserve
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	if name == "" {
+		return SymlinkPreserve, nil
+	}
+	policy, ok := symlinkPolicyNames[strings.ToLower(name)]
+	if !ok {
+		return SymlinkPreserve, fmt.Errorf("unknown symlink policy %q", name)
+	}
+	return policy, nil
+}
+
+// String returns the name accepted by ParseSymlinkPolicy
+func (p SymlinkPolicy) String() string {
+	for name, policy := range symlinkPolicyNames {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("SymlinkPolicy(%d)", int(p))
+}
+
+// WriteOptions controls how WriteFile writes the content of a file
+// The zero value replaces an existing file, keeping its permissions
+type WriteOptions struct {
+	// Exclusive fails the write with ErrorKindAlreadyExists when the file exists
+	Exclusive bool
+	// Mode sets the permission bits of the file, e.g. 0600; 0 gives a new file 0644
+	// less the umask and lets a replaced file keep its bits
+	Mode uint32
+}
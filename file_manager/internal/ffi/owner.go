@@ -0,0 +1,232 @@
+package ffi
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OwnerSpec is a chown owner: "user", "user:group", ":group", or "user:" for the
+// user and their login group, where users and groups are names or numeric IDs
+// The zero value is not an owner.
+type OwnerSpec struct {
+	// UID and GID are the new IDs, or -1 to leave them unchanged
+	UID  int
+	GID  int
+	text string
+}
+
+// ParseOwnerSpec parses a chown owner such as "alice", "1000:1000", ":staff" or "alice:",
+// looking up names in the user and group databases
+func ParseOwnerSpec(text string) (OwnerSpec, error) {
+	text = strings.TrimSpace(text)
+	name, group, hasGroup := strings.Cut(text, ":")
+	if name == "" && group == "" {
+		return OwnerSpec{}, fmt.Errorf("empty owner")
+	}
+
+	spec := OwnerSpec{UID: -1, GID: -1, text: text}
+	if name != "" {
+		uid, loginGroup, err := lookupOwner(name)
+		if err != nil {
+			return OwnerSpec{}, err
+		}
+		spec.UID = uid
+		if hasGroup && group == "" {
+			if loginGroup < 0 {
+				return OwnerSpec{}, fmt.Errorf("user %q has no login group; name the group after the colon", name)
+			}
+			spec.GID = loginGroup
+		}
+	}
+	if group != "" {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			return OwnerSpec{}, err
+		}
+		spec.GID = gid
+	}
+	return spec, nil
+}
+
+// lookupOwner returns the ID and login group of a user name or numeric ID
+// The login group is -1 for an ID that is not in the user database.
+func lookupOwner(name string) (int, int, error) {
+	if id, ok := numericID(name); ok {
+		if u, err := user.LookupId(name); err == nil {
+			if gid, ok := numericID(u.Gid); ok {
+				return id, gid, nil
+			}
+		}
+		return id, -1, nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown user %q", name)
+	}
+	uid, ok := numericID(u.Uid)
+	if !ok {
+		return 0, 0, fmt.Errorf("user %q has no numeric ID", name)
+	}
+	gid, ok := numericID(u.Gid)
+	if !ok {
+		gid = -1
+	}
+	return uid, gid, nil
+}
+
+// lookupGroup returns the ID of a group name or numeric ID
+func lookupGroup(name string) (int, error) {
+	if id, ok := numericID(name); ok {
+		return id, nil
+	}
+
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown group %q", name)
+	}
+	gid, ok := numericID(g.Gid)
+	if !ok {
+		return 0, fmt.Errorf("group %q has no numeric ID", name)
+	}
+	return gid, nil
+}
+
+// numericID parses a user or group ID such as "1000"
+func numericID(text string) (int, bool) {
+	id, err := strconv.ParseUint(text, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int(id), true
+}
+
+// IsZero reports whether s is the zero OwnerSpec, which does not describe an owner
+func (s OwnerSpec) IsZero() bool {
+	return s.text == ""
+}
+
+// String returns the owner as it was parsed
+func (s OwnerSpec) String() string {
+	return s.text
+}
+
+// ownerString formats a uid and gid as "uid:gid", with - for an ID left unchanged
+func ownerString(uid, gid int) string {
+	id := func(id int) string {
+		if id < 0 {
+			return "-"
+		}
+		return strconv.Itoa(id)
+	}
+	return id(uid) + ":" + id(gid)
+}
+
+// ChownOptions controls ChangeOwners
+type ChownOptions struct {
+	Owner OwnerSpec
+	// Recursive changes everything below a folder too; linked folders are never descended into
+	Recursive bool
+	// NoDereference changes symbolic links themselves instead of what they point to
+	NoDereference bool
+}
+
+// ChangeOwners gives path, and everything below it when opts.Recursive is set,
+// the owner of opts through backend.ChangeOwner
+// Entries that fail do not stop the walk; the first failure decides the error kind.
+// Without root, changing the owner is usually refused, which the message points out.
+func ChangeOwners(ctx context.Context, backend Backend, path string, opts ChownOptions) Result {
+	if opts.Owner.IsZero() {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("No owner given for '%s'", path),
+			Kind:    ErrorKindInvalidPath,
+		}
+	}
+
+	c := &chownWalk{ctx: ctx, backend: backend, opts: opts}
+	c.visit(path)
+
+	owner := fmt.Sprintf("%s (%s)", opts.Owner, ownerString(opts.Owner.UID, opts.Owner.GID))
+	if c.failed > 0 {
+		message := fmt.Sprintf("Changed owner of %d entries under '%s' to %s, %d failed: %s",
+			c.changed, path, owner, c.failed, c.firstFailure.Message)
+		if c.firstFailure.Kind == ErrorKindPermissionDenied && os.Geteuid() != 0 {
+			message += "; changing the owner of a file usually requires running as root"
+		}
+		return Result{Success: false, Message: message, Kind: c.firstFailure.Kind}
+	}
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Changed owner of %d entries under '%s' to %s", c.changed, path, owner),
+	}
+}
+
+// chownWalk holds the state of a ChangeOwners walk
+type chownWalk struct {
+	ctx     context.Context
+	backend Backend
+	opts    ChownOptions
+
+	changed      int
+	failed       int
+	firstFailure Result
+}
+
+// visit changes the owner of path and, for folders of a recursive walk, its entries
+func (c *chownWalk) visit(path string) {
+	if c.ctx.Err() != nil {
+		c.fail(cancelledResult(c.ctx, Progress{}))
+		return
+	}
+
+	info, err := c.backend.Lstat(path)
+	if err != nil {
+		c.fail(Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access path '%s': %v", path, err),
+			Kind:    KindOf(err),
+		})
+		return
+	}
+
+	noFollow := c.opts.NoDereference && info.Mode()&fs.ModeSymlink != 0
+	if result := c.backend.ChangeOwner(c.ctx, path, c.opts.Owner.UID, c.opts.Owner.GID, noFollow); result.Success {
+		c.changed++
+	} else {
+		c.fail(result)
+	}
+
+	if !info.IsDir() || !c.opts.Recursive {
+		return
+	}
+	entries, err := c.backend.ReadDir(path)
+	if err != nil {
+		c.fail(Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list '%s': %v", path, err),
+			Kind:    KindOf(err),
+		})
+		return
+	}
+	for _, entry := range entries {
+		c.visit(filepath.Join(path, entry.Name()))
+		if c.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// fail records a failed entry
+func (c *chownWalk) fail(result Result) {
+	if c.failed == 0 {
+		c.firstFailure = result
+	}
+	c.failed++
+}
@@ -0,0 +1,52 @@
+package ffi
+
+import (
+	"os/user"
+	"strconv"
+	"testing"
+)
+
+func TestParseOwnerSpec(t *testing.T) {
+	tests := []struct {
+		text     string
+		uid, gid int
+	}{
+		{"1000", 1000, -1},
+		{"1000:2000", 1000, 2000},
+		{":2000", -1, 2000},
+		{" 0:0 ", 0, 0},
+	}
+	for _, tt := range tests {
+		spec, err := ParseOwnerSpec(tt.text)
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		if spec.UID != tt.uid || spec.GID != tt.gid {
+			t.Errorf("%q: expected %d:%d, got %d:%d", tt.text, tt.uid, tt.gid, spec.UID, spec.GID)
+		}
+	}
+
+	for _, text := range []string{"", ":", "no-such-user-xyz", ":no-such-group-xyz", "-1", "1000:x:y"} {
+		if _, err := ParseOwnerSpec(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("No current user: %v", err)
+	}
+	uid, err := strconv.Atoi(current.Uid)
+	if err != nil {
+		t.Skipf("Non-numeric user ID %q", current.Uid)
+	}
+	gid, _ := strconv.Atoi(current.Gid)
+	spec, err := ParseOwnerSpec(current.Username + ":")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.UID != uid || spec.GID != gid {
+		t.Errorf("%s: expected %d:%d, got %d:%d", current.Username, uid, gid, spec.UID, spec.GID)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package ffi
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the uid and gid described by info, or -1 when it has none
+func fileOwner(info fs.FileInfo) (int, int) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid), int(stat.Gid)
+	}
+	return -1, -1
+}
@@ -0,0 +1,84 @@
+//go:build unix
+
+package ffi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// owner returns the uid and gid of path without following a link
+func owner(t *testing.T, path string) (int, int) {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	return int(stat.Uid), int(stat.Gid)
+}
+
+func TestChangeOwners(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range availableBackends(t) {
+		if backend.Name() == BackendMemory {
+			continue
+		}
+		t.Run(backend.Name(), func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "project")
+			file := filepath.Join(root, "src", "main.go")
+			link := filepath.Join(root, "link")
+			backend.CreateFolder(ctx, filepath.Join(root, "src"), CreateOptions{Parents: true})
+			backend.WriteFile(ctx, file, []byte("package main"), WriteOptions{})
+			if err := os.Symlink(file, link); err != nil {
+				t.Fatal(err)
+			}
+
+			if os.Geteuid() != 0 {
+				// Giving a file away needs root
+				spec, _ := ParseOwnerSpec("0:0")
+				result := ChangeOwners(ctx, backend, root, ChownOptions{Owner: spec, Recursive: true})
+				if result.Success || result.Kind != ErrorKindPermissionDenied || !strings.Contains(result.Message, "root") {
+					t.Errorf("Expected permission denied with a hint, got %v: %s", result.Kind, result.Message)
+				}
+				uid, gid := owner(t, file)
+				spec, _ = ParseOwnerSpec(":" + strconv.Itoa(gid))
+				if result := ChangeOwners(ctx, backend, root, ChownOptions{Owner: spec, Recursive: true}); !result.Success {
+					t.Fatal(result.Message)
+				}
+				if u, g := owner(t, file); u != uid || g != gid {
+					t.Errorf("Expected %d:%d, got %d:%d", uid, gid, u, g)
+				}
+				return
+			}
+
+			spec, _ := ParseOwnerSpec("4321:4322")
+			result := ChangeOwners(ctx, backend, root, ChownOptions{Owner: spec, Recursive: true, NoDereference: true})
+			if !result.Success {
+				t.Fatal(result.Message)
+			}
+			for _, path := range []string{root, filepath.Join(root, "src"), file, link} {
+				if uid, gid := owner(t, path); uid != 4321 || gid != 4322 {
+					t.Errorf("%s: expected 4321:4322, got %d:%d", path, uid, gid)
+				}
+			}
+
+			// Without -h the link target changes and the link keeps its owner
+			spec, _ = ParseOwnerSpec("4400")
+			if result := ChangeOwners(ctx, backend, link, ChownOptions{Owner: spec}); !result.Success {
+				t.Fatal(result.Message)
+			}
+			if uid, gid := owner(t, file); uid != 4400 || gid != 4322 {
+				t.Errorf("Expected the target to be 4400:4322, got %d:%d", uid, gid)
+			}
+			if uid, _ := owner(t, link); uid != 4321 {
+				t.Errorf("Expected the link to keep uid 4321, got %d", uid)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package ffi
+
+import "io/fs"
+
+// fileOwner returns -1 for both IDs, as Windows files have no Unix owner
+func fileOwner(info fs.FileInfo) (int, int) {
+	return -1, -1
+}
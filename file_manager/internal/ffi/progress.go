@@ -0,0 +1,31 @@
+package ffi
+
+// Progress describes how far a long-running operation has come
+type Progress struct {
+	FilesDone   uint64
+	FilesTotal  uint64
+	BytesDone   uint64
+	BytesTotal  uint64
+	CurrentPath string
+}
+
+// ProgressFunc receives progress updates
+// It is called synchronously from the goroutine running the operation
+type ProgressFunc func(Progress)
+
+// Percent returns the completion percentage, based on bytes when known
+// and on file counts otherwise
+func (p Progress) Percent() float64 {
+	if p.BytesTotal > 0 {
+		return float64(p.BytesDone) * 100 / float64(p.BytesTotal)
+	}
+	if p.FilesTotal > 0 {
+		return float64(p.FilesDone) * 100 / float64(p.FilesTotal)
+	}
+	return 0
+}
+
+// Done reports whether every file has been processed
+func (p Progress) Done() bool {
+	return p.FilesDone >= p.FilesTotal
+}
@@ -0,0 +1,141 @@
+package ffi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies why an operation failed
+// The numeric values match the error codes returned by the Rust core
+type ErrorKind int
+
+const (
+	ErrorKindNone ErrorKind = iota
+	ErrorKindNotFound
+	ErrorKindPermissionDenied
+	ErrorKindAlreadyExists
+	ErrorKindNotEmpty
+	ErrorKindCrossDevice
+	ErrorKindInvalidPath
+	ErrorKindIO
+	ErrorKindCancelled
+	// ErrorKindChecksumMismatch is set by verified copies and never returned by the Rust core
+	ErrorKindChecksumMismatch
+)
+
+// Code returns the stable, machine-readable name of the error kind
+func (k ErrorKind) Code() string {
+	switch k {
+	case ErrorKindNone:
+		return ""
+	case ErrorKindNotFound:
+		return "NOT_FOUND"
+	case ErrorKindPermissionDenied:
+		return "PERMISSION_DENIED"
+	case ErrorKindAlreadyExists:
+		return "ALREADY_EXISTS"
+	case ErrorKindNotEmpty:
+		return "NOT_EMPTY"
+	case ErrorKindCrossDevice:
+		return "CROSS_DEVICE"
+	case ErrorKindInvalidPath:
+		return "INVALID_PATH"
+	case ErrorKindCancelled:
+		return "CANCELLED"
+	case ErrorKindChecksumMismatch:
+		return "CHECKSUM_MISMATCH"
+	default:
+		return "IO_ERROR"
+	}
+}
+
+// KindOf classifies an error returned by a Backend, such as those of Lstat and ReadDir
+func KindOf(err error) ErrorKind {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindCancelled
+	}
+	if kind := memErrorKind(err); kind != ErrorKindIO {
+		return kind
+	}
+	return kindFromError(err)
+}
+
+// String implements fmt.Stringer
+func (k ErrorKind) String() string {
+	if k == ErrorKindNone {
+		return "NONE"
+	}
+	return k.Code()
+}
+
+// Result represents the outcome of a file operation
+type Result struct {
+	Success bool
+	Message string
+	Kind    ErrorKind
+
+	// Progress is the last progress snapshot of operations that report progress,
+	// telling how much was completed when an operation fails or is cancelled
+	Progress Progress
+
+	// Conflicts lists the entries of a copy or move that already existed at the destination
+	Conflicts []Conflict
+
+	// MovedByCopy is set when a move crossed filesystems, so the source was copied,
+	// verified by size and checksum and deleted instead of renamed
+	MovedByCopy bool
+
+	// Mismatches lists the copied files that failed verification
+	Mismatches []Mismatch
+
+	// Strategy is the slowest way the contents of any file of a copy were copied
+	Strategy CopyStrategy
+
+	// Skipped lists the files a shredding delete left in place because overwriting
+	// them would not reach their data
+	Skipped []SkippedFile
+
+	// ModeChanges lists the old and new permission bits of the entries ChangeModes visited
+	ModeChanges []ModeChange
+}
+
+// CopyStrategy tells how the contents of copied files were copied, from fastest to slowest
+// The numeric values match the copy strategies of the Rust core
+type CopyStrategy int
+
+const (
+	// CopyStrategyNone means no file contents were copied
+	CopyStrategyNone CopyStrategy = iota
+	// CopyStrategyReflink shares the data blocks of the source (FICLONE on btrfs, XFS and others)
+	CopyStrategyReflink
+	// CopyStrategyCopyFileRange lets the kernel copy the data with copy_file_range
+	CopyStrategyCopyFileRange
+	// CopyStrategyBuffered reads the data into a buffer and writes it out
+	CopyStrategyBuffered
+)
+
+// String returns the name of the strategy, empty for CopyStrategyNone
+func (s CopyStrategy) String() string {
+	switch s {
+	case CopyStrategyNone:
+		return ""
+	case CopyStrategyReflink:
+		return "reflink"
+	case CopyStrategyCopyFileRange:
+		return "copy_file_range"
+	case CopyStrategyBuffered:
+		return "buffered"
+	default:
+		return fmt.Sprintf("CopyStrategy(%d)", int(s))
+	}
+}
+
+// PrintResult prints a formatted result message
+func PrintResult(result Result) {
+	if result.Success {
+		fmt.Printf("✅ %s\n", result.Message)
+	} else {
+		fmt.Printf("❌ %s\n", result.Message)
+	}
+}
@@ -0,0 +1,233 @@
+package ffi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	mathrand "math/rand/v2"
+	"os"
+	"path/filepath"
+)
+
+// DeleteOptions controls how DeletePath removes files
+// The zero value unlinks them without touching their contents
+type DeleteOptions struct {
+	// ShredPasses is how many times the contents of every file are overwritten with
+	// random data before it is unlinked; 0 does not shred
+	ShredPasses int
+	// VerifyShred reads every file back after the last pass and fails the delete
+	// when the data on disk differs from what was written
+	VerifyShred bool
+}
+
+// DefaultShredPasses is the number of passes the CLI and API shred with unless told otherwise
+const DefaultShredPasses = 3
+
+// SkippedFile is a file an operation deliberately left alone
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// errShredMismatch is returned when a shredded file does not read back as written
+var errShredMismatch = errors.New("contents differ from the last pass")
+
+// shredPath overwrites every file below path opts.ShredPasses times and removes
+// the tree, reporting to progress
+// The Rust core only unlinks, so the disk backends share this implementation.
+func shredPath(ctx context.Context, path string, opts DeleteOptions, progress ProgressFunc) Result {
+	tracker := newProgressTracker(ctx, progress)
+
+	if _, err := os.Lstat(path); err != nil {
+		if os.IsNotExist(err) {
+			return tracker.finish(Result{
+				Success: false,
+				Message: fmt.Sprintf("Path '%s' does not exist", path),
+				Kind:    ErrorKindNotFound,
+			})
+		}
+		return tracker.finish(Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access path '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		})
+	}
+
+	files, bytes, err := scanTree(path)
+	if err != nil {
+		return tracker.finish(Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to access path '%s': %v", path, err),
+			Kind:    kindFromError(err),
+		})
+	}
+	tracker.setTotals(files, bytes)
+
+	var skipped []SkippedFile
+	if _, err := shredTree(path, opts, tracker, &skipped); err != nil {
+		return tracker.finish(Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to shred '%s': %v", path, err),
+			Kind:    kindFromError(err),
+			Skipped: skipped,
+		})
+	}
+	return tracker.finish(shredResult(path, opts, skipped))
+}
+
+// shredResult builds the result of a shred that finished, warning about skipped files
+func shredResult(path string, opts DeleteOptions, skipped []SkippedFile) Result {
+	how := fmt.Sprintf("%d pass(es)", opts.ShredPasses)
+	if opts.VerifyShred {
+		how += ", verified"
+	}
+	if len(skipped) > 0 {
+		return Result{
+			Success: true,
+			Message: fmt.Sprintf("Shredded '%s' (%s), but left %d file(s) in place where overwriting cannot reach the data", path, how, len(skipped)),
+			Skipped: skipped,
+		}
+	}
+	return Result{
+		Success: true,
+		Message: fmt.Sprintf("Shredded '%s' (%s)", path, how),
+	}
+}
+
+// shredTree shreds path depth-first, appending the files it leaves in place to skipped
+// It reports whether anything was kept, in which case the folders above it stay too.
+func shredTree(path string, opts DeleteOptions, tracker *progressTracker, skipped *[]SkippedFile) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return false, err
+		}
+		kept := false
+		for _, entry := range entries {
+			keptChild, err := shredTree(filepath.Join(path, entry.Name()), opts, tracker, skipped)
+			if err != nil {
+				return false, err
+			}
+			kept = kept || keptChild
+		}
+		if kept {
+			return true, nil
+		}
+		return false, os.Remove(path)
+	}
+
+	if err := tracker.startFile(path); err != nil {
+		return false, err
+	}
+	// Links and special files have no contents of their own to overwrite
+	if info.Mode().IsRegular() {
+		reason, err := shredFile(path, info, opts)
+		if err != nil {
+			return false, err
+		}
+		if reason != "" {
+			*skipped = append(*skipped, SkippedFile{Path: path, Reason: reason})
+			tracker.addBytes(uint64(info.Size()))
+			tracker.finishFile()
+			return true, nil
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	tracker.addBytes(uint64(info.Size()))
+	tracker.finishFile()
+	return false, nil
+}
+
+// shredFile overwrites the contents of a regular file and truncates it
+// It returns why the file was left alone when overwriting would not reach its data.
+func shredFile(path string, info fs.FileInfo, opts DeleteOptions) (string, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, fs.ErrPermission) {
+		// Credentials are often read-only; the owner may still make them writable
+		if os.Chmod(path, info.Mode().Perm()|0200) == nil {
+			file, err = os.OpenFile(path, os.O_WRONLY, 0)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if reason := copyOnWrite(file); reason != "" {
+		return reason, nil
+	}
+
+	size := info.Size()
+	var seed [32]byte
+	for pass := 0; pass < opts.ShredPasses; pass++ {
+		if _, err := rand.Read(seed[:]); err != nil {
+			return "", err
+		}
+		if err := overwrite(file, size, seed); err != nil {
+			return "", err
+		}
+	}
+
+	if opts.VerifyShred {
+		if err := verifyOverwrite(path, size, seed); err != nil {
+			return "", err
+		}
+	}
+	return "", file.Truncate(0)
+}
+
+// overwrite writes size bytes of the random stream of seed over file and syncs it
+func overwrite(file *os.File, size int64, seed [32]byte) error {
+	stream := mathrand.NewChaCha8(seed)
+	buffer := make([]byte, min(int64(shredBufferSize), size))
+	for offset := int64(0); offset < size; {
+		chunk := buffer[:min(int64(len(buffer)), size-offset)]
+		stream.Read(chunk)
+		if _, err := file.WriteAt(chunk, offset); err != nil {
+			return err
+		}
+		offset += int64(len(chunk))
+	}
+	return file.Sync()
+}
+
+// verifyOverwrite checks that path holds the random stream of seed
+// The cached pages are dropped first where possible, so the data comes from the disk.
+func verifyOverwrite(path string, size int64, seed [32]byte) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dropCache(file)
+
+	stream := mathrand.NewChaCha8(seed)
+	expected := make([]byte, min(int64(shredBufferSize), size))
+	actual := make([]byte, len(expected))
+	for offset := int64(0); offset < size; {
+		n := int(min(int64(len(expected)), size-offset))
+		stream.Read(expected[:n])
+		if _, err := io.ReadFull(file, actual[:n]); err != nil {
+			return err
+		}
+		if !bytes.Equal(expected[:n], actual[:n]) {
+			return &fs.PathError{Op: "verify", Path: path, Err: errShredMismatch}
+		}
+		offset += int64(n)
+	}
+	return nil
+}
+
+// shredBufferSize is the size of the chunks files are overwritten and verified in
+const shredBufferSize = 1024 * 1024
@@ -0,0 +1,25 @@
+package ffi
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyOnWrite tells why overwriting file would write new blocks instead of
+// replacing its data, or returns "" when it is overwritten in place
+func copyOnWrite(file *os.File) string {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(file.Fd()), &st); err != nil {
+		return ""
+	}
+	if unix.ByteSliceToString(st.Fstypename[:]) == "apfs" {
+		return "APFS writes overwritten data to new blocks"
+	}
+	return ""
+}
+
+// dropCache makes later reads of file bypass the cache
+func dropCache(file *os.File) {
+	unix.FcntlInt(file.Fd(), unix.F_NOCACHE, 1)
+}
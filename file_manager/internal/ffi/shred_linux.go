@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package ffi
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Filesystem magic numbers and inode flags missing from x/sys/unix
+const (
+	zfsSuperMagic = 0x2fc12fc1
+	// fsNoCowFlag marks btrfs files that are overwritten in place (chattr +C)
+	fsNoCowFlag = 0x00800000
+)
+
+// copyOnWrite tells why overwriting file would write new blocks instead of
+// replacing its data, or returns "" when it is overwritten in place
+func copyOnWrite(file *os.File) string {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(file.Fd()), &st); err != nil {
+		return ""
+	}
+
+	switch uint32(st.Type) {
+	case unix.BTRFS_SUPER_MAGIC:
+		flags, err := unix.IoctlGetUint32(int(file.Fd()), unix.FS_IOC_GETFLAGS)
+		if err == nil && flags&fsNoCowFlag != 0 {
+			return ""
+		}
+		return "btrfs writes overwritten data to new blocks"
+	case unix.BCACHEFS_SUPER_MAGIC:
+		return "bcachefs writes overwritten data to new blocks"
+	case zfsSuperMagic:
+		return "ZFS writes overwritten data to new blocks"
+	}
+	return ""
+}
+
+// dropCache asks the kernel to forget the cached pages of file
+func dropCache(file *os.File) {
+	unix.Fadvise(int(file.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package ffi
+
+import "os"
+
+// copyOnWrite tells why overwriting file would not replace its data
+// Copy-on-write filesystems are not detected on this platform.
+func copyOnWrite(file *os.File) string {
+	return ""
+}
+
+// dropCache does nothing; verification may read back cached pages on this platform
+func dropCache(file *os.File) {}
@@ -0,0 +1,65 @@
+package ffi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestShredDelete verifies every backend overwrites and removes a tree, including
+// read-only files, and verifies the last pass when asked
+func TestShredDelete(t *testing.T) {
+	ctx := context.Background()
+
+	for _, backend := range availableBackends(t) {
+		t.Run(backend.Name(), func(t *testing.T) {
+			root := t.TempDir()
+			secrets := filepath.Join(root, "secrets")
+			backend.CreateFolder(ctx, filepath.Join(secrets, "nested"), CreateOptions{Parents: true})
+			backend.WriteFile(ctx, filepath.Join(secrets, "key.pem"), make([]byte, 3*shredBufferSize/2), WriteOptions{})
+			backend.WriteFile(ctx, filepath.Join(secrets, "nested", "token"), []byte("hunter2"), WriteOptions{})
+			backend.CreateFile(ctx, filepath.Join(secrets, "empty"), CreateOptions{})
+			backend.ChangePermissions(ctx, filepath.Join(secrets, "nested", "token"), 0400)
+
+			result := backend.DeletePath(ctx, secrets, DeleteOptions{ShredPasses: 2, VerifyShred: true}, nil)
+			if !result.Success {
+				t.Fatal(result.Message)
+			}
+			if len(result.Skipped) > 0 {
+				t.Skipf("Copy-on-write filesystem: %+v", result.Skipped)
+			}
+			if result.Progress.FilesDone != 3 {
+				t.Errorf("Expected 3 files done, got %d", result.Progress.FilesDone)
+			}
+			if _, err := backend.Lstat(secrets); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Shredded folder still exists: %v", err)
+			}
+		})
+	}
+}
+
+// TestVerifyOverwrite verifies data that differs from the last pass is detected
+func TestVerifyOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	seed := [32]byte{1, 2, 3}
+	if err := overwrite(file, 4096, seed); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyOverwrite(path, 4096, seed); err != nil {
+		t.Errorf("Overwritten file failed verification: %v", err)
+	}
+	if _, err := file.WriteAt([]byte{0}, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyOverwrite(path, 4096, [32]byte{1, 2, 3}); !errors.Is(err, errShredMismatch) {
+		t.Errorf("Expected %v, got %v", errShredMismatch, err)
+	}
+}
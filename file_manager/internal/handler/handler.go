@@ -1,15 +1,22 @@
 package handler
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"filemanager/internal/ffi"
+	"filemanager/internal/journal"
 	"filemanager/internal/service"
+	"filemanager/internal/trash"
 	"filemanager/pkg/version"
 	"fmt"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // APIRequest represents incoming API requests
@@ -24,30 +31,300 @@ type APIRequest struct {
 	Template  string   `json:"template"`
 	RootDir   string   `json:"rootDir"`
 	Structure string   `json:"structure"`
+
+	// Conflict selects what copy and move do with existing destinations:
+	// overwrite (default), fail, skip, keep-both or overwrite-if-newer
+	Conflict string `json:"conflict,omitempty"`
+
+	// Symlinks selects what copy does with symbolic links: preserve (default), follow or skip
+	Symlinks string `json:"symlinks,omitempty"`
+
+	// Archive preserves permissions, times, ownership (as root) and extended attributes like cp -a
+	Archive bool `json:"archive,omitempty"`
+
+	// Parallelism, FailFast and Transactional tune batch operations such as createTree
+	Parallelism   int  `json:"parallelism,omitempty"`
+	FailFast      bool `json:"failFast,omitempty"`
+	Transactional bool `json:"transactional,omitempty"`
+
+	// DryRun returns the plan of the operation instead of running it
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Verify compares checksums of the copied files with their sources: sha256 or blake3
+	Verify string `json:"verify,omitempty"`
+
+	// Permanent makes delete remove entries instead of moving them to the trash
+	Permanent bool `json:"permanent,omitempty"`
+
+	// Passes is how many times shred overwrites every file; 0 selects ffi.DefaultShredPasses
+	Passes int `json:"passes,omitempty"`
+
+	// VerifyShred makes shred read every file back after the last pass
+	VerifyShred bool `json:"verifyShred,omitempty"`
+
+	// Confirm must be "shred" for shred requests, which cannot be undone
+	Confirm string `json:"confirm,omitempty"`
+
+	// DirMode is the mode chmod gives folders; Mode applies to them too when it is empty
+	DirMode string `json:"dirMode,omitempty"`
+
+	// Recursive makes chmod and chown change everything below a folder
+	Recursive bool `json:"recursive,omitempty"`
+
+	// Preview makes chmod list the mode changes in "modes" without making them
+	Preview bool `json:"preview,omitempty"`
+
+	// Owner is the new owner of chown: "user", "user:group", ":group" or "user:",
+	// with names or numeric IDs
+	Owner string `json:"owner,omitempty"`
+
+	// NoDereference makes chown change symbolic links themselves instead of their targets
+	NoDereference bool `json:"noDereference,omitempty"`
+
+	// Include and Exclude are glob patterns, such as **/*.pyc, that make delete, chmod,
+	// copy and move apply to the matching entries below their paths or source; paths
+	// may hold patterns themselves, as in build/**/*.log
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+
+	// MinSize and MaxSize bound the size of matched files, such as 1500, 10K or 2M
+	MinSize string `json:"minSize,omitempty"`
+	MaxSize string `json:"maxSize,omitempty"`
+
+	// OlderThan and NewerThan bound the age of matched files, such as 90m, 12h or 7d
+	OlderThan string `json:"olderThan,omitempty"`
+	NewerThan string `json:"newerThan,omitempty"`
+
+	// Find, Replace, Case, Slugify and Start are the rule of bulkRename, which renames
+	// the entries paths select; see service.RenameRule for the tokens Replace takes
+	Find    string `json:"find,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	Case    string `json:"case,omitempty"`
+	Slugify bool   `json:"slugify,omitempty"`
+	Start   int    `json:"start,omitempty"`
+
+	// Content is what createFile writes to every file: text, or binary data when
+	// Encoding is base64. For createFile and createFolder, Mode sets the permissions
+	// of the new entries, such as 600, Owner their owner, and Exclusive fails for
+	// those that already exist.
+	Content   string `json:"content,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	Exclusive bool   `json:"exclusive,omitempty"`
+
+	// Parents false makes createFile and createFolder fail when the parent folder is missing
+	Parents *bool `json:"parents,omitempty"`
 }
 
 // APIResponse represents API responses
 type APIResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Results []struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
-	} `json:"results,omitempty"`
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Code    string       `json:"code,omitempty"`
+	Results []ItemResult `json:"results,omitempty"`
+
+	// MovedByCopy is set when a move crossed filesystems and fell back to copy and delete
+	MovedByCopy bool `json:"movedByCopy,omitempty"`
+
+	// Strategy tells how a copy copied file contents: reflink, copy_file_range or buffered
+	Strategy string `json:"strategy,omitempty"`
+
+	// Plan lists what a dry run would do; success and counts tell whether it would work
+	Plan *PlanResponse `json:"plan,omitempty"`
+
+	// Modes lists the old and new mode of every entry chmod visited
+	Modes []ModeChangeResponse `json:"modes,omitempty"`
+
+	// Matches lists the entries glob patterns and filters selected
+	Matches []string `json:"matches,omitempty"`
+
+	// Renames lists the old and new path of every entry bulkRename renames
+	Renames []RenameResponse `json:"renames,omitempty"`
+
 	Count struct {
 		Success int `json:"success"`
 		Failed  int `json:"failed"`
 	} `json:"count,omitempty"`
 }
 
+// ItemResult represents the outcome for a single path in a multi-path operation
+type ItemResult struct {
+	Path    string `json:"path"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+
+	// Action tells how an existing destination was handled: overwritten, skipped or renamed
+	Action string `json:"action,omitempty"`
+}
+
+// ModeChangeResponse is the permission change of a single entry
+// Modes are given in octal, such as "0755", and like ls, such as "rwxr-xr-x".
+type ModeChangeResponse struct {
+	Path           string `json:"path"`
+	IsDir          bool   `json:"isDir"`
+	Before         string `json:"before"`
+	After          string `json:"after"`
+	BeforeSymbolic string `json:"beforeSymbolic"`
+	AfterSymbolic  string `json:"afterSymbolic"`
+}
+
+// RenameResponse is the renaming of a single entry by bulkRename
+// Conflict tells why the entry cannot be renamed; no entry is renamed while one has a conflict.
+type RenameResponse struct {
+	OldPath  string `json:"oldPath"`
+	NewPath  string `json:"newPath"`
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// PlanResponse is the plan of a dry run
+type PlanResponse struct {
+	Actions   []PlanAction   `json:"actions"`
+	Existing  []string       `json:"existing"`
+	Conflicts []PlanConflict `json:"conflicts"`
+}
+
+// PlanAction is a single change a dry run would make
+type PlanAction struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Dest string `json:"dest,omitempty"`
+	Size int64  `json:"size,omitempty"`
+	Mode string `json:"mode,omitempty"`
+	// Owner is the "uid:gid" a chown would set, with - for an ID left unchanged
+	Owner string `json:"owner,omitempty"`
+}
+
+// PlanConflict is a destination entry that already exists
+// Action tells how it would be handled: overwritten, skipped or renamed
+type PlanConflict struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+	Action string `json:"action"`
+}
+
+// shredConfirmation is the value of APIRequest.Confirm that allows a shred
+const shredConfirmation = "shred"
+
+// maxShredPasses bounds the passes of a shred request, the 35 of the Gutmann method
+const maxShredPasses = 35
+
+// Response codes for request-level errors that do not come from an ffi.ErrorKind
+const (
+	codeInvalidRequest   = "INVALID_REQUEST"
+	codeUnknownOperation = "UNKNOWN_OPERATION"
+)
+
+// ProgressEvent is streamed to the browser while a long-running operation runs
+type ProgressEvent struct {
+	FilesDone   uint64  `json:"filesDone"`
+	FilesTotal  uint64  `json:"filesTotal"`
+	BytesDone   uint64  `json:"bytesDone"`
+	BytesTotal  uint64  `json:"bytesTotal"`
+	CurrentPath string  `json:"currentPath"`
+	Percent     float64 `json:"percent"`
+}
+
+// progressInterval limits how often progress events are streamed
+const progressInterval = 100 * time.Millisecond
+
+// statusClientClosedRequest is reported when the client went away before the operation finished
+const statusClientClosedRequest = 499
+
 // TemplateInfo represents template metadata
 type TemplateInfo struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 }
 
+// TrashItem represents an entry of the trash
+// Path identifies the entry for the restore operation.
+type TrashItem struct {
+	Path         string    `json:"path"`
+	OriginalPath string    `json:"originalPath"`
+	DeletionDate time.Time `json:"deletionDate"`
+	IsDir        bool      `json:"isDir"`
+	Size         int64     `json:"size"`
+}
+
+// HistoryEntry is an operation of the history, most recent first
+// Undone entries are those redo makes again, earliest first.
+type HistoryEntry struct {
+	ID     int           `json:"id"`
+	Time   time.Time     `json:"time"`
+	Title  string        `json:"title"`
+	Undone bool          `json:"undone"`
+	Steps  []HistoryStep `json:"steps"`
+	// Notes tell what the operation changed that undo cannot reverse
+	Notes []string `json:"notes,omitempty"`
+}
+
+// HistoryStep is a single change of a HistoryEntry
+// Op is create, move, chmod, chown, trash or restore; Dest is where a move went.
+type HistoryStep struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Dest string `json:"dest,omitempty"`
+}
+
+// EntryResponse describes a file, folder or link
+// UID and GID are -1 and Owner and Group empty where there are no Unix owners.
+type EntryResponse struct {
+	Name         string    `json:"name"`
+	Path         string    `json:"path"`
+	Type         string    `json:"type"`
+	Size         int64     `json:"size"`
+	Mode         string    `json:"mode"`
+	ModeSymbolic string    `json:"modeSymbolic"`
+	UID          int       `json:"uid"`
+	GID          int       `json:"gid"`
+	Owner        string    `json:"owner,omitempty"`
+	Group        string    `json:"group,omitempty"`
+	Modified     time.Time `json:"modified"`
+	Accessed     time.Time `json:"accessed"`
+	LinkTarget   string    `json:"linkTarget,omitempty"`
+}
+
+// ListResponse is a page of the entries of a folder
+// Total counts the entries of every page together.
+type ListResponse struct {
+	Path    string          `json:"path"`
+	Entries []EntryResponse `json:"entries"`
+	Total   int             `json:"total"`
+	Offset  int             `json:"offset"`
+}
+
+// Handler serves the file operation API on top of a filesystem backend
+type Handler struct {
+	backend ffi.Backend
+	files   *service.FileService
+	trash   *trash.Trash
+	// history records the changes of every request; it is nil when they are not recorded
+	history *journal.Journal
+}
+
+// New creates a Handler whose operations run on backend
+func New(backend ffi.Backend) *Handler {
+	return &Handler{backend: backend, files: service.NewFileService(backend), trash: trash.New(backend)}
+}
+
+// NewWithHistory creates a Handler whose operations run on the backend of history,
+// recording the changes of every request as one entry that can be undone
+func NewWithHistory(history *journal.Journal) *Handler {
+	backend := history.Backend()
+	return &Handler{backend: backend, files: service.NewFileService(backend), trash: history.Trash(), history: history}
+}
+
+// begin groups the changes made with the returned context into one entry of the
+// history, which end records
+func (h *Handler) begin(ctx context.Context) (context.Context, func()) {
+	if h.history == nil {
+		return ctx, func() {}
+	}
+	return h.history.Begin(ctx)
+}
+
 // HandleOperation is the main API endpoint handler
-func HandleOperation(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HandleOperation(w http.ResponseWriter, r *http.Request) {
 	// Enable CORS
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
@@ -66,58 +343,200 @@ func HandleOperation(w http.ResponseWriter, r *http.Request) {
 
 	var req APIRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, "Invalid request format", http.StatusBadRequest)
+		respondError(w, "Invalid request format", codeInvalidRequest, http.StatusBadRequest)
 		return
 	}
 
+	// A dry run plans the operation on an overlay of the backend
+	api := h
+	var dryRun *ffi.DryRun
+	if req.DryRun {
+		dryRun = ffi.NewDryRun(h.backend)
+		api = New(dryRun)
+	}
+
+	// Operations stop between files once the client disconnects
+	ctx, end := api.begin(r.Context())
 	var response APIResponse
 
 	switch req.Operation {
 	case "createFolder":
-		response = handleCreateFolderAPI(req)
+		response = api.handleCreateFolderAPI(ctx, req)
 	case "createFile":
-		response = handleCreateFileAPI(req)
+		response = api.handleCreateFileAPI(ctx, req)
 	case "rename":
-		response = handleRenameAPI(req)
+		response = api.handleRenameAPI(ctx, req)
+	case "bulkRename":
+		response = api.handleBulkRenameAPI(ctx, req)
 	case "delete":
-		response = handleDeleteAPI(req)
+		response = api.handleDeleteAPI(ctx, req)
+	case "restore":
+		response = api.handleRestoreAPI(ctx, req)
+	case "emptyTrash":
+		response = resultResponse(api.trash.Empty(ctx, nil))
+	case "shred":
+		response = api.handleShredAPI(ctx, req)
 	case "chmod":
-		response = handleChmodAPI(req)
+		response = api.handleChmodAPI(ctx, req)
+	case "chown":
+		response = api.handleChownAPI(ctx, req)
 	case "move":
-		response = handleMoveAPI(req)
+		response = api.handleMoveAPI(ctx, req)
 	case "copy":
-		response = handleCopyAPI(req)
+		response = api.handleCopyAPI(ctx, req)
 	case "createTemplate":
-		response = handleCreateTemplateAPI(req)
+		response = api.handleCreateTemplateAPI(ctx, req)
 	case "createCustom":
-		response = handleCreateCustomAPI(req)
+		response = api.handleCreateCustomAPI(ctx, req)
 	case "createTree":
-		response = handleCreateTreeAPI(req)
+		response = api.handleCreateTreeAPI(ctx, req)
+	case "undo", "redo":
+		response = api.handleHistoryAPI(ctx, req)
 	default:
-		respondError(w, "Unknown operation", http.StatusBadRequest)
+		end()
+		respondError(w, "Unknown operation", codeUnknownOperation, http.StatusBadRequest)
 		return
 	}
+	end()
 
+	if dryRun != nil {
+		response.Message = "Dry run: " + response.Message
+		response.Plan = planResponse(dryRun.Plan())
+	}
+
+	if !response.Success && response.Code == "" {
+		response.Code = ffi.ErrorKindIO.Code()
+	}
+	w.WriteHeader(statusForResponse(response))
 	json.NewEncoder(w).Encode(response)
 }
 
-func handleCreateFolderAPI(req APIRequest) APIResponse {
-	var response APIResponse
-	successCount := 0
-	errorCount := 0
+// HandleOperationStream runs a copy, move, delete or shred operation and streams its progress
+// as Server-Sent Events. "progress" events carry a ProgressEvent and the stream ends with
+// a single "result" event carrying the APIResponse.
+func (h *Handler) HandleOperationStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-	for _, path := range req.Paths {
-		result := ffi.CreateFolder(path)
-		if result.Success {
-			successCount++
-		} else {
-			errorCount++
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req APIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request format", codeInvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := copyOptions(req)
+	if err != nil {
+		respondError(w, err.Error(), codeInvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	if h.isBulk(req, append([]string{req.Source}, req.Paths...)...) {
+		respondError(w, "Glob patterns and filters are only supported by /api/operation", codeInvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	switch req.Operation {
+	case "copy", "move":
+	case "delete":
+		if len(req.Paths) == 0 {
+			respondError(w, "No path provided", codeInvalidRequest, http.StatusBadRequest)
+			return
+		}
+	case "shred":
+		if len(req.Paths) == 0 {
+			respondError(w, "No path provided", codeInvalidRequest, http.StatusBadRequest)
+			return
 		}
+		if _, err := shredOptions(req); err != nil {
+			respondError(w, err.Error(), codeInvalidRequest, http.StatusBadRequest)
+			return
+		}
+	default:
+		respondError(w, "Operation does not support progress streaming", codeUnknownOperation, http.StatusBadRequest)
+		return
 	}
 
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, "Streaming not supported", codeInvalidRequest, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	var lastSent time.Time
+	progress := func(p ffi.Progress) {
+		if !p.Done() && time.Since(lastSent) < progressInterval {
+			return
+		}
+		lastSent = time.Now()
+
+		writeEvent(w, "progress", ProgressEvent{
+			FilesDone:   p.FilesDone,
+			FilesTotal:  p.FilesTotal,
+			BytesDone:   p.BytesDone,
+			BytesTotal:  p.BytesTotal,
+			CurrentPath: p.CurrentPath,
+			Percent:     p.Percent(),
+		})
+		flusher.Flush()
+	}
+
+	ctx, end := h.begin(r.Context())
+	var result ffi.Result
+	switch req.Operation {
+	case "copy":
+		result = h.backend.CopyPath(ctx, req.Source, req.Dest, opts, progress)
+	case "move":
+		result = h.backend.MovePath(ctx, req.Source, req.Dest, opts, progress)
+	case "delete":
+		result = h.delete(ctx, req.Paths[0], req.Permanent, progress)
+	case "shred":
+		opts, _ := shredOptions(req)
+		result = h.backend.DeletePath(ctx, req.Paths[0], opts, progress)
+	}
+	end()
+
+	writeEvent(w, "result", transferResponse(result))
+	flusher.Flush()
+}
+
+// writeEvent writes a single Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+func (h *Handler) handleCreateFolderAPI(ctx context.Context, req APIRequest) APIResponse {
+	opts, err := createOptions(req, true)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+
+	var response APIResponse
+	for _, path := range req.Paths {
+		recordResult(&response, path, h.backend.CreateFolder(ctx, path, opts))
+	}
+
+	successCount := response.Count.Success
+	errorCount := response.Count.Failed
 	response.Success = errorCount == 0
-	response.Count.Success = successCount
-	response.Count.Failed = errorCount
 
 	if errorCount == 0 {
 		response.Message = fmt.Sprintf("Successfully created %d folder(s)", successCount)
@@ -128,29 +547,30 @@ func handleCreateFolderAPI(req APIRequest) APIResponse {
 	return response
 }
 
-func handleCreateFileAPI(req APIRequest) APIResponse {
-	var response APIResponse
-	successCount := 0
-	errorCount := 0
+// handleCreateFileAPI creates files with their missing parents unless parents is false
+// A parent that cannot be created fails the files in it.
+func (h *Handler) handleCreateFileAPI(ctx context.Context, req APIRequest) APIResponse {
+	opts, err := createOptions(req, false)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+	content, err := fileContent(req)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
 
+	var response APIResponse
 	for _, path := range req.Paths {
-		// Create parent directories if needed
-		dir := filepath.Dir(path)
-		if dir != "." && dir != path {
-			ffi.CreateFolder(dir)
-		}
-
-		result := ffi.CreateFile(path)
-		if result.Success {
-			successCount++
+		if content == nil {
+			recordResult(&response, path, h.backend.CreateFile(ctx, path, opts))
 		} else {
-			errorCount++
+			recordResult(&response, path, ffi.CreateFileWithContent(ctx, h.backend, path, content, opts))
 		}
 	}
 
+	successCount := response.Count.Success
+	errorCount := response.Count.Failed
 	response.Success = errorCount == 0
-	response.Count.Success = successCount
-	response.Count.Failed = errorCount
 
 	if errorCount == 0 {
 		response.Message = fmt.Sprintf("Successfully created %d file(s)", successCount)
@@ -161,70 +581,450 @@ func handleCreateFileAPI(req APIRequest) APIResponse {
 	return response
 }
 
-func handleRenameAPI(req APIRequest) APIResponse {
-	var response APIResponse
-	result := ffi.RenamePath(req.OldPath, req.NewPath)
+// createOptions returns the creation options of a createFolder or createFile request
+func createOptions(req APIRequest, isDir bool) (ffi.CreateOptions, error) {
+	opts := ffi.CreateOptions{Exclusive: req.Exclusive, Parents: req.Parents == nil || *req.Parents}
+	if req.Mode != "" {
+		mode, err := ffi.ParseNewMode(req.Mode, isDir)
+		if err != nil {
+			return opts, err
+		}
+		opts.Mode = mode
+	}
+	if req.Owner != "" {
+		owner, err := ffi.ParseOwnerSpec(req.Owner)
+		if err != nil {
+			return opts, err
+		}
+		opts.Owner = owner
+	}
+	return opts, nil
+}
+
+// fileContent returns the content a createFile request writes, or nil for empty files
+func fileContent(req APIRequest) ([]byte, error) {
+	switch strings.ToLower(req.Encoding) {
+	case "", "text":
+		if req.Content == "" {
+			return nil, nil
+		}
+		return []byte(req.Content), nil
+	case "base64":
+		content, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+		return content, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q (expected text or base64)", req.Encoding)
+	}
+}
+
+func (h *Handler) handleRenameAPI(ctx context.Context, req APIRequest) APIResponse {
+	return resultResponse(h.backend.RenamePath(ctx, req.OldPath, req.NewPath))
+}
+
+// handleBulkRenameAPI renames every entry the paths, patterns and filters of a request
+// select by its rule
+// Every rename is listed in "renames"; a preview or a conflict stops there without
+// renaming anything.
+func (h *Handler) handleBulkRenameAPI(ctx context.Context, req APIRequest) APIResponse {
+	if len(req.Paths) == 0 {
+		return APIResponse{Success: false, Message: "No path provided", Code: codeInvalidRequest}
+	}
+	opts, err := h.matchOptions(req, req.Paths)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+	matches, err := h.files.GlobPaths(ctx, req.Paths, opts)
+	if err != nil {
+		return APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to match entries below '%s': %v", strings.Join(req.Paths, "', '"), err),
+			Code:    ffi.KindOf(err).Code(),
+		}
+	}
+
+	rule := service.RenameRule{Find: req.Find, Replace: req.Replace, Case: req.Case, Slugify: req.Slugify, Start: req.Start}
+	plan, err := h.files.PlanRenames(matches, rule)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+
+	response := APIResponse{Renames: make([]RenameResponse, len(plan.Renames))}
+	for i, rename := range plan.Renames {
+		response.Renames[i] = RenameResponse{OldPath: rename.Old, NewPath: rename.New, Conflict: rename.Conflict}
+	}
+	if plan.Conflicts > 0 {
+		response.Success = false
+		response.Message = fmt.Sprintf("%d of %d renames have conflicts", plan.Conflicts, len(plan.Renames))
+		response.Code = ffi.ErrorKindAlreadyExists.Code()
+		return response
+	}
+	if req.Preview {
+		response.Success = true
+		response.Message = fmt.Sprintf("%d of %d matching entries would be renamed", len(plan.Renames), len(matches))
+		return response
+	}
 
+	result := h.files.ApplyRenames(ctx, plan)
 	response.Success = result.Success
 	response.Message = result.Message
-
+	response.Code = result.Kind.Code()
 	return response
 }
 
-func handleDeleteAPI(req APIRequest) APIResponse {
+// handleHistoryAPI undoes the most recent entry of the history or redoes the
+// earliest undone one
+func (h *Handler) handleHistoryAPI(ctx context.Context, req APIRequest) APIResponse {
+	if h.history == nil {
+		return APIResponse{Success: false, Message: "The server does not record a history", Code: codeInvalidRequest}
+	}
+	if req.Operation == "undo" {
+		return resultResponse(h.history.Undo(ctx))
+	}
+	return resultResponse(h.history.Redo(ctx))
+}
+
+func (h *Handler) handleDeleteAPI(ctx context.Context, req APIRequest) APIResponse {
 	var response APIResponse
+	if len(req.Paths) > 0 && h.isBulk(req, req.Paths...) {
+		return h.handleBulkAPI(ctx, req, req.Paths)
+	}
 
 	if len(req.Paths) > 0 {
-		result := ffi.DeletePath(req.Paths[0])
-		response.Success = result.Success
-		response.Message = result.Message
+		response = resultResponse(h.delete(ctx, req.Paths[0], req.Permanent, nil))
 	} else {
 		response.Success = false
 		response.Message = "No path provided"
+		response.Code = codeInvalidRequest
 	}
 
 	return response
 }
 
-func handleChmodAPI(req APIRequest) APIResponse {
+// delete moves path to the trash, or removes it when permanent is set
+func (h *Handler) delete(ctx context.Context, path string, permanent bool, progress ffi.ProgressFunc) ffi.Result {
+	if permanent {
+		return h.backend.DeletePath(ctx, path, ffi.DeleteOptions{}, progress)
+	}
+	return h.trash.Put(ctx, path, progress)
+}
+
+// handleShredAPI overwrites and deletes every path of the request
+// Files left in place on copy-on-write filesystems are listed as skipped items.
+func (h *Handler) handleShredAPI(ctx context.Context, req APIRequest) APIResponse {
 	var response APIResponse
 
-	if len(req.Paths) > 0 && req.Mode != "" {
-		var mode uint32
-		fmt.Sscanf(req.Mode, "%o", &mode)
+	opts, err := shredOptions(req)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+	if len(req.Paths) == 0 {
+		return APIResponse{Success: false, Message: "No path provided", Code: codeInvalidRequest}
+	}
+
+	var skipped []ItemResult
+	for _, path := range req.Paths {
+		result := h.backend.DeletePath(ctx, path, opts, nil)
+		recordResult(&response, path, result)
+		skipped = append(skipped, skippedItems(result)...)
+	}
+	response.Results = append(response.Results, skipped...)
+
+	successCount := response.Count.Success
+	errorCount := response.Count.Failed
+	response.Success = errorCount == 0
 
-		result := ffi.ChangePermissions(req.Paths[0], mode)
-		response.Success = result.Success
-		response.Message = result.Message
+	if errorCount == 0 {
+		response.Message = fmt.Sprintf("Successfully shredded %d item(s)", successCount)
 	} else {
-		response.Success = false
-		response.Message = "Missing path or mode"
+		response.Message = fmt.Sprintf("Shredded %d item(s), %d failed", successCount, errorCount)
+	}
+	if len(skipped) > 0 {
+		response.Message += fmt.Sprintf("; %d file(s) on copy-on-write filesystems were left in place", len(skipped))
 	}
 
 	return response
 }
 
-func handleMoveAPI(req APIRequest) APIResponse {
+// shredOptions builds the delete options of a shred request, which must be confirmed
+func shredOptions(req APIRequest) (ffi.DeleteOptions, error) {
+	if req.Confirm != shredConfirmation {
+		return ffi.DeleteOptions{}, fmt.Errorf("shred overwrites files so they cannot be recovered; set \"confirm\": %q to proceed", shredConfirmation)
+	}
+	passes := req.Passes
+	if passes == 0 {
+		passes = ffi.DefaultShredPasses
+	}
+	if passes < 0 || passes > maxShredPasses {
+		return ffi.DeleteOptions{}, fmt.Errorf("passes must be between 1 and %d", maxShredPasses)
+	}
+	return ffi.DeleteOptions{ShredPasses: passes, VerifyShred: req.VerifyShred}, nil
+}
+
+func (h *Handler) handleRestoreAPI(ctx context.Context, req APIRequest) APIResponse {
 	var response APIResponse
-	result := ffi.MovePath(req.Source, req.Dest)
 
-	response.Success = result.Success
-	response.Message = result.Message
+	for _, path := range req.Paths {
+		recordResult(&response, path, h.trash.Restore(ctx, path))
+	}
+
+	successCount := response.Count.Success
+	errorCount := response.Count.Failed
+	response.Success = errorCount == 0
+
+	if len(req.Paths) == 0 {
+		response.Message = "No path provided"
+		response.Code = codeInvalidRequest
+	} else if errorCount == 0 {
+		response.Message = fmt.Sprintf("Successfully restored %d item(s)", successCount)
+	} else {
+		response.Message = fmt.Sprintf("Restored %d item(s), %d failed", successCount, errorCount)
+	}
 
 	return response
 }
 
-func handleCopyAPI(req APIRequest) APIResponse {
+func (h *Handler) handleChmodAPI(ctx context.Context, req APIRequest) APIResponse {
 	var response APIResponse
-	result := ffi.CopyPath(req.Source, req.Dest)
+	if len(req.Paths) > 0 && h.isBulk(req, req.Paths...) {
+		return h.handleBulkAPI(ctx, req, req.Paths)
+	}
 
-	response.Success = result.Success
-	response.Message = result.Message
+	if len(req.Paths) > 0 && (req.Mode != "" || req.DirMode != "") {
+		opts, err := chmodOptions(req)
+		if err != nil {
+			return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+		}
 
+		result := ffi.ChangeModes(ctx, h.backend, req.Paths[0], opts)
+		response = resultResponse(result)
+		for _, change := range result.ModeChanges {
+			response.Modes = append(response.Modes, ModeChangeResponse{
+				Path:           change.Path,
+				IsDir:          change.IsDir,
+				Before:         fmt.Sprintf("%04o", change.Old),
+				After:          fmt.Sprintf("%04o", change.New),
+				BeforeSymbolic: ffi.ModeString(change.Old),
+				AfterSymbolic:  ffi.ModeString(change.New),
+			})
+		}
+		response.Results = append(response.Results, skippedItems(result)...)
+	} else {
+		response.Success = false
+		response.Message = "Missing path or mode"
+		response.Code = codeInvalidRequest
+	}
+
+	return response
+}
+
+// chmodOptions builds the chmod options of a request
+// Mode applies to folders too unless DirMode is given.
+func chmodOptions(req APIRequest) (ffi.ChmodOptions, error) {
+	opts := ffi.ChmodOptions{Recursive: req.Recursive, Preview: req.Preview}
+	var err error
+	if req.Mode != "" {
+		if opts.FileMode, err = ffi.ParseModeSpec(req.Mode); err != nil {
+			return opts, err
+		}
+	}
+	opts.DirMode = opts.FileMode
+	if req.DirMode != "" {
+		if opts.DirMode, err = ffi.ParseModeSpec(req.DirMode); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+func (h *Handler) handleChownAPI(ctx context.Context, req APIRequest) APIResponse {
+	if len(req.Paths) == 0 || req.Owner == "" {
+		return APIResponse{Success: false, Message: "Missing path or owner", Code: codeInvalidRequest}
+	}
+
+	owner, err := ffi.ParseOwnerSpec(req.Owner)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+	opts := ffi.ChownOptions{Owner: owner, Recursive: req.Recursive, NoDereference: req.NoDereference}
+	return resultResponse(ffi.ChangeOwners(ctx, h.backend, req.Paths[0], opts))
+}
+
+// isBulk reports whether a request selects entries with glob patterns or filters
+// instead of naming them
+func (h *Handler) isBulk(req APIRequest, paths ...string) bool {
+	if len(req.Include) > 0 || len(req.Exclude) > 0 ||
+		req.MinSize != "" || req.MaxSize != "" || req.OlderThan != "" || req.NewerThan != "" {
+		return true
+	}
+	for _, path := range paths {
+		if h.files.IsGlob(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOptions builds the glob and filter options of a request, checking the
+// patterns of paths too
+func (h *Handler) matchOptions(req APIRequest, paths []string) (service.MatchOptions, error) {
+	opts := service.MatchOptions{Include: req.Include, Exclude: req.Exclude}
+	patterns := append(append([]string{}, req.Include...), req.Exclude...)
+	for _, path := range paths {
+		if h.files.IsGlob(path) {
+			_, pattern := service.SplitGlob(path)
+			patterns = append(patterns, pattern)
+		}
+	}
+	for _, pattern := range patterns {
+		if err := service.ValidatePattern(pattern); err != nil {
+			return opts, err
+		}
+	}
+
+	var err error
+	for _, size := range []struct {
+		text  string
+		value *int64
+	}{{req.MinSize, &opts.MinSize}, {req.MaxSize, &opts.MaxSize}} {
+		if size.text != "" {
+			if *size.value, err = service.ParseSize(size.text); err != nil {
+				return opts, err
+			}
+		}
+	}
+	for _, age := range []struct {
+		text  string
+		value *time.Duration
+	}{{req.OlderThan, &opts.OlderThan}, {req.NewerThan, &opts.NewerThan}} {
+		if age.text != "" {
+			if *age.value, err = service.ParseAge(age.text); err != nil {
+				return opts, err
+			}
+		}
+	}
+	return opts, nil
+}
+
+// handleBulkAPI applies a delete, chmod, copy or move request to every entry its
+// patterns and filters select below paths
+// Every match is listed in "matches"; a preview stops there without changing anything.
+func (h *Handler) handleBulkAPI(ctx context.Context, req APIRequest, paths []string) APIResponse {
+	opts, err := h.matchOptions(req, paths)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+
+	var op func(context.Context, service.Match) ffi.Result
+	switch req.Operation {
+	case "delete":
+		op = func(ctx context.Context, match service.Match) ffi.Result {
+			return h.delete(ctx, match.Path, req.Permanent, nil)
+		}
+	case "chmod":
+		if req.Mode == "" && req.DirMode == "" {
+			return APIResponse{Success: false, Message: "Missing mode", Code: codeInvalidRequest}
+		}
+		chmod, err := chmodOptions(req)
+		if err != nil {
+			return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+		}
+		op = func(ctx context.Context, match service.Match) ffi.Result {
+			return ffi.ChangeModes(ctx, h.backend, match.Path, chmod)
+		}
+	case "copy", "move":
+		if req.Dest == "" {
+			return APIResponse{Success: false, Message: "Missing destination", Code: codeInvalidRequest}
+		}
+	}
+
+	matches, err := h.files.GlobPaths(ctx, paths, opts)
+	if err != nil {
+		return APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to match entries below '%s': %v", strings.Join(paths, "', '"), err),
+			Code:    ffi.KindOf(err).Code(),
+		}
+	}
+
+	response := APIResponse{Matches: make([]string, len(matches))}
+	for i, match := range matches {
+		response.Matches[i] = match.Path
+	}
+	if req.Preview {
+		response.Success = true
+		response.Message = fmt.Sprintf("%d entries match", len(matches))
+		return response
+	}
+
+	var results []ffi.Result
+	if op != nil {
+		results = h.files.ApplyMatches(ctx, matches, op)
+	} else {
+		copyOpts, _ := copyOptions(req)
+		results = h.files.TransferMatches(ctx, matches, req.Dest, req.Operation == "move", copyOpts)
+	}
+	for i, result := range results {
+		recordResult(&response, matches[i].Path, result)
+	}
+
+	verb := map[string]string{
+		"delete": "Deleted",
+		"chmod":  "Changed permissions of",
+		"copy":   "Copied",
+		"move":   "Moved",
+	}[req.Operation]
+	response.Success = response.Count.Failed == 0
+	if response.Success {
+		response.Message = fmt.Sprintf("%s %d matching entries", verb, response.Count.Success)
+	} else {
+		response.Message = fmt.Sprintf("%s %d matching entries, %d failed", verb, response.Count.Success, response.Count.Failed)
+	}
 	return response
 }
 
-func handleCreateTemplateAPI(req APIRequest) APIResponse {
+func (h *Handler) handleMoveAPI(ctx context.Context, req APIRequest) APIResponse {
+	opts, err := copyOptions(req)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+	if h.isBulk(req, req.Source) {
+		return h.handleBulkAPI(ctx, req, []string{req.Source})
+	}
+	return transferResponse(h.backend.MovePath(ctx, req.Source, req.Dest, opts, nil))
+}
+
+func (h *Handler) handleCopyAPI(ctx context.Context, req APIRequest) APIResponse {
+	opts, err := copyOptions(req)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+	if h.isBulk(req, req.Source) {
+		return h.handleBulkAPI(ctx, req, []string{req.Source})
+	}
+	return transferResponse(h.backend.CopyPath(ctx, req.Source, req.Dest, opts, nil))
+}
+
+// copyOptions builds the copy and move options of a request
+func copyOptions(req APIRequest) (ffi.CopyOptions, error) {
+	conflict, err := ffi.ParseConflictPolicy(req.Conflict)
+	if err != nil {
+		return ffi.CopyOptions{}, err
+	}
+	symlinks, err := ffi.ParseSymlinkPolicy(req.Symlinks)
+	if err != nil {
+		return ffi.CopyOptions{}, err
+	}
+	verify, err := ffi.ParseChecksumAlgorithm(req.Verify)
+	if err != nil {
+		return ffi.CopyOptions{}, err
+	}
+	return ffi.CopyOptions{Conflict: conflict, Symlinks: symlinks, PreserveMetadata: req.Archive, Verify: verify}, nil
+}
+
+func (h *Handler) handleCreateTemplateAPI(ctx context.Context, req APIRequest) APIResponse {
 	var response APIResponse
 
 	templates := service.GetAvailableTemplates()
@@ -240,14 +1040,18 @@ func handleCreateTemplateAPI(req APIRequest) APIResponse {
 	if selectedTemplate == nil {
 		response.Success = false
 		response.Message = "Template not found"
+		response.Code = ffi.ErrorKindNotFound.Code()
 		return response
 	}
 
-	successCount, errorCount := service.CreateFromTemplate(req.RootDir, *selectedTemplate)
+	successCount, errorCount, kind := h.files.CreateFromTemplate(ctx, req.RootDir, *selectedTemplate, req.Transactional)
 
 	response.Success = errorCount == 0
 	response.Count.Success = successCount
 	response.Count.Failed = errorCount
+	if errorCount > 0 {
+		response.Code = failureCode(kind)
+	}
 
 	if errorCount == 0 {
 		response.Message = fmt.Sprintf("Successfully created %s structure with %d items", selectedTemplate.Name, successCount)
@@ -258,12 +1062,21 @@ func handleCreateTemplateAPI(req APIRequest) APIResponse {
 	return response
 }
 
-func handleCreateCustomAPI(req APIRequest) APIResponse {
+func (h *Handler) handleCreateCustomAPI(ctx context.Context, req APIRequest) APIResponse {
 	var response APIResponse
 
+	// Folders and files take the mode, owner and create flags of the request, as
+	// they do in createFolder and createFile
+	folderOpts, err := createOptions(req, true)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+	fileOpts, err := createOptions(req, false)
+	if err != nil {
+		return APIResponse{Success: false, Message: err.Error(), Code: codeInvalidRequest}
+	}
+
 	lines := strings.Split(req.Structure, "\n")
-	successCount := 0
-	errorCount := 0
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -271,27 +1084,18 @@ func handleCreateCustomAPI(req APIRequest) APIResponse {
 			continue
 		}
 
-		var result ffi.Result
 		if strings.HasPrefix(line, "d:") {
 			path := strings.TrimPrefix(line, "d:")
-			result = ffi.CreateFolder(path)
+			recordResult(&response, path, h.backend.CreateFolder(ctx, path, folderOpts))
 		} else if strings.HasPrefix(line, "f:") {
 			path := strings.TrimPrefix(line, "f:")
-			result = ffi.CreateFile(path)
-		} else {
-			continue
-		}
-
-		if result.Success {
-			successCount++
-		} else {
-			errorCount++
+			recordResult(&response, path, h.backend.CreateFile(ctx, path, fileOpts))
 		}
 	}
 
+	successCount := response.Count.Success
+	errorCount := response.Count.Failed
 	response.Success = errorCount == 0
-	response.Count.Success = successCount
-	response.Count.Failed = errorCount
 
 	if errorCount == 0 {
 		response.Message = fmt.Sprintf("Successfully created %d items", successCount)
@@ -302,19 +1106,17 @@ func handleCreateCustomAPI(req APIRequest) APIResponse {
 	return response
 }
 
-func handleCreateTreeAPI(req APIRequest) APIResponse {
+func (h *Handler) handleCreateTreeAPI(ctx context.Context, req APIRequest) APIResponse {
 	var response APIResponse
 
 	dirs, files, err := service.ParseTreeStructure(req.Structure)
 	if err != nil {
 		response.Success = false
 		response.Message = fmt.Sprintf("Error parsing structure: %v", err)
+		response.Code = codeInvalidRequest
 		return response
 	}
 
-	successCount := 0
-	errorCount := 0
-
 	// Sort directories by depth
 	sort.Slice(dirs, func(i, j int) bool {
 		depthI := strings.Count(dirs[i], "/")
@@ -325,29 +1127,33 @@ func handleCreateTreeAPI(req APIRequest) APIResponse {
 		return dirs[i] < dirs[j]
 	})
 
-	// Create directories
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	// The batch creates every directory before the entries inside it
+	batch := h.newBatch(req)
 	for _, dir := range dirs {
-		result := ffi.CreateFolder(dir)
-		if result.Success {
-			successCount++
-		} else {
-			errorCount++
-		}
+		batch.AddCreateFolder(dir)
+	}
+	for _, filePath := range filePaths {
+		batch.AddCreateFile(filePath)
 	}
 
-	// Create files
-	for filePath := range files {
-		result := ffi.CreateFile(filePath)
-		if result.Success {
-			successCount++
-		} else {
-			errorCount++
-		}
+	paths := append(dirs, filePaths...)
+	for i, result := range batch.ExecuteContext(ctx) {
+		recordResult(&response, paths[i], result)
+	}
+	if response.Count.Failed > 0 {
+		// The failure that stopped the batch, not the entries rolled back for it
+		response.Code = failureCode(batch.FailureKind())
 	}
 
+	successCount := response.Count.Success
+	errorCount := response.Count.Failed
 	response.Success = errorCount == 0
-	response.Count.Success = successCount
-	response.Count.Failed = errorCount
 
 	if errorCount == 0 {
 		response.Message = fmt.Sprintf("Successfully created %d items", successCount)
@@ -358,6 +1164,24 @@ func handleCreateTreeAPI(req APIRequest) APIResponse {
 	return response
 }
 
+// newBatch creates a batch configured from the request
+// Parallelism defaults to the number of CPUs
+func (h *Handler) newBatch(req APIRequest) *ffi.BatchOperation {
+	batch := ffi.NewBatchOperation(h.backend)
+
+	parallelism := req.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	batch.SetParallelism(parallelism)
+
+	if req.FailFast {
+		batch.SetErrorPolicy(ffi.FailFast)
+	}
+	batch.SetTransactional(req.Transactional)
+	return batch
+}
+
 // HandleTemplates returns available templates
 func HandleTemplates(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -375,19 +1199,344 @@ func HandleTemplates(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(templateInfos)
 }
 
+// HandleHistory lists the operations of the history, most recent first
+// A server that does not record a history lists none.
+func (h *Handler) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	history := []HistoryEntry{}
+	if h.history != nil {
+		entries, err := h.history.History()
+		if err != nil {
+			respondError(w, err.Error(), ffi.KindOf(err).Code(), http.StatusInternalServerError)
+			return
+		}
+		for _, entry := range entries {
+			item := HistoryEntry{
+				ID:     entry.ID,
+				Time:   entry.Time,
+				Title:  entry.Title,
+				Undone: entry.Undone,
+				Steps:  make([]HistoryStep, len(entry.Steps)),
+				Notes:  entry.Notes,
+			}
+			for i, step := range entry.Steps {
+				item.Steps[i] = HistoryStep{Op: step.Op, Path: step.Path, Dest: step.Dest}
+			}
+			history = append(history, item)
+		}
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
+
+// HandleTrash lists the entries of the trash, most recently deleted first
+func (h *Handler) HandleTrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	items, err := h.trash.List()
+	if err != nil {
+		respondError(w, err.Error(), ffi.KindOf(err).Code(), http.StatusInternalServerError)
+		return
+	}
+
+	trashItems := make([]TrashItem, len(items))
+	for i, item := range items {
+		trashItems[i] = TrashItem{
+			Path:         item.Path,
+			OriginalPath: item.OriginalPath,
+			DeletionDate: item.DeletionDate,
+			IsDir:        item.IsDir,
+			Size:         item.Size,
+		}
+	}
+
+	json.NewEncoder(w).Encode(trashItems)
+}
+
+// HandleList lists the entries of the folder named by the path query parameter
+// sort (name, size, modified or type), order (asc or desc), dirsFirst (default true),
+// hidden, offset and limit select and page the entries.
+func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	path := query.Get("path")
+	if path == "" {
+		respondError(w, "Missing path", codeInvalidRequest, http.StatusBadRequest)
+		return
+	}
+	opts, err := listOptions(query)
+	if err != nil {
+		respondError(w, err.Error(), codeInvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	listing, err := ffi.ListDir(r.Context(), h.backend, path, opts)
+	if err != nil {
+		code := ffi.KindOf(err).Code()
+		respondError(w, fmt.Sprintf("Failed to list '%s': %v", path, err), code, statusForCode(code))
+		return
+	}
+
+	response := ListResponse{
+		Path:    listing.Path,
+		Entries: make([]EntryResponse, len(listing.Entries)),
+		Total:   listing.Total,
+		Offset:  opts.Offset,
+	}
+	for i, entry := range listing.Entries {
+		response.Entries[i] = entryResponse(entry)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// listOptions parses the query parameters of HandleList
+func listOptions(query url.Values) (ffi.ListOptions, error) {
+	opts := ffi.ListOptions{SortBy: query.Get("sort"), DirsFirst: true}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("invalid order %q (expected asc or desc)", query.Get("order"))
+	}
+
+	for name, value := range map[string]*bool{"dirsFirst": &opts.DirsFirst, "hidden": &opts.ShowHidden} {
+		if text := query.Get(name); text != "" {
+			b, err := strconv.ParseBool(text)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s %q", name, text)
+			}
+			*value = b
+		}
+	}
+
+	for name, value := range map[string]*int{"offset": &opts.Offset, "limit": &opts.Limit} {
+		if text := query.Get(name); text != "" {
+			n, err := strconv.Atoi(text)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("invalid %s %q", name, text)
+			}
+			*value = n
+		}
+	}
+	return opts, nil
+}
+
+// HandleStat describes the entry named by the path query parameter without following a link
+func (h *Handler) HandleStat(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		respondError(w, "Missing path", codeInvalidRequest, http.StatusBadRequest)
+		return
+	}
+
+	entry, err := ffi.Stat(h.backend, path)
+	if err != nil {
+		code := ffi.KindOf(err).Code()
+		respondError(w, fmt.Sprintf("Failed to access path '%s': %v", path, err), code, statusForCode(code))
+		return
+	}
+	json.NewEncoder(w).Encode(entryResponse(entry))
+}
+
+// entryResponse converts an ffi.EntryInfo
+func entryResponse(entry ffi.EntryInfo) EntryResponse {
+	return EntryResponse{
+		Name:         entry.Name,
+		Path:         entry.Path,
+		Type:         entry.Type,
+		Size:         entry.Size,
+		Mode:         fmt.Sprintf("%04o", entry.Mode),
+		ModeSymbolic: ffi.ModeString(entry.Mode),
+		UID:          entry.UID,
+		GID:          entry.GID,
+		Owner:        entry.Owner,
+		Group:        entry.Group,
+		Modified:     entry.ModTime,
+		Accessed:     entry.AccessTime,
+		LinkTarget:   entry.LinkTarget,
+	}
+}
+
 // HandleHealth is a health check endpoint
-func HandleHealth(w http.ResponseWriter, r *http.Request) {
+// It reports the backend so clients can tell which implementation serves them
+func (h *Handler) HandleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "healthy",
 		"version": version.GetVersion(),
+		"backend": h.backend.Name(),
 	})
 }
 
-func respondError(w http.ResponseWriter, message string, statusCode int) {
+// resultResponse builds an APIResponse from a single ffi.Result
+func resultResponse(result ffi.Result) APIResponse {
+	response := APIResponse{Success: result.Success, Message: result.Message}
+	if !result.Success {
+		response.Code = failureCode(result.Kind)
+	}
+	return response
+}
+
+// failureCode returns the response code of a failure of kind, which is IO_ERROR
+// for failures that did not classify themselves
+func failureCode(kind ffi.ErrorKind) string {
+	if kind == ffi.ErrorKindNone {
+		return ffi.ErrorKindIO.Code()
+	}
+	return kind.Code()
+}
+
+// transferResponse converts the result of a copy or move, listing every
+// destination conflict as an item so clients can see what was skipped or renamed,
+// followed by a failed item for every file that failed verification
+func transferResponse(result ffi.Result) APIResponse {
+	response := resultResponse(result)
+	response.MovedByCopy = result.MovedByCopy
+	response.Strategy = result.Strategy.String()
+	for _, conflict := range result.Conflicts {
+		response.Results = append(response.Results, ItemResult{
+			Path:    conflict.Dest,
+			Success: true,
+			Message: fmt.Sprintf("%s: %s", conflict.Action, conflict.Source),
+			Action:  conflict.Action.String(),
+		})
+	}
+	for _, mismatch := range result.Mismatches {
+		response.Results = append(response.Results, ItemResult{
+			Path:    mismatch.Dest,
+			Success: false,
+			Message: fmt.Sprintf("%s: %s", mismatch.Source, mismatch.Reason),
+			Code:    ffi.ErrorKindChecksumMismatch.Code(),
+		})
+	}
+	response.Results = append(response.Results, skippedItems(result)...)
+	return response
+}
+
+// skippedItems lists the entries an operation deliberately left alone, such as the
+// files shred could not overwrite, as skipped items
+func skippedItems(result ffi.Result) []ItemResult {
+	items := make([]ItemResult, 0, len(result.Skipped))
+	for _, skipped := range result.Skipped {
+		items = append(items, ItemResult{
+			Path:    skipped.Path,
+			Success: true,
+			Message: skipped.Reason,
+			Action:  ffi.ConflictSkipped.String(),
+		})
+	}
+	return items
+}
+
+// planResponse converts the plan of a dry run to its API representation
+func planResponse(plan ffi.Plan) *PlanResponse {
+	response := &PlanResponse{
+		Actions:   make([]PlanAction, 0, len(plan.Actions)),
+		Existing:  append([]string{}, plan.Existing...),
+		Conflicts: make([]PlanConflict, 0, len(plan.Conflicts)),
+	}
+	for _, action := range plan.Actions {
+		item := PlanAction{Op: action.Op, Path: action.Path, Dest: action.Dest, Size: action.Size}
+		if action.Op == ffi.ActionChmod {
+			item.Mode = fmt.Sprintf("%04o", action.Mode)
+		}
+		if action.Op == ffi.ActionChown {
+			item.Owner = action.Owner
+		}
+		response.Actions = append(response.Actions, item)
+	}
+	for _, conflict := range plan.Conflicts {
+		response.Conflicts = append(response.Conflicts, PlanConflict{
+			Source: conflict.Source,
+			Dest:   conflict.Dest,
+			Action: conflict.Action.String(),
+		})
+	}
+	return response
+}
+
+// recordResult appends a per-path result to the response and updates the counts
+// The first failure determines the response code
+func recordResult(response *APIResponse, path string, result ffi.Result) {
+	item := ItemResult{Path: path, Success: result.Success, Message: result.Message}
+	if !result.Success {
+		item.Code = failureCode(result.Kind)
+	}
+	response.Results = append(response.Results, item)
+
+	if result.Success {
+		response.Count.Success++
+		return
+	}
+
+	response.Count.Failed++
+	if response.Code == "" {
+		response.Code = item.Code
+	}
+}
+
+// statusForResponse returns the HTTP status code for an operation response
+// Partial failures of multi-path operations are reported as 207 Multi-Status
+func statusForResponse(response APIResponse) int {
+	if response.Success {
+		return http.StatusOK
+	}
+	if response.Count.Success > 0 && response.Count.Failed > 0 {
+		return http.StatusMultiStatus
+	}
+	return statusForCode(response.Code)
+}
+
+// statusForCode maps the code of a failed response to an HTTP status code
+// A failure without a code is an internal error.
+func statusForCode(code string) int {
+	switch code {
+	case codeInvalidRequest, codeUnknownOperation, ffi.ErrorKindInvalidPath.Code():
+		return http.StatusBadRequest
+	case ffi.ErrorKindNotFound.Code():
+		return http.StatusNotFound
+	case ffi.ErrorKindPermissionDenied.Code():
+		return http.StatusForbidden
+	case ffi.ErrorKindAlreadyExists.Code(), ffi.ErrorKindNotEmpty.Code():
+		return http.StatusConflict
+	case ffi.ErrorKindCrossDevice.Code():
+		return http.StatusUnprocessableEntity
+	case ffi.ErrorKindCancelled.Code():
+		return statusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func respondError(w http.ResponseWriter, message string, code string, statusCode int) {
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(APIResponse{
 		Success: false,
 		Message: message,
+		Code:    code,
 	})
 }
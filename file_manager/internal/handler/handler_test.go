@@ -0,0 +1,885 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"filemanager/internal/ffi"
+	"filemanager/internal/journal"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testHandler returns a Handler on the backend selected by FILEMANAGER_BACKEND,
+// so the tests can be run against every implementation
+func testHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	backend, err := ffi.BackendFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(backend)
+}
+
+// doOperation posts an APIRequest body to HandleOperation and decodes the response
+func doOperation(t *testing.T, body string) (*httptest.ResponseRecorder, APIResponse) {
+	t.Helper()
+	return doHandlerOperation(t, testHandler(t), body)
+}
+
+// doHandlerOperation posts an APIRequest body to h and decodes the response
+func doHandlerOperation(t *testing.T, h *Handler, body string) (*httptest.ResponseRecorder, APIResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/api/operation", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.HandleOperation(w, req)
+
+	var response APIResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return w, response
+}
+
+// TestOperationErrorCodes verifies failures carry a stable code and HTTP status
+func TestOperationErrorCodes(t *testing.T) {
+	tmpDir := t.TempDir()
+	existing := filepath.Join(tmpDir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(tmpDir, "missing.txt")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{
+			name:   "rename missing source",
+			body:   `{"operation":"rename","oldPath":"` + missing + `","newPath":"` + existing + `.new"}`,
+			status: http.StatusNotFound,
+			code:   "NOT_FOUND",
+		},
+		{
+			name:   "delete without path",
+			body:   `{"operation":"delete"}`,
+			status: http.StatusBadRequest,
+			code:   "INVALID_REQUEST",
+		},
+		{
+			name:   "unknown operation",
+			body:   `{"operation":"explode"}`,
+			status: http.StatusBadRequest,
+			code:   "UNKNOWN_OPERATION",
+		},
+		{
+			name:   "successful rename",
+			body:   `{"operation":"rename","oldPath":"` + existing + `","newPath":"` + existing + `.new"}`,
+			status: http.StatusOK,
+			code:   "",
+		},
+	}
+
+	for _, test := range tests {
+		w, response := doOperation(t, test.body)
+
+		if w.Code != test.status {
+			t.Errorf("%s: Expected status %d, got %d", test.name, test.status, w.Code)
+		}
+		if response.Code != test.code {
+			t.Errorf("%s: Expected code %q, got %q", test.name, test.code, response.Code)
+		}
+	}
+}
+
+// TestPartialFailureStatus verifies mixed results are reported per item with 207
+func TestPartialFailureStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	blocker := filepath.Join(tmpDir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	good := filepath.Join(tmpDir, "good")
+	bad := filepath.Join(blocker, "child")
+	w, response := doOperation(t, `{"operation":"createFolder","paths":["`+good+`","`+bad+`"]}`)
+
+	if w.Code != http.StatusMultiStatus {
+		t.Errorf("Expected status 207, got %d", w.Code)
+	}
+	if len(response.Results) != 2 {
+		t.Fatalf("Expected 2 item results, got %d", len(response.Results))
+	}
+	if !response.Results[0].Success || response.Results[1].Success {
+		t.Errorf("Unexpected item results: %+v", response.Results)
+	}
+	if response.Results[1].Code == "" {
+		t.Error("Failed item has no code")
+	}
+}
+
+// TestOperationStream verifies progress events are streamed before the final result
+func TestOperationStream(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", filepath.Join("nested", "b.txt")} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst := filepath.Join(tmpDir, "dst")
+
+	body := `{"operation":"copy","source":"` + src + `","dest":"` + dst + `"}`
+	req := httptest.NewRequest("POST", "/api/operation/stream", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	testHandler(t).HandleOperationStream(w, req)
+
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected event stream, got %s", w.Header().Get("Content-Type"))
+	}
+
+	stream := w.Body.String()
+	progressAt := strings.Index(stream, "event: progress")
+	resultAt := strings.Index(stream, "event: result")
+	if progressAt == -1 || resultAt == -1 || progressAt > resultAt {
+		t.Fatalf("Unexpected event stream:\n%s", stream)
+	}
+	if !strings.Contains(stream, `"filesDone":2,"filesTotal":2`) {
+		t.Errorf("Final progress event missing:\n%s", stream)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "nested", "b.txt")); err != nil {
+		t.Errorf("Copied file missing: %v", err)
+	}
+}
+
+// TestCancelledOperation verifies an operation stops when the client has gone away
+func TestCancelledOperation(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tmpDir, "dst")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	body := `{"operation":"copy","source":"` + src + `","dest":"` + dst + `"}`
+	req := httptest.NewRequest("POST", "/api/operation", strings.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	testHandler(t).HandleOperation(w, req)
+
+	var response APIResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != statusClientClosedRequest {
+		t.Errorf("Expected status %d, got %d", statusClientClosedRequest, w.Code)
+	}
+	if response.Code != "CANCELLED" {
+		t.Errorf("Expected code CANCELLED, got %q", response.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); err == nil {
+		t.Error("File was copied despite cancellation")
+	}
+}
+
+// TestCopyConflictPolicies verifies the conflict field controls existing destinations
+func TestCopyConflictPolicies(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src.txt")
+	dst := filepath.Join(tmpDir, "dst.txt")
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body := func(conflict string) string {
+		return `{"operation":"copy","source":"` + src + `","dest":"` + dst + `","conflict":"` + conflict + `"}`
+	}
+
+	w, response := doOperation(t, body("fail"))
+	if w.Code != http.StatusConflict || response.Code != "ALREADY_EXISTS" {
+		t.Errorf("fail: Expected 409 ALREADY_EXISTS, got %d %q", w.Code, response.Code)
+	}
+
+	w, response = doOperation(t, body("skip"))
+	if w.Code != http.StatusOK || len(response.Results) != 1 || response.Results[0].Action != "skipped" {
+		t.Errorf("skip: Unexpected response %d %+v", w.Code, response)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "old" {
+		t.Errorf("skip: Destination was modified: %q", data)
+	}
+
+	w, response = doOperation(t, body("keep-both"))
+	renamed := filepath.Join(tmpDir, "dst (1).txt")
+	if w.Code != http.StatusOK || len(response.Results) != 1 || response.Results[0].Path != renamed {
+		t.Errorf("keep-both: Unexpected response %d %+v", w.Code, response)
+	}
+	if data, _ := os.ReadFile(renamed); string(data) != "new" {
+		t.Errorf("keep-both: Copy has content %q", data)
+	}
+
+	w, response = doOperation(t, body("sideways"))
+	if w.Code != http.StatusBadRequest || response.Code != "INVALID_REQUEST" {
+		t.Errorf("unknown policy: Expected 400 INVALID_REQUEST, got %d %q", w.Code, response.Code)
+	}
+}
+
+// TestCopySymlinkLoop verifies following symlinks terminates on a link farm with a loop
+func TestCopySymlinkLoop(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "pkg", "index.js"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(src, filepath.Join(src, "pkg", "self")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	dst := filepath.Join(tmpDir, "dst")
+
+	w, response := doOperation(t, `{"operation":"copy","source":"`+src+`","dest":"`+dst+`","symlinks":"follow"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, response.Message)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "pkg", "self")); !os.IsNotExist(err) {
+		t.Errorf("Looping symlink was copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "pkg", "index.js")); err != nil {
+		t.Errorf("Regular file missing: %v", err)
+	}
+}
+
+// TestCopyArchive verifies the archive flag preserves times and permission bits
+func TestCopyArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "report.txt")
+	if err := os.WriteFile(src, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(src, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tmpDir, "copy.txt")
+
+	w, response := doOperation(t, `{"operation":"copy","source":"`+src+`","dest":"`+dst+`","archive":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, response.Message)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modified) {
+		t.Errorf("Expected modification time %v, got %v", modified, info.ModTime())
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+// TestCreateTreeInMemory verifies createTree on the in-memory backend, without touching the disk
+func TestCreateTreeInMemory(t *testing.T) {
+	backend := ffi.NewMemoryBackend()
+	structure := "app/\n├── src/\n│   └── main.go\n└── README.md"
+	body, _ := json.Marshal(APIRequest{Operation: "createTree", Structure: structure})
+
+	w, response := doHandlerOperation(t, New(backend), string(body))
+	if w.Code != http.StatusOK || !response.Success {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+	if response.Count.Success != 4 {
+		t.Errorf("Expected 4 created items, got %d", response.Count.Success)
+	}
+	if _, err := backend.Lstat(filepath.Join("app", "src", "main.go")); err != nil {
+		t.Errorf("File was not created: %v", err)
+	}
+
+	// A file in place of the root folder makes every item fail
+	blocked := ffi.NewMemoryBackend()
+	blocked.WriteFile(context.Background(), "app", nil, ffi.WriteOptions{})
+	w, response = doHandlerOperation(t, New(blocked), string(body))
+	if w.Code == http.StatusOK {
+		t.Error("Expected a failure status, got 200")
+	}
+	if response.Success || response.Count.Success != 0 {
+		t.Errorf("Expected every item to fail: %+v", response)
+	}
+}
+
+// TestCreateFileContent verifies createFile writes text and base64 content with a mode
+func TestCreateFileContent(t *testing.T) {
+	backend := ffi.NewMemoryBackend()
+	h := New(backend)
+
+	body, _ := json.Marshal(APIRequest{Operation: "createFile", Paths: []string{"conf/app.yaml"}, Content: "port: 8080\n", Mode: "600"})
+	if w, response := doHandlerOperation(t, h, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the file to be created, got %d: %s", w.Code, response.Message)
+	}
+	if data, _ := backend.ReadFile("conf/app.yaml"); string(data) != "port: 8080\n" {
+		t.Errorf("Unexpected content %q", data)
+	}
+	if info, _ := backend.Lstat("conf/app.yaml"); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	body, _ = json.Marshal(APIRequest{Operation: "createFile", Paths: []string{"logo.bin"}, Content: "AAH/", Encoding: "base64"})
+	if w, response := doHandlerOperation(t, h, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the binary file to be created, got %d: %s", w.Code, response.Message)
+	}
+	if data, _ := backend.ReadFile("logo.bin"); string(data) != "\x00\x01\xff" {
+		t.Errorf("Unexpected binary content %q", data)
+	}
+
+	body, _ = json.Marshal(APIRequest{Operation: "createFile", Paths: []string{"conf/app.yaml"}, Content: "replaced", Exclusive: true})
+	if w, response := doHandlerOperation(t, h, string(body)); w.Code != http.StatusConflict || response.Success {
+		t.Errorf("Expected an exclusive create of an existing file to fail with 409, got %d: %s", w.Code, response.Message)
+	}
+	if data, _ := backend.ReadFile("conf/app.yaml"); string(data) != "port: 8080\n" {
+		t.Errorf("The existing file was replaced: %q", data)
+	}
+
+	for _, body := range []string{
+		`{"operation":"createFile","paths":["x"],"content":"%%%","encoding":"base64"}`,
+		`{"operation":"createFile","paths":["x"],"content":"x","encoding":"hex"}`,
+		`{"operation":"createFile","paths":["x"],"mode":"999"}`,
+	} {
+		if w, response := doHandlerOperation(t, h, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", body, w.Code, response.Message)
+		}
+	}
+}
+
+// TestCreateOptions verifies missing and blocked parents are reported per path and
+// folders take a mode
+func TestCreateOptions(t *testing.T) {
+	backend := ffi.NewMemoryBackend()
+	h := New(backend)
+	backend.WriteFile(context.Background(), "blocked", nil, ffi.WriteOptions{})
+
+	body := `{"operation":"createFile","paths":["blocked/sub/a.txt","ok/b.txt"]}`
+	w, response := doHandlerOperation(t, h, body)
+	if w.Code != http.StatusMultiStatus || response.Count.Success != 1 || len(response.Results) != 2 {
+		t.Fatalf("Expected one file to fail, got %d: %+v", w.Code, response)
+	}
+	if failed := response.Results[0]; failed.Success || !strings.Contains(failed.Message, "Cannot create the folder of file") {
+		t.Errorf("Expected the blocked parent to be reported, got %+v", failed)
+	}
+
+	body = `{"operation":"createFile","paths":["missing/c.txt"],"parents":false}`
+	if w, response := doHandlerOperation(t, h, body); w.Code != http.StatusNotFound || response.Code != "NOT_FOUND" {
+		t.Errorf("Expected a missing parent to fail with 404, got %d: %s", w.Code, response.Message)
+	}
+
+	body = `{"operation":"createFolder","paths":["private/keys"],"mode":"700"}`
+	if w, response := doHandlerOperation(t, h, body); w.Code != http.StatusOK {
+		t.Fatalf("Expected the folder to be created, got %d: %s", w.Code, response.Message)
+	}
+	if info, _ := backend.Lstat("private/keys"); info.Mode().Perm() != 0700 {
+		t.Errorf("Expected mode 0700, got %v", info.Mode().Perm())
+	}
+	body = `{"operation":"createFolder","paths":["private/keys"],"exclusive":true}`
+	if w, response := doHandlerOperation(t, h, body); w.Code != http.StatusConflict {
+		t.Errorf("Expected an exclusive create of an existing folder to fail with 409, got %d: %s", w.Code, response.Message)
+	}
+	body = `{"operation":"createFolder","paths":["x"],"owner":"no-such-user-here"}`
+	if w, response := doHandlerOperation(t, h, body); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown owner to be refused, got %d: %s", w.Code, response.Message)
+	}
+
+	// createCustom applies the options of the request to its files as createFile does
+	body = `{"operation":"createCustom","structure":"f:src/main.go\nd:bin","mode":"600"}`
+	if w, response := doHandlerOperation(t, h, body); w.Code != http.StatusOK {
+		t.Fatalf("Expected a file in a missing folder to be created, got %d: %s", w.Code, response.Message)
+	}
+	if info, _ := backend.Lstat("src/main.go"); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	body = `{"operation":"createCustom","structure":"f:lib/util.go","parents":false}`
+	if w, response := doHandlerOperation(t, h, body); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a missing parent to fail with 404 without parents, got %d: %s", w.Code, response.Message)
+	}
+}
+
+// TestCreateTemplateInMemory verifies createTemplate writes the template files through the backend
+func TestCreateTemplateInMemory(t *testing.T) {
+	backend := ffi.NewMemoryBackend()
+
+	w, response := doHandlerOperation(t, New(backend), `{"operation":"createTemplate","template":"go-project","rootDir":"/projects/demo"}`)
+	if w.Code != http.StatusOK || !response.Success {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+
+	data, err := backend.ReadFile("/projects/demo/go.mod")
+	if err != nil || !strings.Contains(string(data), "module") {
+		t.Errorf("go.mod reads %q (%v)", data, err)
+	}
+}
+
+// TestFailureCodes verifies failures without a code report IO_ERROR with 500, and
+// template failures the code of the entry that failed
+func TestFailureCodes(t *testing.T) {
+	response := resultResponse(ffi.Result{Success: false, Message: "unclassified"})
+	if response.Code != ffi.ErrorKindIO.Code() || statusForResponse(response) != http.StatusInternalServerError {
+		t.Errorf("Expected IO_ERROR with 500, got %s with %d", response.Code, statusForResponse(response))
+	}
+	if status := statusForCode(""); status != http.StatusInternalServerError {
+		t.Errorf("Expected a failure without a code to give 500, got %d", status)
+	}
+
+	backend := ffi.NewMemoryBackend()
+	backend.CreateFolder(context.Background(), "/projects", ffi.CreateOptions{})
+	backend.WriteFile(context.Background(), "/projects/demo", nil, ffi.WriteOptions{})
+	body := `{"operation":"createTemplate","template":"go-project","rootDir":"/projects/demo","transactional":true}`
+	if w, response := doHandlerOperation(t, New(backend), body); w.Code != http.StatusConflict || response.Code != ffi.ErrorKindAlreadyExists.Code() {
+		t.Errorf("Expected a root taken by a file to fail with 409, got %d %s: %s", w.Code, response.Code, response.Message)
+	}
+}
+
+// TestDryRun verifies dryRun returns the plan of an operation without running it
+func TestDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	existing := filepath.Join(tmpDir, "app")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(APIRequest{
+		Operation: "createFolder",
+		Paths:     []string{existing, filepath.Join(tmpDir, "app", "src", "pkg")},
+		DryRun:    true,
+	})
+	w, response := doOperation(t, string(body))
+
+	if w.Code != http.StatusOK || !response.Success {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+	if response.Plan == nil {
+		t.Fatal("Response has no plan")
+	}
+	expected := []PlanAction{
+		{Op: "createFolder", Path: filepath.Join(tmpDir, "app", "src")},
+		{Op: "createFolder", Path: filepath.Join(tmpDir, "app", "src", "pkg")},
+	}
+	if len(response.Plan.Actions) != len(expected) || response.Plan.Actions[0] != expected[0] || response.Plan.Actions[1] != expected[1] {
+		t.Errorf("Unexpected actions: %+v", response.Plan.Actions)
+	}
+	if len(response.Plan.Existing) != 1 || response.Plan.Existing[0] != existing {
+		t.Errorf("Unexpected existing entries: %v", response.Plan.Existing)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "app", "src")); !os.IsNotExist(err) {
+		t.Errorf("Dry run created a folder: %v", err)
+	}
+
+	// A failing plan is reported like a failing operation
+	w, response = doOperation(t, `{"operation":"delete","paths":["`+filepath.Join(tmpDir, "missing")+`"],"dryRun":true}`)
+	if w.Code != http.StatusNotFound || response.Plan == nil || len(response.Plan.Actions) != 0 {
+		t.Errorf("Expected 404 with an empty plan, got %d: %+v", w.Code, response)
+	}
+}
+
+// TestVerifiedCopy verifies copies accept a checksum algorithm and reject unknown ones
+func TestVerifiedCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src.txt")
+	if err := os.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(APIRequest{Operation: "copy", Source: src, Dest: filepath.Join(tmpDir, "dst.txt"), Verify: "blake3"})
+	w, response := doOperation(t, string(body))
+	if w.Code != http.StatusOK || !strings.Contains(response.Message, "verified with blake3") {
+		t.Errorf("Expected a verified copy, got %d: %s", w.Code, response.Message)
+	}
+
+	body, _ = json.Marshal(APIRequest{Operation: "copy", Source: src, Dest: filepath.Join(tmpDir, "other.txt"), Verify: "md5"})
+	w, response = doOperation(t, string(body))
+	if w.Code != http.StatusBadRequest || response.Code != codeInvalidRequest {
+		t.Errorf("Expected 400 %s, got %d %s", codeInvalidRequest, w.Code, response.Code)
+	}
+}
+
+// TestDeleteToTrash verifies delete moves entries to the trash unless it is permanent,
+// and that trashed entries are listed and restored
+func TestDeleteToTrash(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	kept := filepath.Join(tmpDir, "kept.txt")
+	gone := filepath.Join(tmpDir, "gone.txt")
+	for _, path := range []string{kept, gone} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := testHandler(t)
+	if w, response := doHandlerOperation(t, h, `{"operation":"delete","paths":["`+kept+`"]}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+	if w, response := doHandlerOperation(t, h, `{"operation":"delete","paths":["`+gone+`"],"permanent":true}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleTrash(w, httptest.NewRequest("GET", "/api/trash", nil))
+	var items []TrashItem
+	if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+		t.Fatalf("Failed to decode trash: %v", err)
+	}
+	if len(items) != 1 || items[0].OriginalPath != kept || items[0].Size != 4 {
+		t.Fatalf("Unexpected trash: %+v", items)
+	}
+
+	body, _ := json.Marshal(APIRequest{Operation: "restore", Paths: []string{items[0].Path}})
+	if w, response := doHandlerOperation(t, h, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("Restored file missing: %v", err)
+	}
+	if _, err := os.Stat(gone); !os.IsNotExist(err) {
+		t.Errorf("Permanently deleted file still exists: %v", err)
+	}
+	if w, response := doHandlerOperation(t, h, string(body)); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 restoring twice, got %d: %s", w.Code, response.Message)
+	}
+}
+
+// TestShred verifies shred must be confirmed and overwrites and deletes every path
+func TestShred(t *testing.T) {
+	tmpDir := t.TempDir()
+	paths := []string{filepath.Join(tmpDir, "a.key"), filepath.Join(tmpDir, "b.key")}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("secret"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body, _ := json.Marshal(APIRequest{Operation: "shred", Paths: paths})
+	w, response := doOperation(t, string(body))
+	if w.Code != http.StatusBadRequest || response.Code != codeInvalidRequest {
+		t.Errorf("Expected 400 %s without confirmation, got %d %s", codeInvalidRequest, w.Code, response.Code)
+	}
+	if _, err := os.Stat(paths[0]); err != nil {
+		t.Fatalf("Unconfirmed shred removed a file: %v", err)
+	}
+
+	body, _ = json.Marshal(APIRequest{Operation: "shred", Paths: paths, Confirm: "shred", Passes: 1, VerifyShred: true})
+	w, response = doOperation(t, string(body))
+	if w.Code != http.StatusOK || response.Count.Success != 2 {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Shredded file still exists: %v", err)
+		}
+	}
+}
+
+// TestChmod verifies symbolic and recursive chmod with a preview of every change
+func TestChmod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix permission bits")
+	}
+	tmpDir := t.TempDir()
+	script := filepath.Join(tmpDir, "bin", "run.sh")
+	if err := os.MkdirAll(filepath.Dir(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(APIRequest{Operation: "chmod", Paths: []string{tmpDir}, Mode: "u+x,go-rwx", DirMode: "2750", Recursive: true, Preview: true})
+	w, response := doOperation(t, string(body))
+	if w.Code != http.StatusOK || len(response.Modes) != 3 {
+		t.Fatalf("Expected a preview of 3 entries, got %d: %s %+v", w.Code, response.Message, response.Modes)
+	}
+	last := response.Modes[2]
+	if last.Path != script || last.Before != "0644" || last.After != "0700" || last.AfterSymbolic != "rwx------" {
+		t.Errorf("Unexpected change: %+v", last)
+	}
+	if info, _ := os.Stat(script); info.Mode().Perm() != 0644 {
+		t.Errorf("Preview changed the mode to %v", info.Mode())
+	}
+
+	body, _ = json.Marshal(APIRequest{Operation: "chmod", Paths: []string{tmpDir}, Mode: "u+x,go-rwx", DirMode: "2750", Recursive: true})
+	if w, response := doOperation(t, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+	if info, _ := os.Stat(filepath.Dir(script)); info.Mode()&os.ModeSetgid == 0 || info.Mode().Perm() != 0750 {
+		t.Errorf("Unexpected folder mode %v", info.Mode())
+	}
+
+	w, response = doOperation(t, `{"operation":"chmod","paths":["`+script+`"],"mode":"u+q"}`)
+	if w.Code != http.StatusBadRequest || response.Code != codeInvalidRequest {
+		t.Errorf("Expected 400 %s for an invalid mode, got %d %s", codeInvalidRequest, w.Code, response.Code)
+	}
+}
+
+// TestChown verifies chown reports invalid owners and refused changes
+func TestChown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix owners")
+	}
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(APIRequest{Operation: "chown", Paths: []string{file}, Owner: ":" + strconv.Itoa(os.Getgid())})
+	if w, response := doOperation(t, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected success, got %d: %s", w.Code, response.Message)
+	}
+
+	w, response := doOperation(t, `{"operation":"chown","paths":["`+file+`"],"owner":"no-such-user-xyz"}`)
+	if w.Code != http.StatusBadRequest || response.Code != codeInvalidRequest {
+		t.Errorf("Expected 400 %s for an unknown user, got %d %s", codeInvalidRequest, w.Code, response.Code)
+	}
+
+	if os.Geteuid() != 0 {
+		w, response = doOperation(t, `{"operation":"chown","paths":["`+file+`"],"owner":"0"}`)
+		if w.Code != http.StatusForbidden || !strings.Contains(response.Message, "root") {
+			t.Errorf("Expected 403 with a hint about root, got %d: %s", w.Code, response.Message)
+		}
+	}
+}
+
+// TestListAndStat verifies the listing is sorted, filtered and paged and stat reports missing entries
+func TestListAndStat(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{"b.txt": "0123456789", "a.txt": "abc", ".hidden": ""} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	h := testHandler(t)
+
+	get := func(handle http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handle(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	w := get(h.HandleList, "/api/list?sort=size&order=desc&limit=2&path="+url.QueryEscape(tmpDir))
+	var listing ListResponse
+	if err := json.NewDecoder(w.Body).Decode(&listing); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected a listing, got %d: %v", w.Code, err)
+	}
+	if listing.Total != 3 || len(listing.Entries) != 2 || listing.Entries[0].Name != "docs" || listing.Entries[1].Name != "b.txt" {
+		t.Errorf("Unexpected listing %+v", listing)
+	}
+	if entry := listing.Entries[1]; entry.Type != ffi.EntryFile || entry.Size != 10 {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	if w := get(h.HandleList, "/api/list?limit=-1&path="+url.QueryEscape(tmpDir)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative limit, got %d", w.Code)
+	}
+
+	w = get(h.HandleStat, "/api/stat?path="+url.QueryEscape(filepath.Join(tmpDir, "a.txt")))
+	var entry EntryResponse
+	if err := json.NewDecoder(w.Body).Decode(&entry); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected an entry, got %d: %v", w.Code, err)
+	}
+	if entry.Size != 3 || entry.Modified.IsZero() {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	if w := get(h.HandleStat, "/api/stat?path="+url.QueryEscape(filepath.Join(tmpDir, "missing"))); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing entry, got %d", w.Code)
+	}
+}
+
+// TestBulkOperations verifies glob patterns and filters are expanded, previewed and applied
+func TestBulkOperations(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, path := range []string{"app.pyc", "pkg/mod.pyc", "pkg/mod.py", "build/logs/run.log", "notes[1].txt"} {
+		path = filepath.Join(tmpDir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body, _ := json.Marshal(APIRequest{Operation: "delete", Paths: []string{tmpDir}, Include: []string{"**/*.pyc"}, Permanent: true, Preview: true})
+	w, response := doOperation(t, string(body))
+	if w.Code != http.StatusOK || len(response.Matches) != 2 {
+		t.Fatalf("Expected a preview of 2 matches, got %d: %s %v", w.Code, response.Message, response.Matches)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "app.pyc")); err != nil {
+		t.Errorf("Preview deleted a match: %v", err)
+	}
+
+	body, _ = json.Marshal(APIRequest{Operation: "delete", Paths: []string{filepath.Join(tmpDir, "**", "*.pyc")}, Permanent: true})
+	if w, response := doOperation(t, string(body)); w.Code != http.StatusOK || response.Count.Success != 2 {
+		t.Fatalf("Expected 2 deletes, got %d: %s", w.Code, response.Message)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "pkg", "mod.pyc")); !os.IsNotExist(err) {
+		t.Errorf("Match was not deleted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "pkg", "mod.py")); err != nil {
+		t.Errorf("Non-matching file was deleted: %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "archive")
+	body, _ = json.Marshal(APIRequest{Operation: "move", Source: filepath.Join(tmpDir, "build", "**", "*.log"), Dest: archive, MaxSize: "1K"})
+	if w, response := doOperation(t, string(body)); w.Code != http.StatusOK || response.Count.Success != 1 {
+		t.Fatalf("Expected 1 move, got %d: %s", w.Code, response.Message)
+	}
+	if _, err := os.Stat(filepath.Join(archive, "logs", "run.log")); err != nil {
+		t.Errorf("Log was not moved below its folder: %v", err)
+	}
+
+	// A name with glob characters that exists is taken literally
+	body, _ = json.Marshal(APIRequest{Operation: "delete", Paths: []string{filepath.Join(tmpDir, "notes[1].txt")}, Permanent: true})
+	if w, response := doOperation(t, string(body)); w.Code != http.StatusOK || response.Matches != nil {
+		t.Errorf("Expected a literal delete, got %d: %s %v", w.Code, response.Message, response.Matches)
+	}
+
+	w, response = doOperation(t, `{"operation":"delete","paths":["`+tmpDir+`"],"include":["[a-"]}`)
+	if w.Code != http.StatusBadRequest || response.Code != codeInvalidRequest {
+		t.Errorf("Expected 400 %s for a malformed pattern, got %d %s", codeInvalidRequest, w.Code, response.Code)
+	}
+
+	// Equal bounds select an exact size, keeping both of them
+	if err := os.WriteFile(filepath.Join(tmpDir, "pkg", "ten.py"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body, _ = json.Marshal(APIRequest{Operation: "delete", Paths: []string{filepath.Join(tmpDir, "**", "*.py")}, MinSize: "10", MaxSize: "10", Preview: true})
+	w, response = doOperation(t, string(body))
+	if w.Code != http.StatusOK || len(response.Matches) != 1 || filepath.Base(response.Matches[0]) != "ten.py" {
+		t.Errorf("Expected only the file of 10 bytes, got %d: %s %v", w.Code, response.Message, response.Matches)
+	}
+}
+
+func TestBulkRename(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"IMG_1.JPG", "IMG_2.JPG", "1.log", "2.log", "holiday-03.jpg"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := APIRequest{Operation: "bulkRename", Paths: []string{filepath.Join(tmpDir, "*.JPG")}, Replace: "holiday-{n:02}{ext}", Slugify: true, Preview: true}
+	body, _ := json.Marshal(request)
+	w, response := doOperation(t, string(body))
+	if w.Code != http.StatusOK || len(response.Renames) != 2 || filepath.Base(response.Renames[1].NewPath) != "holiday-02.jpg" {
+		t.Fatalf("Expected a preview of 2 renames, got %d: %s %+v", w.Code, response.Message, response.Renames)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "IMG_1.JPG")); err != nil {
+		t.Errorf("Preview renamed an entry: %v", err)
+	}
+
+	// Numbering from 2 would give the name of a file outside the selection
+	request.Start = 2
+	body, _ = json.Marshal(request)
+	w, response = doOperation(t, string(body))
+	if w.Code != http.StatusConflict || response.Renames[1].Conflict == "" {
+		t.Fatalf("Expected a preview to report 409 with a conflict, got %d: %s %+v", w.Code, response.Message, response.Renames)
+	}
+	request.Preview = false
+	body, _ = json.Marshal(request)
+	w, response = doOperation(t, string(body))
+	if w.Code != http.StatusConflict || response.Renames[1].Conflict == "" {
+		t.Fatalf("Expected 409 with a conflict, got %d: %s %+v", w.Code, response.Message, response.Renames)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "IMG_1.JPG")); err != nil {
+		t.Errorf("Entries were renamed despite a conflict: %v", err)
+	}
+
+	// Numbering 1.log and 2.log from 2 renames 2.log out of the way first
+	body, _ = json.Marshal(APIRequest{Operation: "bulkRename", Paths: []string{filepath.Join(tmpDir, "*.log")}, Replace: "{n}.log", Start: 2})
+	if w, response := doOperation(t, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the renames to succeed, got %d: %s", w.Code, response.Message)
+	}
+	for name, want := range map[string]string{"2.log": "1.log", "3.log": "2.log"} {
+		if data, _ := os.ReadFile(filepath.Join(tmpDir, name)); string(data) != want {
+			t.Errorf("%s holds %q, expected %q", name, data, want)
+		}
+	}
+
+	w, response = doOperation(t, `{"operation":"bulkRename","paths":["`+tmpDir+`"],"find":"("}`)
+	if w.Code != http.StatusBadRequest || response.Code != codeInvalidRequest {
+		t.Errorf("Expected 400 %s for a malformed expression, got %d %s", codeInvalidRequest, w.Code, response.Code)
+	}
+}
+
+// TestHistory verifies every request becomes one entry of the history that undo and redo reverse
+func TestHistory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	backend, err := ffi.BackendFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewWithHistory(journal.New(backend, filepath.Join(t.TempDir(), "history.json")))
+
+	a, b := filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "b")
+	body, _ := json.Marshal(APIRequest{Operation: "createFolder", Paths: []string{a, b}})
+	if w, response := doHandlerOperation(t, h, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the folders to be created, got %d: %s", w.Code, response.Message)
+	}
+	body, _ = json.Marshal(APIRequest{Operation: "move", Source: a, Dest: filepath.Join(b, "a")})
+	if w, response := doHandlerOperation(t, h, string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected the move to succeed, got %d: %s", w.Code, response.Message)
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleHistory(w, httptest.NewRequest("GET", "/api/history", nil))
+	var history []HistoryEntry
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v (%v)", history, err)
+	}
+	if entry := history[0]; entry.Steps[0].Op != journal.StepMove || entry.Steps[0].Dest != filepath.Join(b, "a") {
+		t.Errorf("Expected the move first, got %+v", entry)
+	}
+	if len(history[1].Steps) != 2 {
+		t.Errorf("Expected both folders in one entry, got %+v", history[1])
+	}
+
+	if w, response := doHandlerOperation(t, h, `{"operation":"undo"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected the move to be undone, got %d: %s", w.Code, response.Message)
+	}
+	if _, err := os.Stat(a); err != nil {
+		t.Errorf("The folder was not moved back: %v", err)
+	}
+	if w, response := doHandlerOperation(t, h, `{"operation":"redo"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected the move to be redone, got %d: %s", w.Code, response.Message)
+	}
+	if w, response := doHandlerOperation(t, h, `{"operation":"redo"}`); w.Code != http.StatusNotFound || response.Code != "NOT_FOUND" {
+		t.Errorf("Expected nothing to redo, got %d: %s", w.Code, response.Message)
+	}
+
+	if w, response := doOperation(t, `{"operation":"undo"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a history, got %d: %s", w.Code, response.Message)
+	}
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"filemanager/internal/ffi"
+	"filemanager/internal/journal"
 	"filemanager/pkg/version"
 	"fmt"
 	"log"
@@ -12,8 +14,9 @@ import (
 	"strings"
 )
 
-// StartWebServer starts the HTTP server
-func StartWebServer() error {
+// StartWebServer starts the HTTP server, running file operations on backend
+// When history is not nil, operations run on its backend instead and are recorded.
+func StartWebServer(backend ffi.Backend, history *journal.Journal) error {
 	// Determine the static files directory
 	// Try to use the executable's directory first, then fall back to current directory
 	exePath, err := os.Executable()
@@ -61,9 +64,18 @@ func StartWebServer() error {
 	})
 
 	// API endpoints
-	http.HandleFunc("/api/operation", HandleOperation)
+	api := New(backend)
+	if history != nil {
+		api = NewWithHistory(history)
+	}
+	http.HandleFunc("/api/operation", api.HandleOperation)
+	http.HandleFunc("/api/operation/stream", api.HandleOperationStream)
 	http.HandleFunc("/api/templates", HandleTemplates)
-	http.HandleFunc("/api/health", HandleHealth)
+	http.HandleFunc("/api/trash", api.HandleTrash)
+	http.HandleFunc("/api/history", api.HandleHistory)
+	http.HandleFunc("/api/list", api.HandleList)
+	http.HandleFunc("/api/stat", api.HandleStat)
+	http.HandleFunc("/api/health", api.HandleHealth)
 
 	port := "8080"
 	url := fmt.Sprintf("http://localhost:%s", port)
@@ -208,6 +220,9 @@ func createBasicFrontend(staticDir string) {
                     <h2>📄 Create File(s)</h2>
                     <p class="form-description">Enter file paths separated by spaces. Parent directories will be created automatically.</p>
                     <textarea id="filePaths" placeholder="e.g., project/src/main.go project/README.md" rows="4"></textarea>
+                    <textarea id="fileContent" placeholder="Content of the files (optional)" rows="6"></textarea>
+                    <input type="text" id="fileMode" placeholder="Mode (optional, e.g., 600 or u+x)">
+                    <label><input type="checkbox" id="fileExclusive"> Fail for files that already exist</label>
                     <button class="btn-primary" onclick="executeCreateFile()">Create Files</button>
                 </div>
 
@@ -850,7 +865,10 @@ async function executeCreateFile() {
         showError('Please enter at least one file path');
         return;
     }
-    await sendRequest('createFile', { paths });
+    const content = document.getElementById('fileContent').value;
+    const mode = document.getElementById('fileMode').value.trim();
+    const exclusive = document.getElementById('fileExclusive').checked;
+    await sendRequest('createFile', { paths, content, mode, exclusive });
 }
 
 async function executeRename() {
@@ -1147,7 +1165,7 @@ Enjoy using FileManager in both terminal and web modes!
 
 	successCount := 0
 	for filePath, content := range files {
-		if err := os.WriteFile(filePath, content, 0644); err != nil {
+		if err := ffi.WriteFileAtomic(filePath, content, ffi.WriteOptions{}); err != nil {
 			log.Printf("❌ Failed to write %s: %v\n", filePath, err)
 		} else {
 			successCount++
@@ -0,0 +1,213 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"filemanager/internal/ffi"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// recordingBackend makes changes on a backend and records them in a journal
+// Reads go straight to the backend. Changes that cannot be reversed, such as
+// permanent deletes and overwritten files, are only noted in the entry of the
+// operation they are part of.
+type recordingBackend struct {
+	ffi.Backend
+	journal *Journal
+}
+
+// absPath returns path as an absolute path, so steps can be reversed from any directory
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// exists reports whether an entry exists at path
+func (b *recordingBackend) exists(path string) bool {
+	_, err := b.Backend.Lstat(path)
+	return err == nil
+}
+
+// missing returns the topmost folder of path that does not exist, path itself when
+// only it is missing, or an empty string when it exists
+func (b *recordingBackend) missing(path string) string {
+	top := ""
+	for p := absPath(path); ; p = filepath.Dir(p) {
+		if _, err := b.Backend.Lstat(p); !errors.Is(err, fs.ErrNotExist) {
+			return top
+		}
+		top = p
+		if filepath.Dir(p) == p {
+			return top
+		}
+	}
+}
+
+func (b *recordingBackend) CreateFolder(ctx context.Context, path string, opts ffi.CreateOptions) ffi.Result {
+	top := b.missing(path)
+	result := b.Backend.CreateFolder(ctx, path, opts)
+	if result.Success && top != "" {
+		b.journal.record(ctx, fmt.Sprintf("Create folder %s", path), []Step{{Op: StepCreate, Path: top}})
+	}
+	return result
+}
+
+// CreateFile records the topmost folder it created for the file, or the file itself
+func (b *recordingBackend) CreateFile(ctx context.Context, path string, opts ffi.CreateOptions) ffi.Result {
+	top := b.missing(path)
+	result := b.Backend.CreateFile(ctx, path, opts)
+	if result.Success {
+		if top == "" {
+			b.recordWrite(ctx, path, true)
+		} else {
+			b.journal.record(ctx, fmt.Sprintf("Create file %s", path), []Step{{Op: StepCreate, Path: top}})
+		}
+	}
+	return result
+}
+
+func (b *recordingBackend) WriteFile(ctx context.Context, path string, content []byte, opts ffi.WriteOptions) ffi.Result {
+	existed := b.exists(path)
+	result := b.Backend.WriteFile(ctx, path, content, opts)
+	if result.Success {
+		b.recordWrite(ctx, path, existed)
+	}
+	return result
+}
+
+// recordWrite records that a file was written, creating it unless it existed
+func (b *recordingBackend) recordWrite(ctx context.Context, path string, existed bool) {
+	if existed {
+		b.journal.record(ctx, "", nil, fmt.Sprintf("the previous content of %s was replaced", path))
+		return
+	}
+	b.journal.record(ctx, fmt.Sprintf("Create file %s", path), []Step{{Op: StepCreate, Path: absPath(path)}})
+}
+
+func (b *recordingBackend) RenamePath(ctx context.Context, oldPath, newPath string) ffi.Result {
+	existed := b.exists(newPath)
+	result := b.Backend.RenamePath(ctx, oldPath, newPath)
+	if result.Success {
+		var notes []string
+		if existed {
+			notes = append(notes, fmt.Sprintf("the entry %s replaced was lost", newPath))
+		}
+		b.journal.record(ctx, fmt.Sprintf("Rename %s to %s", oldPath, newPath),
+			[]Step{{Op: StepMove, Path: absPath(oldPath), Dest: absPath(newPath)}}, notes...)
+	}
+	return result
+}
+
+func (b *recordingBackend) DeletePath(ctx context.Context, path string, opts ffi.DeleteOptions, progress ffi.ProgressFunc) ffi.Result {
+	result := b.Backend.DeletePath(ctx, path, opts, progress)
+	if result.Success {
+		b.recordDelete(ctx, path)
+	}
+	return result
+}
+
+func (b *recordingBackend) Remove(ctx context.Context, path string) ffi.Result {
+	result := b.Backend.Remove(ctx, path)
+	if result.Success {
+		b.recordDelete(ctx, path)
+	}
+	return result
+}
+
+// recordDelete records that path was deleted for good: entries the operation
+// created are forgotten, anything else is noted as lost
+func (b *recordingBackend) recordDelete(ctx context.Context, path string) {
+	if !b.journal.forget(ctx, absPath(path)) {
+		b.journal.record(ctx, "", nil, fmt.Sprintf("%s was deleted permanently", path))
+	}
+}
+
+func (b *recordingBackend) ChangePermissions(ctx context.Context, path string, mode uint32) ffi.Result {
+	info, err := b.Backend.Lstat(path)
+	result := b.Backend.ChangePermissions(ctx, path, mode)
+	if result.Success && err == nil {
+		if old := ffi.PermissionBits(info.Mode()); old != mode {
+			b.journal.record(ctx, fmt.Sprintf("Change permissions of %s to %04o", path, mode),
+				[]Step{{Op: StepChmod, Path: absPath(path), OldMode: old, NewMode: mode}})
+		}
+	}
+	return result
+}
+
+func (b *recordingBackend) ChangeOwner(ctx context.Context, path string, uid, gid int, noFollow bool) ffi.Result {
+	info, err := ffi.Stat(b.Backend, path)
+	result := b.Backend.ChangeOwner(ctx, path, uid, gid, noFollow)
+	if !result.Success || err != nil || info.UID < 0 {
+		return result
+	}
+	if info.Type == ffi.EntrySymlink && !noFollow {
+		b.journal.record(ctx, "", nil, fmt.Sprintf("the owner of what %s points to was changed", path))
+		return result
+	}
+
+	step := Step{Op: StepChown, Path: absPath(path), OldUID: info.UID, OldGID: info.GID, NewUID: uid, NewGID: gid, NoFollow: noFollow}
+	if uid < 0 {
+		step.NewUID = info.UID
+	}
+	if gid < 0 {
+		step.NewGID = info.GID
+	}
+	if step.NewUID != step.OldUID || step.NewGID != step.OldGID {
+		b.journal.record(ctx, fmt.Sprintf("Change owner of %s", path), []Step{step})
+	}
+	return result
+}
+
+func (b *recordingBackend) MovePath(ctx context.Context, src, dst string, opts ffi.CopyOptions, progress ffi.ProgressFunc) ffi.Result {
+	parent := b.missing(filepath.Dir(dst))
+	existed := b.exists(dst)
+	result := b.Backend.MovePath(ctx, src, dst, opts, progress)
+	if result.Success {
+		b.recordTransfer(ctx, fmt.Sprintf("Move %s to %s", src, dst), src, dst, parent, existed, result, true)
+	}
+	return result
+}
+
+func (b *recordingBackend) CopyPath(ctx context.Context, src, dst string, opts ffi.CopyOptions, progress ffi.ProgressFunc) ffi.Result {
+	parent := b.missing(filepath.Dir(dst))
+	existed := b.exists(dst)
+	result := b.Backend.CopyPath(ctx, src, dst, opts, progress)
+	if result.Success {
+		b.recordTransfer(ctx, fmt.Sprintf("Copy %s to %s", src, dst), src, dst, parent, existed, result, false)
+	}
+	return result
+}
+
+// recordTransfer records a copy or move of src to dst that created the folder parent
+// A destination that existed is reversible only when the entry was written next to
+// it under a new name; otherwise it was merged into or replaced.
+func (b *recordingBackend) recordTransfer(ctx context.Context, title, src, dst, parent string, existed bool, result ffi.Result, move bool) {
+	var steps []Step
+	if parent != "" {
+		steps = append(steps, Step{Op: StepCreate, Path: parent})
+	}
+
+	target := dst
+	if existed {
+		target = ""
+		for _, conflict := range result.Conflicts {
+			if conflict.Source == src && conflict.Action == ffi.ConflictRenamed {
+				target = conflict.Dest
+			}
+		}
+	}
+	switch {
+	case target == "":
+		b.journal.record(ctx, title, steps, fmt.Sprintf("%s was merged into or replaced the existing %s", src, dst))
+		return
+	case move:
+		steps = append(steps, Step{Op: StepMove, Path: absPath(src), Dest: absPath(target)})
+	default:
+		steps = append(steps, Step{Op: StepCreate, Path: absPath(target)})
+	}
+	b.journal.record(ctx, title, steps)
+}
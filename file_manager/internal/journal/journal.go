@@ -0,0 +1,475 @@
+// Package journal records the changes made through a backend so they can be undone
+// and redone, keeping the history in a file between runs
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"filemanager/internal/ffi"
+	"filemanager/internal/trash"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of Step
+const (
+	// StepCreate created Path; undoing it moves Path to the trash
+	StepCreate = "create"
+	// StepMove moved or renamed Path to Dest
+	StepMove = "move"
+	// StepChmod changed the permission bits of Path from OldMode to NewMode
+	StepChmod = "chmod"
+	// StepChown changed the owner of Path from OldUID:OldGID to NewUID:NewGID
+	StepChown = "chown"
+	// StepTrash moved Path to the trash, which keeps it at Trashed
+	StepTrash = "trash"
+	// StepRestore moved Path back from Trashed in the trash
+	StepRestore = "restore"
+)
+
+// Step is a single change with what it takes to reverse it
+type Step struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Dest string `json:"dest,omitempty"`
+	// Trashed is where the trash keeps Path while it is deleted: after a trash step,
+	// before a restore step and once a create step is undone
+	Trashed string `json:"trashed,omitempty"`
+	OldMode uint32 `json:"oldMode,omitempty"`
+	NewMode uint32 `json:"newMode,omitempty"`
+	OldUID  int    `json:"oldUid,omitempty"`
+	OldGID  int    `json:"oldGid,omitempty"`
+	NewUID  int    `json:"newUid,omitempty"`
+	NewGID  int    `json:"newGid,omitempty"`
+	// NoFollow changes the owner of a symbolic link itself
+	NoFollow bool `json:"noFollow,omitempty"`
+}
+
+// Entry is an operation of the history, such as a move or a recursive chmod
+type Entry struct {
+	ID    int       `json:"id"`
+	Time  time.Time `json:"time"`
+	Title string    `json:"title"`
+	Steps []Step    `json:"steps"`
+	// Notes tell what the operation changed that cannot be undone, such as the
+	// content of an overwritten file
+	Notes []string `json:"notes,omitempty"`
+	// Undone is set while the entry is undone; Redo makes it again
+	Undone bool `json:"undone,omitempty"`
+}
+
+// maxEntries bounds the history; older entries are dropped
+const maxEntries = 200
+
+// history is the content of the history file
+type history struct {
+	LastID  int     `json:"lastId"`
+	Entries []Entry `json:"entries"`
+}
+
+// Journal records the changes made through the backend returned by Backend
+//
+// Every change lands in an entry of its own, unless it is made with a context from
+// Begin, which groups the changes of an operation. Recording a new entry drops the
+// entries that were undone, as in an editor. The history is read from and written
+// to its file for every change, so processes sharing it see each other's entries.
+type Journal struct {
+	backend ffi.Backend
+	// trash undoes creations and redoes deletions without recording them
+	trash *trash.Trash
+	// path is the history file; when it is empty the history is only kept in memory
+	path string
+
+	mu     sync.Mutex
+	memory history
+}
+
+// DefaultPath returns the history file of the current user, ~/.filemanager/history.json
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the history: %w", err)
+	}
+	return filepath.Join(home, ".filemanager", "history.json"), nil
+}
+
+// New returns a journal of the changes made to backend that keeps its history in
+// the file at path, or only in memory when path is empty
+func New(backend ffi.Backend, path string) *Journal {
+	return &Journal{backend: backend, trash: trash.New(backend), path: path}
+}
+
+// Backend returns a backend that makes changes on the backend of j and records them
+func (j *Journal) Backend() ffi.Backend {
+	return &recordingBackend{Backend: j.backend, journal: j}
+}
+
+// Trash returns a trash on the backend of j that records the entries it moves
+func (j *Journal) Trash() *trash.Trash {
+	t := trash.New(j.backend)
+	t.SetRecorder(trashRecorder{j})
+	return t
+}
+
+// operationKey is the context key of the operation Begin started
+type operationKey struct{}
+
+// operation collects the changes made with a context from Begin
+type operation struct {
+	journal *Journal
+
+	mu      sync.Mutex
+	entry   Entry
+	changes int
+}
+
+// Begin returns a context whose changes end records as a single entry, titled
+// after the first change
+func (j *Journal) Begin(ctx context.Context) (context.Context, func()) {
+	op := &operation{journal: j}
+	end := func() {
+		op.mu.Lock()
+		entry := op.entry
+		if op.changes > 1 {
+			entry.Title += fmt.Sprintf(" and %d more changes", op.changes-1)
+		}
+		op.mu.Unlock()
+		if len(entry.Steps) > 0 {
+			j.add(entry)
+		}
+	}
+	return context.WithValue(ctx, operationKey{}, op), end
+}
+
+// operation returns the operation of ctx, or nil when changes are recorded one by one
+func (j *Journal) operation(ctx context.Context) *operation {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	if op == nil || op.journal != j {
+		return nil
+	}
+	return op
+}
+
+// record adds a change made with ctx; changes without steps only add their notes
+// to an operation
+func (j *Journal) record(ctx context.Context, title string, steps []Step, notes ...string) {
+	if op := j.operation(ctx); op != nil {
+		op.mu.Lock()
+		defer op.mu.Unlock()
+		if len(steps) > 0 {
+			if op.changes == 0 {
+				op.entry.Title = title
+			}
+			op.changes++
+		}
+		op.entry.Steps = append(op.entry.Steps, steps...)
+		op.entry.Notes = append(op.entry.Notes, notes...)
+		return
+	}
+	if len(steps) > 0 {
+		j.add(Entry{Title: title, Steps: steps, Notes: notes})
+	}
+}
+
+// forget drops the creation of path and of everything below it from the operation
+// of ctx, as it was removed again, and reports whether there was one
+func (j *Journal) forget(ctx context.Context, path string) bool {
+	op := j.operation(ctx)
+	if op == nil {
+		return false
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	steps := op.entry.Steps[:0]
+	forgot := false
+	for _, step := range op.entry.Steps {
+		if step.Op == StepCreate && within(step.Path, path) {
+			forgot = true
+			continue
+		}
+		steps = append(steps, step)
+	}
+	op.entry.Steps = steps
+	return forgot
+}
+
+// within reports whether path is base or below it
+func within(path, base string) bool {
+	return path == base || strings.HasPrefix(path, strings.TrimSuffix(base, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// add appends entry to the history, dropping the undone entries
+// The operation that was recorded has happened, so a history that cannot be
+// written is only logged.
+func (j *Journal) add(entry Entry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	h, err := j.load()
+	if err != nil {
+		log.Printf("⚠️  Cannot record '%s' in the history: %v", entry.Title, err)
+		return
+	}
+	for len(h.Entries) > 0 && h.Entries[len(h.Entries)-1].Undone {
+		h.Entries = h.Entries[:len(h.Entries)-1]
+	}
+	h.LastID++
+	entry.ID = h.LastID
+	entry.Time = time.Now()
+	h.Entries = append(h.Entries, entry)
+	if len(h.Entries) > maxEntries {
+		h.Entries = h.Entries[len(h.Entries)-maxEntries:]
+	}
+	if err := j.save(h); err != nil {
+		log.Printf("⚠️  Cannot record '%s' in the history: %v", entry.Title, err)
+	}
+}
+
+// load reads the history; a missing file is an empty history
+func (j *Journal) load() (history, error) {
+	if j.path == "" {
+		// Undo and Redo change the steps of their copy
+		h := history{LastID: j.memory.LastID, Entries: append([]Entry(nil), j.memory.Entries...)}
+		for i := range h.Entries {
+			h.Entries[i].Steps = append([]Step(nil), h.Entries[i].Steps...)
+		}
+		return h, nil
+	}
+	var h history
+	data, err := os.ReadFile(j.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, fmt.Errorf("cannot read the history %s: %w", j.path, err)
+	}
+	return h, nil
+}
+
+// save replaces the history file through a temporary file, so readers never see
+// a partly written one
+func (j *Journal) save(h history) error {
+	if j.path == "" {
+		j.memory = h
+		return nil
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+	return ffi.WriteFileAtomic(j.path, data, ffi.WriteOptions{Mode: 0600})
+}
+
+// History returns the entries of the history, most recent first
+func (j *Journal) History() ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	h, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(h.Entries))
+	for i, entry := range h.Entries {
+		entries[len(entries)-1-i] = entry
+	}
+	return entries, nil
+}
+
+// Undo reverses the most recent entry that is not undone, step by step from the
+// last one
+// When a step fails, the steps undone before it are made again, so an entry is
+// either undone completely or not at all.
+func (j *Journal) Undo(ctx context.Context) ffi.Result {
+	return j.apply(ctx, true)
+}
+
+// Redo makes the earliest undone entry again
+// Like Undo, it makes all steps of the entry or none of them.
+func (j *Journal) Redo(ctx context.Context) ffi.Result {
+	return j.apply(ctx, false)
+}
+
+// apply undoes or redoes an entry of the history
+func (j *Journal) apply(ctx context.Context, undo bool) ffi.Result {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	verb := map[bool]string{true: "undo", false: "redo"}[undo]
+	h, err := j.load()
+	if err != nil {
+		return ffi.Result{Success: false, Message: fmt.Sprintf("Cannot %s: %v", verb, err), Kind: ffi.KindOf(err)}
+	}
+
+	index := -1
+	for i, entry := range h.Entries {
+		if undo && !entry.Undone {
+			index = i
+		}
+		if !undo && entry.Undone {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return ffi.Result{Success: false, Message: fmt.Sprintf("Nothing to %s", verb), Kind: ffi.ErrorKindNotFound}
+	}
+
+	entry := &h.Entries[index]
+	steps := entry.Steps
+	order := make([]int, len(steps))
+	for i := range order {
+		order[i] = i
+		if undo {
+			order[i] = len(steps) - 1 - i
+		}
+	}
+	for n, i := range order {
+		var result ffi.Result
+		if undo {
+			result = j.revert(ctx, &steps[i])
+		} else {
+			result = j.replay(ctx, &steps[i])
+		}
+		if result.Success {
+			continue
+		}
+		for k := n - 1; k >= 0; k-- {
+			if undo {
+				j.replay(context.Background(), &steps[order[k]])
+			} else {
+				j.revert(context.Background(), &steps[order[k]])
+			}
+		}
+		return ffi.Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot %s '%s': %s; nothing was changed", verb, entry.Title, result.Message),
+			Kind:    result.Kind,
+		}
+	}
+
+	entry.Undone = undo
+	if err := j.save(h); err != nil {
+		return ffi.Result{
+			Success: false,
+			Message: fmt.Sprintf("Made the %s of '%s' but cannot save the history: %v", verb, entry.Title, err),
+			Kind:    ffi.KindOf(err),
+		}
+	}
+
+	message := fmt.Sprintf("Undid '%s'", entry.Title)
+	if !undo {
+		message = fmt.Sprintf("Redid '%s'", entry.Title)
+	}
+	if len(entry.Notes) > 0 {
+		message += "; not reversible: " + strings.Join(entry.Notes, "; ")
+	}
+	return ffi.Result{Success: true, Message: message}
+}
+
+// failMove keeps undo and redo from replacing entries that took the place of a moved one
+var failMove = ffi.CopyOptions{Conflict: ffi.ConflictFail}
+
+// revert reverses a step
+func (j *Journal) revert(ctx context.Context, step *Step) ffi.Result {
+	switch step.Op {
+	case StepCreate:
+		// What was created may have been deleted since
+		if _, err := j.backend.Lstat(step.Path); errors.Is(err, fs.ErrNotExist) {
+			step.Trashed = ""
+			return ffi.Result{Success: true}
+		}
+		return j.putInTrash(ctx, step)
+	case StepMove:
+		return j.backend.MovePath(ctx, step.Dest, step.Path, failMove, nil)
+	case StepChmod:
+		return j.backend.ChangePermissions(ctx, step.Path, step.OldMode)
+	case StepChown:
+		return j.backend.ChangeOwner(ctx, step.Path, step.OldUID, step.OldGID, step.NoFollow)
+	case StepTrash:
+		return j.trash.Restore(ctx, step.Trashed)
+	case StepRestore:
+		return j.putInTrash(ctx, step)
+	}
+	return unknownStep(step)
+}
+
+// replay makes a step again
+func (j *Journal) replay(ctx context.Context, step *Step) ffi.Result {
+	switch step.Op {
+	case StepCreate:
+		if step.Trashed == "" {
+			return ffi.Result{
+				Success: false,
+				Message: fmt.Sprintf("'%s' was already gone when it was undone and cannot be recreated", step.Path),
+				Kind:    ffi.ErrorKindNotFound,
+			}
+		}
+		return j.restore(ctx, step)
+	case StepMove:
+		return j.backend.MovePath(ctx, step.Path, step.Dest, failMove, nil)
+	case StepChmod:
+		return j.backend.ChangePermissions(ctx, step.Path, step.NewMode)
+	case StepChown:
+		return j.backend.ChangeOwner(ctx, step.Path, step.NewUID, step.NewGID, step.NoFollow)
+	case StepTrash:
+		return j.putInTrash(ctx, step)
+	case StepRestore:
+		return j.restore(ctx, step)
+	}
+	return unknownStep(step)
+}
+
+// putInTrash moves the path of step to the trash and remembers where it went
+func (j *Journal) putInTrash(ctx context.Context, step *Step) ffi.Result {
+	trashed, result := j.trash.PutPath(ctx, step.Path, nil)
+	if result.Success {
+		step.Trashed = trashed
+	}
+	return result
+}
+
+// restore moves the path of step back from the trash
+func (j *Journal) restore(ctx context.Context, step *Step) ffi.Result {
+	result := j.trash.Restore(ctx, step.Trashed)
+	if result.Success && step.Op == StepCreate {
+		step.Trashed = ""
+	}
+	return result
+}
+
+// unknownStep fails a step written by a newer version
+func unknownStep(step *Step) ffi.Result {
+	return ffi.Result{
+		Success: false,
+		Message: fmt.Sprintf("Unknown change '%s' of '%s'", step.Op, step.Path),
+		Kind:    ffi.ErrorKindInvalidPath,
+	}
+}
+
+// trashRecorder records the entries a trash moves in a journal
+type trashRecorder struct {
+	journal *Journal
+}
+
+func (r trashRecorder) Trashed(ctx context.Context, original, trashed string) {
+	r.journal.record(ctx, fmt.Sprintf("Move %s to the trash", original),
+		[]Step{{Op: StepTrash, Path: original, Trashed: trashed}})
+}
+
+func (r trashRecorder) Restored(ctx context.Context, trashed, original string) {
+	r.journal.record(ctx, fmt.Sprintf("Restore %s from the trash", original),
+		[]Step{{Op: StepRestore, Path: original, Trashed: trashed}})
+}
@@ -0,0 +1,181 @@
+package journal
+
+import (
+	"context"
+	"filemanager/internal/ffi"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newJournal returns a journal of a memory backend holding /work/report.txt
+func newJournal(t *testing.T, path string) (*Journal, *ffi.MemoryBackend) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", "/data")
+	m := ffi.NewMemoryBackend()
+	m.CreateFolder(context.Background(), "/work", ffi.CreateOptions{Parents: true})
+	m.WriteFile(context.Background(), "/work/report.txt", []byte("report"), ffi.WriteOptions{})
+	return New(m, path), m
+}
+
+// assertExists fails unless every path exists and every path prefixed with ! does not
+func assertExists(t *testing.T, m *ffi.MemoryBackend, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		want := !strings.HasPrefix(path, "!")
+		path = strings.TrimPrefix(path, "!")
+		if _, err := m.Lstat(path); (err == nil) != want {
+			t.Errorf("%s: expected exists=%v, got %v", path, want, err)
+		}
+	}
+}
+
+// TestUndoRedo verifies an operation of several changes is undone and redone as a whole
+func TestUndoRedo(t *testing.T) {
+	j, m := newJournal(t, "")
+	b := j.Backend()
+
+	ctx, end := j.Begin(context.Background())
+	for _, result := range []ffi.Result{
+		b.CreateFolder(ctx, "/work/app/src", ffi.CreateOptions{Parents: true}),
+		b.WriteFile(ctx, "/work/app/src/main.go", []byte("package main"), ffi.WriteOptions{}),
+		b.RenamePath(ctx, "/work/report.txt", "/work/app/report.txt"),
+		b.ChangePermissions(ctx, "/work/app/report.txt", 0600),
+	} {
+		if !result.Success {
+			t.Fatal(result.Message)
+		}
+	}
+	end()
+
+	entries, err := j.History()
+	if err != nil || len(entries) != 1 || len(entries[0].Steps) != 4 {
+		t.Fatalf("Expected a single entry of 4 steps, got %+v (%v)", entries, err)
+	}
+	if title := entries[0].Title; title != "Create folder /work/app/src and 3 more changes" {
+		t.Errorf("Unexpected title %q", title)
+	}
+
+	if result := j.Undo(context.Background()); !result.Success {
+		t.Fatal(result.Message)
+	}
+	assertExists(t, m, "/work/report.txt", "!/work/app")
+	if info, _ := m.Lstat("/work/report.txt"); ffi.PermissionBits(info.Mode()) == 0600 {
+		t.Error("Permissions were not restored")
+	}
+	if result := j.Undo(context.Background()); result.Success || result.Kind != ffi.ErrorKindNotFound {
+		t.Errorf("Expected nothing to undo, got %+v", result)
+	}
+
+	if result := j.Redo(context.Background()); !result.Success {
+		t.Fatal(result.Message)
+	}
+	assertExists(t, m, "!/work/report.txt", "/work/app/src/main.go", "/work/app/report.txt")
+	if info, _ := m.Lstat("/work/app/report.txt"); ffi.PermissionBits(info.Mode()) != 0600 {
+		t.Error("Permissions were not changed again")
+	}
+}
+
+// TestUndoTrash verifies deletions to the trash and restores are reversed through the trash
+func TestUndoTrash(t *testing.T) {
+	j, m := newJournal(t, "")
+	trash := j.Trash()
+
+	if result := trash.Put(context.Background(), "/work/report.txt", nil); !result.Success {
+		t.Fatal(result.Message)
+	}
+	if result := j.Undo(context.Background()); !result.Success {
+		t.Fatal(result.Message)
+	}
+	assertExists(t, m, "/work/report.txt")
+
+	if result := j.Redo(context.Background()); !result.Success {
+		t.Fatal(result.Message)
+	}
+	assertExists(t, m, "!/work/report.txt")
+	items, _ := trash.List()
+	if len(items) != 1 {
+		t.Fatalf("Expected the report in the trash, got %+v", items)
+	}
+	if result := j.Undo(context.Background()); !result.Success {
+		t.Fatal(result.Message)
+	}
+	assertExists(t, m, "/work/report.txt")
+}
+
+// TestUndoIsAtomic verifies an entry that cannot be undone completely is left as it was
+func TestUndoIsAtomic(t *testing.T) {
+	j, m := newJournal(t, "")
+	b := j.Backend()
+
+	ctx, end := j.Begin(context.Background())
+	b.RenamePath(ctx, "/work/report.txt", "/work/old.txt")
+	b.CreateFolder(ctx, "/work/new", ffi.CreateOptions{Parents: true})
+	end()
+	// The name the rename would go back to is taken again
+	m.WriteFile(context.Background(), "/work/report.txt", []byte("newer"), ffi.WriteOptions{})
+
+	result := j.Undo(context.Background())
+	if result.Success || result.Kind != ffi.ErrorKindAlreadyExists || !strings.Contains(result.Message, "nothing was changed") {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	assertExists(t, m, "/work/old.txt", "/work/new")
+}
+
+// TestRecording verifies what the recording backend notes and leaves out
+func TestRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	j, m := newJournal(t, path)
+	b := j.Backend()
+	ctx := context.Background()
+
+	// Entries created and removed within an operation leave nothing to undo
+	opCtx, end := j.Begin(ctx)
+	b.WriteFile(opCtx, "/work/tmp", nil, ffi.WriteOptions{})
+	b.Remove(opCtx, "/work/tmp")
+	end()
+	if entries, _ := j.History(); len(entries) != 0 {
+		t.Fatalf("Expected no entries, got %+v", entries)
+	}
+
+	opCtx, end = j.Begin(ctx)
+	b.WriteFile(opCtx, "/work/report.txt", []byte("changed"), ffi.WriteOptions{})
+	b.CopyPath(opCtx, "/work/report.txt", "/backup/report.txt", ffi.CopyOptions{}, nil)
+	end()
+	b.MovePath(ctx, "/work/report.txt", "/backup/report.txt", ffi.CopyOptions{Conflict: ffi.ConflictKeepBoth}, nil)
+
+	// A second journal on the same file sees the entries
+	entries, err := New(m, path).History()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v (%v)", entries, err)
+	}
+	if step := entries[0].Steps[0]; step.Op != StepMove || step.Dest != "/backup/report (1).txt" {
+		t.Errorf("Expected a move to the new name, got %+v", step)
+	}
+	copied := entries[1]
+	if len(copied.Steps) != 2 || copied.Steps[0].Path != "/backup" || len(copied.Notes) != 1 {
+		t.Errorf("Expected the backup folder, the copy and a note on the overwritten report, got %+v", copied)
+	}
+
+	// Undoing the move and recording something new drops the move from the history
+	if result := j.Undo(ctx); !result.Success {
+		t.Fatal(result.Message)
+	}
+	b.ChangePermissions(ctx, "/work/report.txt", 0600)
+	entries, _ = j.History()
+	if len(entries) != 2 || entries[0].Steps[0].Op != StepChmod || entries[1].ID != copied.ID {
+		t.Errorf("Expected the chmod after the copy, got %+v", entries)
+	}
+	if result := j.Redo(ctx); result.Success {
+		t.Error("Expected nothing to redo after a new change")
+	}
+
+	// Undoing a file created with its folders removes the folders too
+	if result := b.CreateFile(ctx, "/new/deep/file.txt", ffi.CreateOptions{Parents: true}); !result.Success {
+		t.Fatal(result.Message)
+	}
+	if result := j.Undo(ctx); !result.Success {
+		t.Fatal(result.Message)
+	}
+	assertExists(t, m, "!/new")
+}
@@ -1,56 +1,83 @@
 package service
 
 import (
+	"context"
 	"filemanager/internal/ffi"
 	"fmt"
-	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 )
 
-// CreateFromTemplate creates a project structure from a template
-func CreateFromTemplate(rootPath string, template StructureTemplate) (int, int) {
-	successCount := 0
-	errorCount := 0
+// FileService runs multi-step file operations on a filesystem backend
+type FileService struct {
+	backend ffi.Backend
+}
 
-	result := ffi.CreateFolder(rootPath)
-	if !result.Success {
-		fmt.Printf("❌ Failed to create root: %s\n", result.Message)
-		return 0, 1
-	}
-	successCount++
+// NewFileService creates a FileService that runs its operations on backend
+func NewFileService(backend ffi.Backend) *FileService {
+	return &FileService{backend: backend}
+}
+
+// Backend returns the backend the service runs its operations on
+func (s *FileService) Backend() ffi.Backend {
+	return s.backend
+}
+
+// NewBatch creates a batch on the service's backend using BatchParallelism workers
+func (s *FileService) NewBatch() *ffi.BatchOperation {
+	batch := ffi.NewBatchOperation(s.backend)
+	batch.SetParallelism(BatchParallelism)
+	return batch
+}
+
+// CreateFromTemplate creates a project structure from a template, stopping when ctx is cancelled
+// It returns the number of items created and failed, and the kind of the failure.
+// When transactional is set, creation is all-or-nothing: if any item fails, the items
+// created so far are removed again.
+func (s *FileService) CreateFromTemplate(ctx context.Context, rootPath string, template StructureTemplate, transactional bool) (int, int, ffi.ErrorKind) {
+	batch := s.NewBatch()
+	batch.SetTransactional(transactional)
+
+	paths := []string{rootPath}
+	batch.AddCreateFolder(rootPath)
 
 	for _, dir := range template.Directories {
 		fullPath := filepath.Join(rootPath, dir)
-		result := ffi.CreateFolder(fullPath)
-		if result.Success {
-			successCount++
-			fmt.Printf("  ✅ 📁 %s\n", fullPath)
-		} else {
-			errorCount++
-			fmt.Printf("  ❌ %s: %s\n", fullPath, result.Message)
-		}
+		paths = append(paths, fullPath)
+		batch.AddCreateFolder(fullPath)
 	}
 
-	for filePath, content := range template.Files {
+	filePaths := make([]string, 0, len(template.Files))
+	for filePath := range template.Files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	for _, filePath := range filePaths {
 		fullPath := filepath.Join(rootPath, filePath)
-		result := ffi.CreateFile(fullPath)
+		paths = append(paths, fullPath)
+		batch.AddCreateFileWithContent(fullPath, []byte(template.Files[filePath]))
+	}
+
+	for i, result := range batch.ExecuteContext(ctx) {
 		if result.Success {
-			if err := os.WriteFile(fullPath, []byte(content), 0644); err == nil {
-				successCount++
-				fmt.Printf("  ✅ 📄 %s\n", fullPath)
-			} else {
-				errorCount++
-				fmt.Printf("  ❌ %s: %s\n", fullPath, err.Error())
+			icon := "📁"
+			if i > len(template.Directories) {
+				icon = "📄"
 			}
+			fmt.Printf("  ✅ %s %s\n", icon, paths[i])
 		} else {
-			errorCount++
-			fmt.Printf("  ❌ %s: %s\n", fullPath, result.Message)
+			fmt.Printf("  ❌ %s: %s\n", paths[i], result.Message)
 		}
 	}
+	if batch.RolledBack() {
+		fmt.Println("  ↩️  Creation failed, all created items were removed")
+	}
 
-	return successCount, errorCount
+	success, failed := batch.GetSummary()
+	return success, failed, batch.FailureKind()
 }
 
 // ParseTreeStructure parses a tree-like structure from pasted text
@@ -200,44 +227,38 @@ func hasFileExtension(name string) bool {
 	return false
 }
 
+// BatchParallelism is the number of workers used by the batch helpers
+var BatchParallelism = runtime.NumCPU()
+
 // BatchCreateFiles creates multiple files in batch
-func BatchCreateFiles(paths []string) (successCount int, errorCount int) {
+func (s *FileService) BatchCreateFiles(paths []string) (successCount int, errorCount int) {
+	// Create parent directories if needed
+	parents := s.NewBatch()
+	seen := make(map[string]bool)
 	for _, path := range paths {
-		// Create parent directories if needed
 		dir := filepath.Dir(path)
-		if dir != "." && dir != path {
-			ffi.CreateFolder(dir)
+		if dir != "." && dir != path && !seen[dir] {
+			seen[dir] = true
+			parents.AddCreateFolder(dir)
 		}
+	}
+	parents.Execute()
 
-		result := ffi.CreateFile(path)
-		if result.Success {
-			successCount++
-		} else {
-			errorCount++
-		}
+	batch := s.NewBatch()
+	for _, path := range paths {
+		batch.AddCreateFile(path)
 	}
-	return
+	batch.Execute()
+	return batch.GetSummary()
 }
 
 // BatchCreateFolders creates multiple folders in batch
-func BatchCreateFolders(paths []string) (successCount int, errorCount int) {
-	// Sort by depth to ensure parent directories are created first
-	sort.Slice(paths, func(i, j int) bool {
-		depthI := strings.Count(paths[i], string(os.PathSeparator))
-		depthJ := strings.Count(paths[j], string(os.PathSeparator))
-		if depthI != depthJ {
-			return depthI < depthJ
-		}
-		return paths[i] < paths[j]
-	})
-
+// Parent directories are created before their children
+func (s *FileService) BatchCreateFolders(paths []string) (successCount int, errorCount int) {
+	batch := s.NewBatch()
 	for _, path := range paths {
-		result := ffi.CreateFolder(path)
-		if result.Success {
-			successCount++
-		} else {
-			errorCount++
-		}
+		batch.AddCreateFolder(path)
 	}
-	return
-}
\ No newline at end of file
+	batch.Execute()
+	return batch.GetSummary()
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"filemanager/internal/ffi"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestCreateFromTemplate verifies every directory and file of a template is created with its content
+func TestCreateFromTemplate(t *testing.T) {
+	backend := ffi.NewMemoryBackend()
+	template := StructureTemplate{
+		Name:        "small",
+		Directories: []string{"cmd/app", "docs"},
+		Files: map[string]string{
+			"cmd/app/main.go": "package main\n",
+			"README.md":       "# small\n",
+		},
+	}
+
+	success, failed, _ := NewFileService(backend).CreateFromTemplate(context.Background(), "/work/project", template, false)
+	if success != 5 || failed != 0 {
+		t.Fatalf("Expected 5 created and 0 failed, got %d and %d", success, failed)
+	}
+
+	for path, content := range template.Files {
+		data, err := backend.ReadFile(filepath.Join("/work/project", path))
+		if err != nil || string(data) != content {
+			t.Errorf("%s: got %q (%v), expected %q", path, data, err, content)
+		}
+	}
+	if _, err := backend.Lstat("/work/project/docs"); err != nil {
+		t.Errorf("Directory was not created: %v", err)
+	}
+}
+
+// TestCreateFromTemplateRollback verifies a failed template removes everything it created
+func TestCreateFromTemplateRollback(t *testing.T) {
+	ctx := context.Background()
+	backend := ffi.NewMemoryBackend()
+	backend.CreateFolder(ctx, "/work/project", ffi.CreateOptions{Parents: true})
+	backend.WriteFile(ctx, "/work/project/docs", []byte("a file where a folder belongs"), ffi.WriteOptions{})
+
+	template := StructureTemplate{
+		Name:        "blocked",
+		Directories: []string{"src/pkg", "docs/api"},
+		Files:       map[string]string{"src/pkg/lib.go": "package pkg\n"},
+	}
+
+	success, failed, kind := NewFileService(backend).CreateFromTemplate(ctx, "/work/project", template, true)
+	if success != 0 || failed == 0 || kind == ffi.ErrorKindCancelled || kind == ffi.ErrorKindNone {
+		t.Errorf("Expected every item to fail for the blocked folder, got %d created and %d failed (%v)", success, failed, kind)
+	}
+
+	expected := []string{"/work", "/work/project", "/work/project/docs"}
+	if paths := backend.Paths(); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected only the existing entries to remain, got %v", paths)
+	}
+
+	// Without a transaction the items that could be created are kept
+	success, _, _ = NewFileService(backend).CreateFromTemplate(ctx, "/work/project", template, false)
+	if _, err := backend.Lstat("/work/project/src/pkg/lib.go"); success == 0 || err != nil {
+		t.Errorf("Expected the items outside docs to be kept, got %d created (%v)", success, err)
+	}
+
+	// A cancelled context creates nothing
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if success, _, _ := NewFileService(backend).CreateFromTemplate(cancelled, "/work/other", template, false); success != 0 {
+		t.Errorf("Expected a cancelled template to create nothing, got %d items", success)
+	}
+}
+
+// TestCreateFromTemplateKeepsExistingFiles verifies a rolled-back template puts back
+// the content of the files it wrote over
+func TestCreateFromTemplateKeepsExistingFiles(t *testing.T) {
+	defer func(parallelism int) { BatchParallelism = parallelism }(BatchParallelism)
+	// One worker writes the README before the blocked file fails
+	BatchParallelism = 1
+
+	ctx := context.Background()
+	backend := ffi.NewMemoryBackend()
+	backend.CreateFolder(ctx, "/work/project/src/pkg/lib.go", ffi.CreateOptions{Parents: true})
+	backend.WriteFile(ctx, "/work/project/README.md", []byte("my notes"), ffi.WriteOptions{})
+
+	template := StructureTemplate{
+		Name:        "over",
+		Directories: []string{"src/pkg"},
+		Files:       map[string]string{"README.md": "# over\n", "src/pkg/lib.go": "package pkg\n"},
+	}
+	if success, _, _ := NewFileService(backend).CreateFromTemplate(ctx, "/work/project", template, true); success != 0 {
+		t.Fatalf("Expected the template to be rolled back, got %d items created", success)
+	}
+	if data, err := backend.ReadFile("/work/project/README.md"); err != nil || string(data) != "my notes" {
+		t.Errorf("Expected the README to keep its content, got %q (%v)", data, err)
+	}
+}
+
+// TestCreateParsedTree verifies a pasted tree is created with folders before their files
+func TestCreateParsedTree(t *testing.T) {
+	structure := `myapp/
+├── cmd/
+│   └── main.go
+├── internal/
+│   └── store/
+│   │   └── store.go
+└── go.mod`
+
+	dirs, files, err := ParseTreeStructure(structure)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := ffi.NewMemoryBackend()
+	service := NewFileService(backend)
+	if _, failed := service.BatchCreateFolders(dirs); failed != 0 {
+		t.Fatalf("%d folders failed", failed)
+	}
+	filePaths := make([]string, 0, len(files))
+	for path := range files {
+		filePaths = append(filePaths, path)
+	}
+	if _, failed := service.BatchCreateFiles(filePaths); failed != 0 {
+		t.Fatalf("%d files failed", failed)
+	}
+
+	expected := []string{
+		"myapp",
+		filepath.Join("myapp", "cmd"),
+		filepath.Join("myapp", "cmd", "main.go"),
+		filepath.Join("myapp", "go.mod"),
+		filepath.Join("myapp", "internal"),
+		filepath.Join("myapp", "internal", "store"),
+		filepath.Join("myapp", "internal", "store", "store.go"),
+	}
+	sort.Strings(expected)
+	if paths := backend.Paths(); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Unexpected tree:\n%v\nexpected:\n%v", paths, expected)
+	}
+}
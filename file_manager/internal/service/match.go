@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"filemanager/internal/ffi"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MatchOptions selects the entries below a folder that a bulk operation applies to
+type MatchOptions struct {
+	// Include and Exclude are glob patterns relative to the folder, with / between
+	// names on every platform: * ? and [...] match within a name and ** matches any
+	// number of folders, as in **/*.pyc. Without Include every file matches.
+	Include []string
+	Exclude []string
+	// MinSize and MaxSize bound the size of files in bytes; 0 leaves a bound open
+	MinSize int64
+	MaxSize int64
+	// OlderThan and NewerThan bound the age of files by their modification time;
+	// 0 leaves a bound open
+	OlderThan time.Duration
+	NewerThan time.Duration
+}
+
+// IsZero reports whether opts selects nothing beyond the literal path
+func (o MatchOptions) IsZero() bool {
+	return len(o.Include) == 0 && len(o.Exclude) == 0 && !o.filtersFiles()
+}
+
+// filtersFiles reports whether opts has size or age bounds
+func (o MatchOptions) filtersFiles() bool {
+	return o.MinSize > 0 || o.MaxSize > 0 || o.OlderThan > 0 || o.NewerThan > 0
+}
+
+// Match is an entry selected by MatchOptions
+type Match struct {
+	Path string
+	// Rel is the path below the folder the match was found in
+	Rel   string
+	IsDir bool
+	Size  int64
+}
+
+// HasGlob reports whether path contains glob characters
+func HasGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// IsGlob reports whether path is a glob pattern rather than the name of an entry:
+// it has glob characters and no entry goes by that exact name
+func (s *FileService) IsGlob(path string) bool {
+	if !HasGlob(path) {
+		return false
+	}
+	_, err := s.backend.Lstat(path)
+	return err != nil
+}
+
+// SplitGlob splits a path such as "build/**/*.log" into the folder a match starts
+// from, "build", and the pattern below it, "**/*.log"
+// A path without glob characters is returned as the folder with an empty pattern.
+func SplitGlob(pattern string) (string, string) {
+	pattern = filepath.ToSlash(pattern)
+	if !HasGlob(pattern) {
+		return filepath.FromSlash(pattern), ""
+	}
+
+	root := ""
+	rest := pattern
+	for {
+		name, tail, found := strings.Cut(rest, "/")
+		if !found || HasGlob(name) {
+			break
+		}
+		root += name + "/"
+		rest = tail
+	}
+	if root == "" {
+		return ".", rest
+	}
+	if root != "/" {
+		root = strings.TrimSuffix(root, "/")
+	}
+	return filepath.FromSlash(root), rest
+}
+
+// ValidatePattern reports a malformed glob pattern such as "[a-"
+func ValidatePattern(pattern string) error {
+	for _, name := range strings.Split(pattern, "/") {
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchGlob reports whether rel, a /-separated path below a folder, matches pattern
+func MatchGlob(pattern, rel string) bool {
+	return matchNames(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchNames matches the names of a path against those of a pattern
+func matchNames(pattern, names []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// ** takes any number of names, including none
+			for i := 0; i <= len(names); i++ {
+				if matchNames(pattern[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], names[0]); !ok {
+			return false
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// Glob walks root and returns the entries opts selects, in walk order
+//
+// A folder matching Include is returned whole, without its contents, unless opts
+// has Exclude patterns or size and age bounds; those only ever select files, so
+// the folders are entered instead. Folders matching Exclude are not entered.
+// Symbolic links are matched like files and never followed. A root folder is never
+// returned itself, while a root that is not a folder is only checked against the
+// size and age bounds.
+func (s *FileService) Glob(ctx context.Context, root string, opts MatchOptions) ([]Match, error) {
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if err := ValidatePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	g := &globWalk{ctx: ctx, backend: s.backend, opts: opts, now: time.Now()}
+	info, err := s.backend.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if g.accept(info) {
+			g.matches = append(g.matches, Match{Path: root, Rel: filepath.Base(root), Size: info.Size()})
+		}
+		return g.matches, nil
+	}
+	if err := g.walk(root, ""); err != nil {
+		return nil, err
+	}
+	return g.matches, nil
+}
+
+// GlobPaths returns the matches of every path in order
+// A pattern, as told by IsGlob, is split with SplitGlob and added to opts.Include;
+// any other path is the folder or file opts is applied to.
+func (s *FileService) GlobPaths(ctx context.Context, paths []string, opts MatchOptions) ([]Match, error) {
+	var matches []Match
+	for _, p := range paths {
+		root, pattern := p, ""
+		if s.IsGlob(p) {
+			root, pattern = SplitGlob(p)
+		}
+		rootOpts := opts
+		if pattern != "" {
+			rootOpts.Include = append(append([]string{}, opts.Include...), pattern)
+		}
+		found, err := s.Glob(ctx, root, rootOpts)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, found...)
+	}
+	return matches, nil
+}
+
+// globWalk holds the state of a Glob walk
+type globWalk struct {
+	ctx     context.Context
+	backend ffi.Backend
+	opts    MatchOptions
+	now     time.Time
+	matches []Match
+}
+
+// walk matches the entries of the folder dir, whose path below the root is rel
+func (g *globWalk) walk(dir, rel string) error {
+	entries, err := g.backend.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := g.ctx.Err(); err != nil {
+			return err
+		}
+		entryRel := path.Join(rel, entry.Name())
+		entryPath := filepath.Join(dir, entry.Name())
+		if matchAny(g.opts.Exclude, entryRel) {
+			continue
+		}
+
+		info, err := g.backend.Lstat(entryPath)
+		if err != nil {
+			// Entries removed during the walk are not matched
+			continue
+		}
+
+		if info.IsDir() {
+			wholeFolder := len(g.opts.Exclude) == 0 && !g.opts.filtersFiles()
+			if wholeFolder && matchAny(g.opts.Include, entryRel) {
+				g.matches = append(g.matches, Match{Path: entryPath, Rel: filepath.FromSlash(entryRel), IsDir: true})
+				continue
+			}
+			if err := g.walk(entryPath, entryRel); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(g.opts.Include) > 0 && !matchAny(g.opts.Include, entryRel) {
+			continue
+		}
+		if !g.accept(info) {
+			continue
+		}
+		g.matches = append(g.matches, Match{Path: entryPath, Rel: filepath.FromSlash(entryRel), Size: info.Size()})
+	}
+	return nil
+}
+
+// accept applies the size and age bounds to a file
+func (g *globWalk) accept(info fs.FileInfo) bool {
+	o := g.opts
+	age := g.now.Sub(info.ModTime())
+	switch {
+	case o.MinSize > 0 && info.Size() < o.MinSize:
+		return false
+	case o.MaxSize > 0 && info.Size() > o.MaxSize:
+		return false
+	case o.OlderThan > 0 && age < o.OlderThan:
+		return false
+	case o.NewerThan > 0 && age > o.NewerThan:
+		return false
+	}
+	return true
+}
+
+// matchAny reports whether rel matches one of patterns
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyMatches runs op on every match in order and returns the results
+// It stops once ctx is cancelled, reporting the remaining matches as cancelled.
+func (s *FileService) ApplyMatches(ctx context.Context, matches []Match, op func(context.Context, Match) ffi.Result) []ffi.Result {
+	results := make([]ffi.Result, len(matches))
+	for i, match := range matches {
+		if ctx.Err() != nil {
+			results[i] = ffi.Result{
+				Success: false,
+				Message: fmt.Sprintf("Cancelled before '%s'", match.Path),
+				Kind:    ffi.ErrorKindCancelled,
+			}
+			continue
+		}
+		results[i] = op(ctx, match)
+	}
+	return results
+}
+
+// TransferMatches copies every match into dest at its path below the folder it was
+// found in, or moves it when move is set, creating the folders in between
+func (s *FileService) TransferMatches(ctx context.Context, matches []Match, dest string, move bool, opts ffi.CopyOptions) []ffi.Result {
+	created := make(map[string]bool)
+	return s.ApplyMatches(ctx, matches, func(ctx context.Context, match Match) ffi.Result {
+		target := filepath.Join(dest, match.Rel)
+		if parent := filepath.Dir(target); !created[parent] {
+			if result := s.backend.CreateFolder(ctx, parent, ffi.CreateOptions{Parents: true}); !result.Success {
+				return result
+			}
+			created[parent] = true
+		}
+		if move {
+			return s.backend.MovePath(ctx, match.Path, target, opts, nil)
+		}
+		return s.backend.CopyPath(ctx, match.Path, target, opts, nil)
+	})
+}
+
+// ParseSize parses a size such as "1500", "10K", "2.5M" or "1G", in binary units
+func ParseSize(text string) (int64, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+	number := strings.TrimSuffix(strings.TrimSuffix(text, "B"), "I")
+	unit := 1.0
+	if n := len(number); n > 0 {
+		if i := strings.IndexByte("KMGT", number[n-1]); i >= 0 {
+			unit = float64(int64(1) << (10 * (i + 1)))
+			number = number[:n-1]
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected bytes or a number with K, M, G or T)", text)
+	}
+	return int64(value * unit), nil
+}
+
+// ParseAge parses an age such as "90m", "12h", "7d" or "2w"
+// Units up to hours are those of time.ParseDuration.
+func ParseAge(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if n := len(text); n > 1 {
+		if unit, ok := units[text[n-1]]; ok {
+			value, err := strconv.ParseFloat(text[:n-1], 64)
+			if err == nil && value >= 0 {
+				return time.Duration(value * float64(unit)), nil
+			}
+		}
+	}
+	age, err := time.ParseDuration(text)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q (expected a number with m, h, d or w such as 7d)", text)
+	}
+	return age, nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"filemanager/internal/ffi"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"**/*.pyc", "app.pyc", true},
+		{"**/*.pyc", "pkg/sub/mod.pyc", true},
+		{"**/*.pyc", "pkg/mod.py", false},
+		{"*.pyc", "pkg/mod.pyc", false},
+		{"build/**/*.log", "build/a/b/run.log", true},
+		{"build/**/*.log", "build/run.log", true},
+		{"build/**/*.log", "src/build/run.log", false},
+		{"build/**", "build/a/b", true},
+		{"**/__pycache__", "a/__pycache__", true},
+		{"src/[ab]?.go", "src/a1.go", true},
+		{"src/[ab]?.go", "src/c1.go", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, expected %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+
+	if err := ValidatePattern("src/[a-"); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
+
+func TestSplitGlob(t *testing.T) {
+	tests := []struct{ path, root, pattern string }{
+		{"build/**/*.log", "build", "**/*.log"},
+		{"/tmp/logs/*.log", filepath.FromSlash("/tmp/logs"), "*.log"},
+		{"/*.log", filepath.FromSlash("/"), "*.log"},
+		{"*.txt", ".", "*.txt"},
+		{"docs/readme.md", filepath.FromSlash("docs/readme.md"), ""},
+	}
+	for _, tt := range tests {
+		root, pattern := SplitGlob(tt.path)
+		if root != tt.root || pattern != tt.pattern {
+			t.Errorf("SplitGlob(%q) = %q, %q, expected %q, %q", tt.path, root, pattern, tt.root, tt.pattern)
+		}
+	}
+}
+
+// TestGlob verifies matching folders are taken whole unless filters have to look inside them
+func TestGlob(t *testing.T) {
+	ctx := context.Background()
+	backend := ffi.NewMemoryBackend()
+	for path, size := range map[string]int{
+		"/work/app.pyc":                   10,
+		"/work/pkg/mod.pyc":               2000,
+		"/work/pkg/mod.py":                5,
+		"/work/pkg/__pycache__/mod.pyc":   30,
+		"/work/vendor/lib/lib.pyc":        40,
+		"/work/pkg/__pycache__/keep.txt":  1,
+		"/work/build/logs/run.log":        1,
+		"/work/build/logs/nested/err.log": 1,
+	} {
+		backend.CreateFolder(ctx, filepath.Dir(path), ffi.CreateOptions{Parents: true})
+		backend.WriteFile(ctx, path, make([]byte, size), ffi.WriteOptions{})
+	}
+	service := NewFileService(backend)
+
+	paths := func(opts MatchOptions) []string {
+		t.Helper()
+		matches, err := service.Glob(ctx, "/work", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rels []string
+		for _, match := range matches {
+			rels = append(rels, filepath.ToSlash(match.Rel))
+		}
+		return rels
+	}
+
+	got := paths(MatchOptions{Include: []string{"**/*.pyc", "**/__pycache__"}})
+	want := []string{"app.pyc", "pkg/__pycache__", "pkg/mod.pyc", "vendor/lib/lib.pyc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	got = paths(MatchOptions{Include: []string{"**/*.pyc"}, Exclude: []string{"vendor"}, MaxSize: 1024})
+	want = []string{"app.pyc", "pkg/__pycache__/mod.pyc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if got := paths(MatchOptions{MinSize: 1000}); !reflect.DeepEqual(got, []string{"pkg/mod.pyc"}) {
+		t.Errorf("Expected only the large file, got %v", got)
+	}
+	if got := paths(MatchOptions{OlderThan: time.Hour}); len(got) != 0 {
+		t.Errorf("Expected no old files, got %v", got)
+	}
+
+	matches, err := service.GlobPaths(ctx, []string{"/work/build/**/*.log"}, MatchOptions{})
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("Expected 2 logs, got %v (%v)", matches, err)
+	}
+	results := service.TransferMatches(ctx, matches, "/archive", true, ffi.CopyOptions{})
+	for _, result := range results {
+		if !result.Success {
+			t.Fatal(result.Message)
+		}
+	}
+	for _, path := range []string{"/archive/logs/run.log", "/archive/logs/nested/err.log"} {
+		if _, err := backend.Lstat(path); err != nil {
+			t.Errorf("%s was not moved: %v", path, err)
+		}
+	}
+	if _, err := backend.Lstat("/work/build/logs/run.log"); err == nil {
+		t.Error("Moved file is still in place")
+	}
+}
+
+func TestParseSizeAndAge(t *testing.T) {
+	sizes := map[string]int64{"1500": 1500, "10K": 10 << 10, "2.5M": 5 << 19, "1GiB": 1 << 30, "3kb": 3 << 10}
+	for text, want := range sizes {
+		if got, err := ParseSize(text); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, expected %d", text, got, err, want)
+		}
+	}
+	ages := map[string]time.Duration{"90m": 90 * time.Minute, "7d": 7 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "1.5h": 90 * time.Minute}
+	for text, want := range ages {
+		if got, err := ParseAge(text); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v, expected %v", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"", "ten", "-5M"} {
+		if _, err := ParseSize(text); err == nil {
+			t.Errorf("ParseSize(%q): expected an error", text)
+		}
+		if _, err := ParseAge(text); err == nil {
+			t.Errorf("ParseAge(%q): expected an error", text)
+		}
+	}
+}
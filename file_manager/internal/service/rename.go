@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"filemanager/internal/ffi"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RenameRule describes how PlanRenames derives the new name of every entry
+//
+// Find is a regular expression whose matches in the name are replaced by Replace,
+// which may refer to groups as $1; without Find, Replace is the whole new name.
+// Replace may hold the tokens {n} for a sequence number, padded as in {n:03},
+// {date} for the modification date, formatted as in {date:YYYYMMDD-hhmmss},
+// {name} for the name without its extension and {ext} for the extension with its dot.
+// Case ("lower", "upper" or "title") and Slugify then change the new name without
+// its extension.
+type RenameRule struct {
+	Find    string
+	Replace string
+	Case    string
+	Slugify bool
+	// Start is the first sequence number; 0 starts at 1
+	Start int
+}
+
+// Rename cases for RenameRule
+const (
+	CaseLower = "lower"
+	CaseUpper = "upper"
+	CaseTitle = "title"
+)
+
+// Rename is the renaming of one entry
+// Conflict tells why the entry cannot be renamed, or is empty when it can.
+type Rename struct {
+	Old      string
+	New      string
+	Conflict string
+}
+
+// RenamePlan lists the renames of a bulk rename in the order of the selection
+type RenamePlan struct {
+	Renames []Rename
+	// Unchanged counts the entries that would keep their name and are left out
+	Unchanged int
+	// Conflicts counts the renames with a conflict; a plan with conflicts is not applied
+	Conflicts int
+}
+
+// renameToken finds the tokens of a replacement
+var renameToken = regexp.MustCompile(`\{(n|date|name|ext)(?::([^}]*))?\}`)
+
+// dateTokens maps the tokens of a {date:...} format to the parts of a time
+var dateTokens = []struct {
+	token  string
+	format func(time.Time) string
+}{
+	{"YYYY", func(t time.Time) string { return fmt.Sprintf("%04d", t.Year()) }},
+	{"YY", func(t time.Time) string { return fmt.Sprintf("%02d", t.Year()%100) }},
+	{"MM", func(t time.Time) string { return fmt.Sprintf("%02d", int(t.Month())) }},
+	{"DD", func(t time.Time) string { return fmt.Sprintf("%02d", t.Day()) }},
+	{"hh", func(t time.Time) string { return fmt.Sprintf("%02d", t.Hour()) }},
+	{"mm", func(t time.Time) string { return fmt.Sprintf("%02d", t.Minute()) }},
+	{"ss", func(t time.Time) string { return fmt.Sprintf("%02d", t.Second()) }},
+}
+
+// PlanRenames works out the new name of every match and checks the renames for
+// invalid names, entries that would end up with the same name and entries that
+// already exist
+// Renames within the selection, such as swapping two names, are not conflicts.
+func (s *FileService) PlanRenames(matches []Match, rule RenameRule) (RenamePlan, error) {
+	var find *regexp.Regexp
+	if rule.Find != "" {
+		var err error
+		if find, err = regexp.Compile(rule.Find); err != nil {
+			return RenamePlan{}, fmt.Errorf("invalid expression %q: %w", rule.Find, err)
+		}
+	} else if rule.Replace == "" && rule.Case == "" && !rule.Slugify {
+		return RenamePlan{}, fmt.Errorf("no rename rule given")
+	}
+	switch rule.Case {
+	case "", CaseLower, CaseUpper, CaseTitle:
+	default:
+		return RenamePlan{}, fmt.Errorf("unknown case %q (expected lower, upper or title)", rule.Case)
+	}
+	for _, token := range renameToken.FindAllStringSubmatch(rule.Replace, -1) {
+		if token[1] == "n" && token[2] != "" {
+			if _, err := strconv.ParseUint(token[2], 10, 8); err != nil {
+				return RenamePlan{}, fmt.Errorf("invalid sequence width %q in %s", token[2], token[0])
+			}
+		}
+	}
+
+	number := rule.Start
+	if number == 0 {
+		number = 1
+	}
+	var plan RenamePlan
+	for _, match := range matches {
+		name := filepath.Base(match.Path)
+		newName := name
+		if find != nil {
+			newName = find.ReplaceAllString(name, rule.Replace)
+		} else if rule.Replace != "" {
+			newName = rule.Replace
+		}
+		if renameToken.MatchString(newName) {
+			var modTime time.Time
+			if info, err := s.backend.Lstat(match.Path); err == nil {
+				modTime = info.ModTime()
+			}
+			newName = expandRenameTokens(newName, name, number, modTime)
+		}
+		newName = changeCase(newName, rule)
+		number++
+
+		if newName == name {
+			plan.Unchanged++
+			continue
+		}
+		plan.Renames = append(plan.Renames, Rename{Old: match.Path, New: filepath.Join(filepath.Dir(match.Path), newName)})
+	}
+
+	s.checkRenames(&plan)
+	return plan, nil
+}
+
+// expandRenameTokens replaces the tokens of a new name for the entry called name
+func expandRenameTokens(newName, name string, number int, modTime time.Time) string {
+	ext := filepath.Ext(name)
+	return renameToken.ReplaceAllStringFunc(newName, func(token string) string {
+		parts := renameToken.FindStringSubmatch(token)
+		switch parts[1] {
+		case "n":
+			width, _ := strconv.Atoi(parts[2])
+			return fmt.Sprintf("%0*d", width, number)
+		case "date":
+			format := parts[2]
+			if format == "" {
+				format = "YYYY-MM-DD"
+			}
+			pairs := make([]string, 0, 2*len(dateTokens))
+			for _, t := range dateTokens {
+				pairs = append(pairs, t.token, t.format(modTime))
+			}
+			return strings.NewReplacer(pairs...).Replace(format)
+		case "name":
+			return strings.TrimSuffix(name, ext)
+		default:
+			return ext
+		}
+	})
+}
+
+// changeCase applies the case and slug conversions of rule to a name without its extension
+func changeCase(name string, rule RenameRule) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" {
+		// A dotfile such as .env has no extension
+		stem, ext = name, ""
+	}
+
+	switch rule.Case {
+	case CaseLower:
+		stem = strings.ToLower(stem)
+	case CaseUpper:
+		stem = strings.ToUpper(stem)
+	case CaseTitle:
+		runes := []rune(strings.ToLower(stem))
+		for i := range runes {
+			if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+				runes[i] = unicode.ToUpper(runes[i])
+			}
+		}
+		stem = string(runes)
+	}
+
+	if rule.Slugify {
+		stem = slugify(stem)
+		ext = strings.ToLower(ext)
+	}
+	return stem + ext
+}
+
+// slugify lowercases name and joins its runs of letters and digits with dashes
+func slugify(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// checkRenames records the conflicts of the renames of plan
+func (s *FileService) checkRenames(plan *RenamePlan) {
+	sources := make(map[string]bool, len(plan.Renames))
+	targets := make(map[string]int, len(plan.Renames))
+	for _, rename := range plan.Renames {
+		sources[rename.Old] = true
+		targets[rename.New]++
+	}
+
+	for i := range plan.Renames {
+		rename := &plan.Renames[i]
+		newName := filepath.Base(rename.New)
+		switch {
+		case newName == "" || newName == "." || newName == ".." || strings.ContainsAny(newName, `/\`) ||
+			filepath.Dir(rename.New) != filepath.Dir(rename.Old):
+			rename.Conflict = fmt.Sprintf("invalid name %q", newName)
+		case targets[rename.New] > 1:
+			rename.Conflict = fmt.Sprintf("%d entries would be named %s", targets[rename.New], newName)
+		case !sources[rename.New] && s.exists(rename.New, rename.Old):
+			rename.Conflict = fmt.Sprintf("%s already exists", newName)
+		}
+		if rename.Conflict != "" {
+			plan.Conflicts++
+		}
+	}
+}
+
+// exists reports whether path exists as another entry than old, which it may name
+// on filesystems that ignore case
+func (s *FileService) exists(path, old string) bool {
+	info, err := s.backend.Lstat(path)
+	if err != nil {
+		return false
+	}
+	oldInfo, err := s.backend.Lstat(old)
+	return err != nil || !os.SameFile(info, oldInfo)
+}
+
+// ApplyRenames makes the renames of a plan without conflicts
+//
+// Entries are renamed in an order that never replaces one another, going through
+// a temporary name where names are swapped in a cycle, and a target that appeared
+// since the plan was made fails the rename rather than being replaced. When a
+// rename fails, the ones already made are undone.
+func (s *FileService) ApplyRenames(ctx context.Context, plan RenamePlan) ffi.Result {
+	if plan.Conflicts > 0 {
+		return ffi.Result{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d renames have conflicts", plan.Conflicts, len(plan.Renames)),
+			Kind:    ffi.ErrorKindAlreadyExists,
+		}
+	}
+
+	type step struct{ from, to string }
+	var done []step
+	rename := func(from, to string) ffi.Result {
+		if _, err := s.backend.Lstat(to); err == nil && !strings.EqualFold(from, to) {
+			return ffi.Result{
+				Success: false,
+				Message: fmt.Sprintf("Failed to rename '%s': '%s' already exists", from, to),
+				Kind:    ffi.ErrorKindAlreadyExists,
+			}
+		}
+		result := s.backend.RenamePath(ctx, from, to)
+		if result.Success {
+			done = append(done, step{from, to})
+		}
+		return result
+	}
+
+	// pending maps the current path of every entry still to be renamed to its target
+	pending := make(map[string]string, len(plan.Renames))
+	order := make([]string, 0, len(plan.Renames))
+	for _, r := range plan.Renames {
+		pending[r.Old] = r.New
+		order = append(order, r.Old)
+	}
+
+	var failure ffi.Result
+	cycles := 0
+	for len(pending) > 0 && failure.Message == "" {
+		progressed := false
+		for i, from := range order {
+			to, ok := pending[from]
+			if !ok {
+				continue
+			}
+			if _, blocked := pending[to]; blocked && to != from {
+				continue
+			}
+			if result := rename(from, to); !result.Success {
+				failure = result
+				break
+			}
+			delete(pending, from)
+			order[i] = ""
+			progressed = true
+		}
+		if progressed || failure.Message != "" {
+			continue
+		}
+
+		// Every remaining entry waits for another: move one of a cycle out of the way
+		for i, from := range order {
+			to, ok := pending[from]
+			if !ok {
+				continue
+			}
+			temp := filepath.Join(filepath.Dir(from), fmt.Sprintf(".rename-%d-%s", os.Getpid(), filepath.Base(from)))
+			if result := rename(from, temp); !result.Success {
+				failure = result
+				break
+			}
+			delete(pending, from)
+			pending[temp] = to
+			order[i] = temp
+			cycles++
+			break
+		}
+	}
+
+	if failure.Message != "" {
+		undoCtx := context.WithoutCancel(ctx)
+		for i := len(done) - 1; i >= 0; i-- {
+			s.backend.RenamePath(undoCtx, done[i].to, done[i].from)
+		}
+		failure.Message += fmt.Sprintf("; undid the %d renames made so far", len(done))
+		return failure
+	}
+
+	message := fmt.Sprintf("Renamed %d entries", len(plan.Renames))
+	if cycles > 0 {
+		message += fmt.Sprintf(", resolving %d swap cycle(s) through a temporary name", cycles)
+	}
+	return ffi.Result{Success: true, Message: message}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"filemanager/internal/ffi"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangeCase(t *testing.T) {
+	tests := []struct {
+		name string
+		rule RenameRule
+		want string
+	}{
+		{"Holiday PHOTO.JPG", RenameRule{Case: CaseLower}, "holiday photo.JPG"},
+		{"holiday photo.jpg", RenameRule{Case: CaseUpper}, "HOLIDAY PHOTO.jpg"},
+		{"hOLIDAY-photo 2.jpg", RenameRule{Case: CaseTitle}, "Holiday-Photo 2.jpg"},
+		{"  Holiday Photo (1)!.JPG", RenameRule{Slugify: true}, "holiday-photo-1.jpg"},
+		{"Café Menu.PDF", RenameRule{Slugify: true}, "café-menu.pdf"},
+		{".Env", RenameRule{Case: CaseLower}, ".env"},
+	}
+	for _, tt := range tests {
+		if got := changeCase(tt.name, tt.rule); got != tt.want {
+			t.Errorf("changeCase(%q, %+v) = %q, expected %q", tt.name, tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestExpandRenameTokens(t *testing.T) {
+	modTime := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+	tests := []struct{ replace, want string }{
+		{"photo-{n:03}{ext}", "photo-007.jpg"},
+		{"{n}-{name}{ext}", "7-IMG_1.jpg"},
+		{"{date}_{name}{ext}", "2024-03-09_IMG_1.jpg"},
+		{"{date:YYYYMMDD-hhmmss}{ext}", "20240309-140507.jpg"},
+		{"{date:YY.MM}", "24.03"},
+	}
+	for _, tt := range tests {
+		if got := expandRenameTokens(tt.replace, "IMG_1.jpg", 7, modTime); got != tt.want {
+			t.Errorf("expandRenameTokens(%q) = %q, expected %q", tt.replace, got, tt.want)
+		}
+	}
+}
+
+// newRenameService returns a service over a memory backend holding files under /pics
+func newRenameService(t *testing.T, names ...string) (*FileService, ffi.Backend) {
+	t.Helper()
+	ctx := context.Background()
+	backend := ffi.NewMemoryBackend()
+	backend.CreateFolder(ctx, "/pics", ffi.CreateOptions{Parents: true})
+	for _, name := range names {
+		backend.WriteFile(ctx, filepath.Join("/pics", name), []byte(name), ffi.WriteOptions{})
+	}
+	return NewFileService(backend), backend
+}
+
+// readName returns the content of a file, which newRenameService sets to its original name
+func readName(t *testing.T, backend ffi.Backend, path string) string {
+	t.Helper()
+	file, err := backend.Open(path)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	defer file.Close()
+	data := make([]byte, 64)
+	n, _ := file.Read(data)
+	return string(data[:n])
+}
+
+func TestPlanRenames(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newRenameService(t, "IMG_1.JPG", "IMG_2.JPG", "notes.txt", "img_3.jpg")
+	matches, err := service.GlobPaths(ctx, []string{"/pics/*.JPG"}, MatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := service.PlanRenames(matches, RenameRule{Find: `^IMG_(\d+)`, Replace: "holiday-{n:02}", Slugify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rename := range plan.Renames {
+		got = append(got, filepath.Base(rename.Old)+">"+filepath.Base(rename.New))
+	}
+	if strings.Join(got, " ") != "IMG_1.JPG>holiday-01.jpg IMG_2.JPG>holiday-02.jpg" || plan.Conflicts != 0 {
+		t.Errorf("Unexpected plan %v with %d conflicts", got, plan.Conflicts)
+	}
+
+	service, _ = newRenameService(t, "IMG_3.JPG", "img_3.jpg", "A.txt", "B.txt")
+	matches, _ = service.GlobPaths(ctx, []string{"/pics"}, MatchOptions{Include: []string{"*.JPG", "*.txt"}})
+	plan, err = service.PlanRenames(matches, RenameRule{Replace: "same.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Conflicts != 3 {
+		t.Errorf("Expected every rename onto same.txt to conflict, got %+v", plan)
+	}
+	if result := service.ApplyRenames(ctx, plan); result.Success || result.Kind != ffi.ErrorKindAlreadyExists {
+		t.Errorf("Expected a plan with conflicts to be refused, got %+v", result)
+	}
+
+	// Lowercasing IMG_3.JPG gives the name of a file outside the selection
+	matches, _ = service.GlobPaths(ctx, []string{"/pics/IMG_3.JPG"}, MatchOptions{})
+	plan, _ = service.PlanRenames(matches, RenameRule{Find: `\.JPG$`, Replace: ".jpg", Case: CaseLower})
+	if plan.Conflicts != 1 || !strings.Contains(plan.Renames[0].Conflict, "already exists") {
+		t.Errorf("Expected an existing target to conflict, got %+v", plan)
+	}
+
+	for _, rule := range []RenameRule{{}, {Find: "("}, {Case: "camel"}, {Replace: "{n:x}"}} {
+		if _, err := service.PlanRenames(matches, rule); err == nil {
+			t.Errorf("Expected an error for rule %+v", rule)
+		}
+	}
+	plan, _ = service.PlanRenames(matches, RenameRule{Replace: "a/b"})
+	if plan.Conflicts != 1 {
+		t.Errorf("Expected a name with a separator to conflict, got %+v", plan)
+	}
+}
+
+// TestApplyRenamesCycles verifies swapped and rotated names go through a temporary name
+func TestApplyRenamesCycles(t *testing.T) {
+	ctx := context.Background()
+	service, backend := newRenameService(t, "1.txt", "2.txt", "3.txt", "a.txt", "b.txt")
+	plan := RenamePlan{Renames: []Rename{
+		{Old: "/pics/1.txt", New: "/pics/2.txt"},
+		{Old: "/pics/2.txt", New: "/pics/3.txt"},
+		{Old: "/pics/3.txt", New: "/pics/1.txt"},
+		{Old: "/pics/a.txt", New: "/pics/b.txt"},
+		{Old: "/pics/b.txt", New: "/pics/c.txt"},
+	}}
+	service.checkRenames(&plan)
+	if plan.Conflicts != 0 {
+		t.Fatalf("Expected renames within the selection not to conflict, got %+v", plan)
+	}
+
+	result := service.ApplyRenames(ctx, plan)
+	if !result.Success || !strings.Contains(result.Message, "1 swap cycle") {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	for path, want := range map[string]string{
+		"/pics/2.txt": "1.txt", "/pics/3.txt": "2.txt", "/pics/1.txt": "3.txt",
+		"/pics/b.txt": "a.txt", "/pics/c.txt": "b.txt",
+	} {
+		if got := readName(t, backend, path); got != want {
+			t.Errorf("%s holds %s, expected %s", path, got, want)
+		}
+	}
+	entries, _ := backend.ReadDir("/pics")
+	if len(entries) != 5 {
+		t.Errorf("Expected no temporary names to be left, got %d entries", len(entries))
+	}
+}
+
+// TestApplyRenamesRollback verifies a target appearing after planning undoes the renames made
+func TestApplyRenamesRollback(t *testing.T) {
+	ctx := context.Background()
+	service, backend := newRenameService(t, "a.txt", "b.txt")
+	plan := RenamePlan{Renames: []Rename{
+		{Old: "/pics/a.txt", New: "/pics/x.txt"},
+		{Old: "/pics/b.txt", New: "/pics/y.txt"},
+	}}
+	backend.WriteFile(ctx, "/pics/y.txt", []byte("late"), ffi.WriteOptions{})
+
+	result := service.ApplyRenames(ctx, plan)
+	if result.Success || result.Kind != ffi.ErrorKindAlreadyExists || !strings.Contains(result.Message, "undid the 1 renames") {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	if got := readName(t, backend, "/pics/a.txt"); got != "a.txt" {
+		t.Errorf("a.txt was not restored, holds %q", got)
+	}
+	if got := readName(t, backend, "/pics/y.txt"); got != "late" {
+		t.Errorf("y.txt was replaced, holds %q", got)
+	}
+}
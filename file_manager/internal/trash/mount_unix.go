@@ -0,0 +1,111 @@
+//go:build !windows
+// +build !windows
+
+package trash
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// otherMount reports whether path lives on another filesystem than the home trash,
+// and if so returns the top directory of its mount
+func otherMount(path, home string) (string, bool) {
+	dev, ok := device(filepath.Dir(path))
+	if !ok {
+		return "", false
+	}
+	// The home trash may not exist yet; the folder it will be created in decides
+	for {
+		if homeDev, ok := device(home); ok {
+			if homeDev == dev {
+				return "", false
+			}
+			break
+		}
+		parent := filepath.Dir(home)
+		if parent == home {
+			return "", false
+		}
+		home = parent
+	}
+
+	top := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(top)
+		if parent == top {
+			return top, true
+		}
+		if parentDev, ok := device(parent); !ok || parentDev != dev {
+			return top, true
+		}
+		top = parent
+	}
+}
+
+// device returns the device number of the filesystem holding path
+func device(path string) (uint64, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+// mountPoints lists the mounted filesystems that may hold a trash directory
+// It is empty on systems without /proc/self/mounts.
+func mountPoints() []string {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var points []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		point := unescapeMount(fields[1])
+		if point == "/" || seen[point] || isPseudoMount(point) {
+			continue
+		}
+		seen[point] = true
+		points = append(points, point)
+	}
+	return points
+}
+
+// isPseudoMount reports whether point belongs to a kernel filesystem that never
+// holds user files
+func isPseudoMount(point string) bool {
+	for _, prefix := range []string{"/proc", "/sys", "/dev", "/run"} {
+		if point == prefix || strings.HasPrefix(point, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeMount decodes the octal escapes, like \040 for a space, of /proc/self/mounts
+func unescapeMount(field string) string {
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
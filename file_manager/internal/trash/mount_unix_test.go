@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package trash
+
+import "testing"
+
+// TestUnescapeMount verifies the escapes of /proc/self/mounts are decoded
+func TestUnescapeMount(t *testing.T) {
+	if got := unescapeMount(`/media/usb\040disk\134x`); got != `/media/usb disk\x` {
+		t.Errorf("Got %q", got)
+	}
+}
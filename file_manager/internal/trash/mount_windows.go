@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package trash
+
+// otherMount reports whether path lives on another filesystem than the home trash
+// Windows has no per-mount trash directories, so everything goes to the home trash.
+func otherMount(path, home string) (string, bool) {
+	return "", false
+}
+
+// mountPoints lists the mounted filesystems that may hold a trash directory
+func mountPoints() []string {
+	return nil
+}
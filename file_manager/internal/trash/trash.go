@@ -0,0 +1,493 @@
+// Package trash moves files into the trash and back following the freedesktop.org
+// Trash specification, so desktop file managers see and restore them too
+package trash
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"filemanager/internal/ffi"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// infoSuffix ends the name of the file describing a trashed entry
+const infoSuffix = ".trashinfo"
+
+// dateLayout is the format of DeletionDate, in local time as the specification asks
+const dateLayout = "2006-01-02T15:04:05"
+
+// Item is an entry in the trash
+type Item struct {
+	// Path is where the entry is kept in the trash; it identifies the item for Restore
+	Path string
+	// OriginalPath is where the entry was deleted from
+	OriginalPath string
+	// DeletionDate is when the entry was moved to the trash
+	DeletionDate time.Time
+	IsDir        bool
+	// Size is the size of files; folders report 0
+	Size int64
+}
+
+// Trash moves entries of a backend into the trash of the current user
+//
+// Entries go to the home trash in $XDG_DATA_HOME/Trash (~/.local/share/Trash) when
+// they live on the same filesystem. Entries on other mounts of a disk backend go to
+// the trash at the top of their mount, $topdir/.Trash/$uid when an administrator
+// prepared a sticky $topdir/.Trash, else $topdir/.Trash-$uid. When neither can be
+// used the entry is copied to the home trash. Backends that are not on the local
+// disk, such as a MemoryBackend, only use the home trash.
+type Trash struct {
+	backend ffi.Backend
+	uid     int
+
+	// home is the home trash directory; homeErr tells why it is unknown
+	home    string
+	homeErr error
+
+	// onDisk tells whether the paths of backend are those of the local disk,
+	// so their mounts can be looked up
+	onDisk bool
+
+	// recorder is told about every entry moved into or out of the trash
+	recorder Recorder
+}
+
+// Recorder is told where entries went when they are moved into or out of the
+// trash, so the moves can be undone later
+type Recorder interface {
+	// Trashed reports that Put moved original to trashed, the path Restore takes
+	Trashed(ctx context.Context, original, trashed string)
+	// Restored reports that Restore moved trashed back to original
+	Restored(ctx context.Context, trashed, original string)
+}
+
+// SetRecorder makes t report the entries it moves to r
+func (t *Trash) SetRecorder(r Recorder) {
+	t.recorder = r
+}
+
+// New returns the trash of the current user on backend
+func New(backend ffi.Backend) *Trash {
+	t := &Trash{
+		backend: backend,
+		uid:     os.Getuid(),
+		onDisk:  backend.Name() != ffi.BackendMemory,
+	}
+	t.home, t.homeErr = homeTrash()
+	return t
+}
+
+// homeTrash returns the home trash directory of the current user
+func homeTrash() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dataHome) {
+		return filepath.Join(dataHome, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the home trash: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// trashDir is a trash directory with its "files" and "info" subdirectories
+type trashDir struct {
+	path string
+	// top is the mount a per-mount trash belongs to; the original paths recorded in
+	// it are relative to top. It is empty for the home trash.
+	top string
+}
+
+func (d trashDir) files() string { return filepath.Join(d.path, "files") }
+func (d trashDir) info() string  { return filepath.Join(d.path, "info") }
+
+// Put moves path into the trash
+// progress is reported when the entry has to be copied to another filesystem.
+func (t *Trash) Put(ctx context.Context, path string, progress ffi.ProgressFunc) ffi.Result {
+	_, result := t.PutPath(ctx, path, progress)
+	return result
+}
+
+// PutPath is Put that also returns where the trash keeps the entry, the path
+// Restore takes
+func (t *Trash) PutPath(ctx context.Context, path string, progress ffi.ProgressFunc) (string, ffi.Result) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", failure(err, "Cannot move '%s' to the trash", path)
+	}
+	if _, err := t.backend.Lstat(abs); err != nil {
+		return "", failure(err, "Cannot move '%s' to the trash", path)
+	}
+
+	dir, err := t.dirFor(ctx, abs)
+	if err != nil {
+		return "", failure(err, "Cannot move '%s' to the trash", path)
+	}
+	if within(abs, dir.path) || within(dir.path, abs) {
+		return "", ffi.Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot move '%s' to the trash: it is part of the trash", path),
+			Kind:    ffi.ErrorKindInvalidPath,
+		}
+	}
+
+	// The info file is written first so an interrupted move leaves no unknown entry
+	name := t.freeName(dir, filepath.Base(abs))
+	infoPath := filepath.Join(dir.info(), name+infoSuffix)
+	original := abs
+	if dir.top != "" {
+		original, _ = filepath.Rel(dir.top, abs)
+	}
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: filepath.ToSlash(original)}).EscapedPath(), time.Now().Format(dateLayout))
+	if result := t.backend.WriteFile(ctx, infoPath, []byte(info), ffi.WriteOptions{Exclusive: true}); !result.Success {
+		return "", result
+	}
+
+	target := filepath.Join(dir.files(), name)
+	result := t.backend.MovePath(ctx, abs, target, ffi.CopyOptions{Conflict: ffi.ConflictFail}, progress)
+	if !result.Success {
+		t.backend.Remove(ctx, infoPath)
+		return "", result
+	}
+	if t.recorder != nil {
+		t.recorder.Trashed(ctx, abs, target)
+	}
+	result.Message = fmt.Sprintf("Moved '%s' to the trash", path)
+	return target, result
+}
+
+// List returns the entries of every trash directory of the user, most recently deleted first
+// Entries whose info file is missing or unreadable are left out.
+func (t *Trash) List() ([]Item, error) {
+	dirs, err := t.dirs()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	for _, dir := range dirs {
+		entries, err := t.backend.ReadDir(dir.info())
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), infoSuffix)
+			if !ok || entry.IsDir() {
+				continue
+			}
+			item, err := t.item(dir, name)
+			if err != nil {
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].DeletionDate.Equal(items[j].DeletionDate) {
+			return items[i].DeletionDate.After(items[j].DeletionDate)
+		}
+		return items[i].Path < items[j].Path
+	})
+	return items, nil
+}
+
+// Restore moves the trashed entry at path, the Path of an Item, back to where it
+// was deleted from, recreating missing parent folders
+// An entry that was created at the original path in the meantime is not replaced.
+func (t *Trash) Restore(ctx context.Context, path string) ffi.Result {
+	dir, ok := trashDirOf(path)
+	if !ok {
+		return ffi.Result{
+			Success: false,
+			Message: fmt.Sprintf("Cannot restore '%s': not an entry of a trash directory", path),
+			Kind:    ffi.ErrorKindInvalidPath,
+		}
+	}
+	item, err := t.item(dir, filepath.Base(path))
+	if err != nil {
+		return failure(err, "Cannot restore '%s'", path)
+	}
+
+	if _, err := t.backend.Lstat(item.OriginalPath); err == nil {
+		err := &fs.PathError{Op: "restore", Path: item.OriginalPath, Err: fs.ErrExist}
+		return failure(err, "Cannot restore '%s'", item.OriginalPath)
+	}
+	if parent := filepath.Dir(item.OriginalPath); parent != item.OriginalPath {
+		if _, err := t.backend.Lstat(parent); err != nil {
+			if result := t.backend.CreateFolder(ctx, parent, ffi.CreateOptions{Parents: true}); !result.Success {
+				return result
+			}
+		}
+	}
+
+	result := t.backend.MovePath(ctx, item.Path, item.OriginalPath, ffi.CopyOptions{Conflict: ffi.ConflictFail}, nil)
+	if !result.Success {
+		return result
+	}
+	t.backend.Remove(ctx, filepath.Join(dir.info(), filepath.Base(path)+infoSuffix))
+	if t.recorder != nil {
+		t.recorder.Restored(ctx, item.Path, item.OriginalPath)
+	}
+	result.Message = fmt.Sprintf("Restored '%s'", item.OriginalPath)
+	return result
+}
+
+// Empty permanently deletes every entry of every trash directory of the user
+// progress receives the progress of each deleted entry in turn.
+func (t *Trash) Empty(ctx context.Context, progress ffi.ProgressFunc) ffi.Result {
+	dirs, err := t.dirs()
+	if err != nil {
+		return failure(err, "Cannot empty the trash")
+	}
+
+	removed, failed := 0, 0
+	var firstFailure ffi.Result
+	for _, dir := range dirs {
+		entries, _ := t.backend.ReadDir(dir.files())
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return ffi.Result{
+					Success: false,
+					Message: fmt.Sprintf("Emptying the trash was cancelled after %d item(s): %v", removed, context.Cause(ctx)),
+					Kind:    ffi.ErrorKindCancelled,
+				}
+			}
+			result := t.backend.DeletePath(ctx, filepath.Join(dir.files(), entry.Name()), ffi.DeleteOptions{}, progress)
+			if !result.Success {
+				if failed == 0 {
+					firstFailure = result
+				}
+				failed++
+				continue
+			}
+			t.backend.Remove(ctx, filepath.Join(dir.info(), entry.Name()+infoSuffix))
+			removed++
+		}
+
+		// Info files without an entry are left over by interrupted moves
+		infos, _ := t.backend.ReadDir(dir.info())
+		for _, info := range infos {
+			name, ok := strings.CutSuffix(info.Name(), infoSuffix)
+			if !ok {
+				continue
+			}
+			if _, err := t.backend.Lstat(filepath.Join(dir.files(), name)); errors.Is(err, fs.ErrNotExist) {
+				t.backend.Remove(ctx, filepath.Join(dir.info(), info.Name()))
+			}
+		}
+	}
+
+	if failed > 0 {
+		return ffi.Result{
+			Success: false,
+			Message: fmt.Sprintf("Removed %d item(s) from the trash, %d failed: %s", removed, failed, firstFailure.Message),
+			Kind:    firstFailure.Kind,
+		}
+	}
+	return ffi.Result{
+		Success: true,
+		Message: fmt.Sprintf("Emptied the trash: %d item(s) removed", removed),
+	}
+}
+
+// item reads the entry called name of dir
+func (t *Trash) item(dir trashDir, name string) (Item, error) {
+	path := filepath.Join(dir.files(), name)
+	info, err := t.backend.Lstat(path)
+	if err != nil {
+		return Item{}, err
+	}
+
+	file, err := t.backend.Open(filepath.Join(dir.info(), name+infoSuffix))
+	if err != nil {
+		return Item{}, err
+	}
+	defer file.Close()
+
+	item := Item{Path: path, IsDir: info.IsDir()}
+	if !info.IsDir() {
+		item.Size = info.Size()
+	}
+	if err := parseInfo(file, &item); err != nil {
+		return Item{}, &fs.PathError{Op: "read", Path: filepath.Join(dir.info(), name+infoSuffix), Err: err}
+	}
+	if !filepath.IsAbs(item.OriginalPath) {
+		item.OriginalPath = filepath.Join(dir.top, item.OriginalPath)
+	}
+	return item, nil
+}
+
+// parseInfo reads the original path and deletion date of a .trashinfo file into item
+func parseInfo(file interface{ Read([]byte) (int, error) }, item *Item) error {
+	scanner := bufio.NewScanner(file)
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == "[Trash Info]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inSection || !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			path, err := url.PathUnescape(value)
+			if err != nil {
+				return err
+			}
+			item.OriginalPath = filepath.FromSlash(path)
+		case "DeletionDate":
+			if date, err := time.ParseInLocation(dateLayout, value, time.Local); err == nil {
+				item.DeletionDate = date
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if item.OriginalPath == "" {
+		return errors.New("missing Path")
+	}
+	return nil
+}
+
+// dirFor returns the trash directory that path should be moved to, creating it when needed
+func (t *Trash) dirFor(ctx context.Context, path string) (trashDir, error) {
+	if t.home == "" {
+		return trashDir{}, t.homeErr
+	}
+	home := trashDir{path: t.home}
+
+	if t.onDisk {
+		if top, ok := otherMount(path, t.home); ok {
+			for _, dir := range t.mountDirs(top) {
+				if t.ensure(ctx, dir) == nil {
+					return dir, nil
+				}
+			}
+		}
+	}
+
+	return home, t.ensure(ctx, home)
+}
+
+// mountDirs lists the trash directories a mount may hold, in order of preference
+func (t *Trash) mountDirs(top string) []trashDir {
+	var dirs []trashDir
+	// A shared .Trash must be a sticky folder, not a link, to be trusted
+	if info, err := t.backend.Lstat(filepath.Join(top, ".Trash")); err == nil && info.IsDir() && info.Mode()&fs.ModeSticky != 0 {
+		dirs = append(dirs, trashDir{path: filepath.Join(top, ".Trash", strconv.Itoa(t.uid)), top: top})
+	}
+	return append(dirs, trashDir{path: filepath.Join(top, fmt.Sprintf(".Trash-%d", t.uid)), top: top})
+}
+
+// ensure creates the folders of dir that are missing
+// New trash directories are only accessible by their owner.
+func (t *Trash) ensure(ctx context.Context, dir trashDir) error {
+	if _, err := t.backend.Lstat(dir.path); err != nil {
+		if result := t.backend.CreateFolder(ctx, dir.path, ffi.CreateOptions{Parents: true}); !result.Success {
+			return errors.New(result.Message)
+		}
+		t.backend.ChangePermissions(ctx, dir.path, 0700)
+	}
+	for _, sub := range []string{dir.files(), dir.info()} {
+		if _, err := t.backend.Lstat(sub); err != nil {
+			if result := t.backend.CreateFolder(ctx, sub, ffi.CreateOptions{}); !result.Success {
+				return errors.New(result.Message)
+			}
+			t.backend.ChangePermissions(ctx, sub, 0700)
+		}
+	}
+	return nil
+}
+
+// dirs returns the trash directories of the user that exist
+func (t *Trash) dirs() ([]trashDir, error) {
+	if t.home == "" {
+		return nil, t.homeErr
+	}
+
+	var dirs []trashDir
+	candidates := []trashDir{{path: t.home}}
+	if t.onDisk {
+		for _, top := range mountPoints() {
+			candidates = append(candidates, t.mountDirs(top)...)
+		}
+	}
+	for _, dir := range candidates {
+		if info, err := t.backend.Lstat(dir.info()); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// freeName returns name, or name with a number added, so that neither an entry
+// nor an info file of that name exists in dir
+func (t *Trash) freeName(dir trashDir, name string) string {
+	// A leading dot starts a hidden name rather than an extension
+	stem, ext := name, ""
+	if index := strings.LastIndex(name, "."); index > 0 {
+		stem, ext = name[:index], name[index:]
+	}
+
+	candidate := name
+	for n := 2; t.taken(dir, candidate); n++ {
+		candidate = fmt.Sprintf("%s.%d%s", stem, n, ext)
+	}
+	return candidate
+}
+
+// taken reports whether name is used by an entry or info file of dir
+func (t *Trash) taken(dir trashDir, name string) bool {
+	if _, err := t.backend.Lstat(filepath.Join(dir.files(), name)); err == nil {
+		return true
+	}
+	_, err := t.backend.Lstat(filepath.Join(dir.info(), name+infoSuffix))
+	return err == nil
+}
+
+// trashDirOf returns the trash directory holding the trashed entry at path
+func trashDirOf(path string) (trashDir, bool) {
+	path = filepath.Clean(path)
+	files := filepath.Dir(path)
+	if filepath.Base(files) != "files" || !filepath.IsAbs(path) {
+		return trashDir{}, false
+	}
+
+	dir := trashDir{path: filepath.Dir(files)}
+	switch name := filepath.Base(dir.path); {
+	case strings.HasPrefix(name, ".Trash-"):
+		dir.top = filepath.Dir(dir.path)
+	case filepath.Base(filepath.Dir(dir.path)) == ".Trash":
+		dir.top = filepath.Dir(filepath.Dir(dir.path))
+	}
+	return dir, true
+}
+
+// within reports whether path is dir or lies below it
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// failure builds the result of an operation that failed with err
+func failure(err error, format string, args ...interface{}) ffi.Result {
+	return ffi.Result{
+		Success: false,
+		Message: fmt.Sprintf(format, args...) + ": " + err.Error(),
+		Kind:    ffi.KindOf(err),
+	}
+}
@@ -0,0 +1,147 @@
+package trash
+
+import (
+	"context"
+	"filemanager/internal/ffi"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPutAndRestore verifies entries are moved to the home trash with an info file,
+// listed, restored and kept apart when they share a name
+func TestPutAndRestore(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("XDG_DATA_HOME", "/data")
+	m := ffi.NewMemoryBackend()
+	m.CreateFolder(ctx, "/work/dir with space", ffi.CreateOptions{Parents: true})
+	m.WriteFile(ctx, "/work/report.txt", []byte("first"), ffi.WriteOptions{})
+	m.WriteFile(ctx, "/work/dir with space/a", []byte("a"), ffi.WriteOptions{})
+
+	trash := New(m)
+	for _, path := range []string{"/work/report.txt", "/work/dir with space"} {
+		if result := trash.Put(ctx, path, nil); !result.Success {
+			t.Fatal(result.Message)
+		}
+	}
+	m.WriteFile(ctx, "/work/report.txt", []byte("second"), ffi.WriteOptions{})
+	if result := trash.Put(ctx, "/work/report.txt", nil); !result.Success {
+		t.Fatal(result.Message)
+	}
+
+	info, err := m.Open("/data/Trash/info/dir with space.trashinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	buf := make([]byte, 256)
+	n, _ := info.Read(buf)
+	content.Write(buf[:n])
+	info.Close()
+	if !strings.HasPrefix(content.String(), "[Trash Info]\nPath=/work/dir%20with%20space\nDeletionDate=") {
+		t.Errorf("Unexpected info file:\n%s", content.String())
+	}
+
+	items, err := trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string]string{}
+	for _, item := range items {
+		paths[item.Path] = item.OriginalPath
+	}
+	expected := map[string]string{
+		"/data/Trash/files/report.txt":     "/work/report.txt",
+		"/data/Trash/files/report.2.txt":   "/work/report.txt",
+		"/data/Trash/files/dir with space": "/work/dir with space",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("Unexpected items: %+v", items)
+	}
+	for path, original := range expected {
+		if paths[path] != original {
+			t.Errorf("%s: expected original %q, got %q", path, original, paths[path])
+		}
+	}
+
+	// The original path is taken again, so only the folder can be restored
+	m.WriteFile(ctx, "/work/report.txt", []byte("third"), ffi.WriteOptions{})
+	if result := trash.Restore(ctx, "/data/Trash/files/report.txt"); result.Success || result.Kind != ffi.ErrorKindAlreadyExists {
+		t.Errorf("Expected %v, got %v (%s)", ffi.ErrorKindAlreadyExists, result.Kind, result.Message)
+	}
+	m.DeletePath(ctx, "/work", ffi.DeleteOptions{}, nil)
+	if result := trash.Restore(ctx, "/data/Trash/files/dir with space"); !result.Success {
+		t.Fatal(result.Message)
+	}
+	if _, err := m.Lstat("/work/dir with space/a"); err != nil {
+		t.Errorf("Restored entry missing: %v", err)
+	}
+	if _, err := m.Lstat("/data/Trash/info/dir with space.trashinfo"); err == nil {
+		t.Error("Info file of a restored entry was kept")
+	}
+
+	if result := trash.Empty(ctx, nil); !result.Success || !strings.Contains(result.Message, "2 item(s)") {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if items, _ := trash.List(); len(items) != 0 {
+		t.Errorf("Trash is not empty: %+v", items)
+	}
+}
+
+// TestPutOnDisk verifies the trash works on the local disk and refuses to trash itself
+func TestPutOnDisk(t *testing.T) {
+	ctx := context.Background()
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	trash := New(ffi.NewNativeBackend())
+	if result := trash.Put(ctx, path, nil); !result.Success {
+		t.Fatal(result.Message)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Trashed file still exists: %v", err)
+	}
+	items, err := trash.List()
+	if err != nil || len(items) != 1 || items[0].OriginalPath != path {
+		t.Fatalf("Unexpected items: %+v (%v)", items, err)
+	}
+	if result := trash.Put(ctx, filepath.Join(dataHome, "Trash"), nil); result.Success {
+		t.Error("The trash was moved into itself")
+	}
+	if result := trash.Restore(ctx, path); result.Kind != ffi.ErrorKindInvalidPath {
+		t.Errorf("Expected %v for a path outside the trash, got %v", ffi.ErrorKindInvalidPath, result.Kind)
+	}
+}
+
+// TestPutDanglingLink verifies a link whose target is gone is trashed and restored as a link
+func TestPutDanglingLink(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	link := filepath.Join(t.TempDir(), "stale")
+	if err := os.Symlink(filepath.Join(t.TempDir(), "gone"), link); err != nil {
+		t.Skipf("Cannot create symbolic links: %v", err)
+	}
+
+	trash := New(ffi.NewNativeBackend())
+	if result := trash.Put(ctx, link, nil); !result.Success {
+		t.Fatal(result.Message)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("Trashed link still exists: %v", err)
+	}
+	items, err := trash.List()
+	if err != nil || len(items) != 1 {
+		t.Fatalf("Expected the link in the trash, got %+v (%v)", items, err)
+	}
+	if result := trash.Restore(ctx, items[0].Path); !result.Success {
+		t.Fatal(result.Message)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected the link back, got %v (%v)", info, err)
+	}
+}
@@ -88,7 +88,10 @@ async function executeCreateFile() {
         return;
     }
     
-    await sendRequest('createFile', { paths });
+    const content = document.getElementById('fileContent').value;
+    const mode = document.getElementById('fileMode').value.trim();
+    const exclusive = document.getElementById('fileExclusive').checked;
+    await sendRequest('createFile', { paths, content, mode, exclusive });
 }
 
 async function executeRename() {
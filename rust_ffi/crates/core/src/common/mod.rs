@@ -16,36 +16,143 @@ pub enum FsError {
     
     #[error("Permission error: {0}")]
     PermissionError(String),
+
+    #[error("Operation cancelled after {0} of {1} files")]
+    Cancelled(u64, u64),
 }
 
 /// Result type for file system operations
 pub type FsResult<T> = Result<T, FsError>;
 
+/// Machine-readable error category carried across the FFI boundary
+/// The numeric values are part of the C ABI and must match the Go side
+#[repr(i32)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ErrorKind {
+    None = 0,
+    NotFound = 1,
+    PermissionDenied = 2,
+    AlreadyExists = 3,
+    NotEmpty = 4,
+    CrossDevice = 5,
+    InvalidPath = 6,
+    Io = 7,
+    Cancelled = 8,
+}
+
+impl FsError {
+    /// Classify the error into a stable ErrorKind
+    pub fn kind(&self) -> ErrorKind {
+        match self {
+            FsError::InvalidUtf8(_) | FsError::PathError(_) => ErrorKind::InvalidPath,
+            FsError::PermissionError(_) => ErrorKind::PermissionDenied,
+            FsError::Cancelled(_, _) => ErrorKind::Cancelled,
+            FsError::Io(e) => io_error_kind(e),
+        }
+    }
+}
+
+/// Map a std::io::Error to an ErrorKind
+fn io_error_kind(err: &std::io::Error) -> ErrorKind {
+    use std::io::ErrorKind as IoKind;
+
+    match err.kind() {
+        IoKind::NotFound => ErrorKind::NotFound,
+        IoKind::PermissionDenied => ErrorKind::PermissionDenied,
+        IoKind::AlreadyExists => ErrorKind::AlreadyExists,
+        IoKind::DirectoryNotEmpty => ErrorKind::NotEmpty,
+        IoKind::CrossesDevices => ErrorKind::CrossDevice,
+        IoKind::InvalidInput
+        | IoKind::InvalidFilename
+        | IoKind::NotADirectory
+        | IoKind::IsADirectory => ErrorKind::InvalidPath,
+        _ => ErrorKind::Io,
+    }
+}
+
 /// C-compatible operation result
 #[repr(C)]
 pub struct OperationResult {
     pub success: i32,
+    pub error_code: i32,
     pub message: *mut c_char,
+    /// Bit set of RESULT_FLAG_* values describing how the operation was carried out
+    pub flags: u32,
 }
 
+/// Set when a move crossed filesystems and was carried out by copying, verifying and deleting
+pub const RESULT_FLAG_MOVED_BY_COPY: u32 = 1;
+/// Set when every file of a copy was cloned with a reflink
+pub const RESULT_FLAG_REFLINK: u32 = 2;
+/// Set when the slowest strategy a copy needed was copy_file_range
+pub const RESULT_FLAG_COPY_FILE_RANGE: u32 = 4;
+/// Set when a copy had to copy the data of at least one file through a buffer
+pub const RESULT_FLAG_BUFFERED: u32 = 8;
+
 impl OperationResult {
     /// Create a success result with a message
     pub fn success(msg: &str) -> Self {
         OperationResult {
             success: 1,
+            error_code: ErrorKind::None as i32,
             message: CString::new(msg).unwrap().into_raw(),
+            flags: 0,
         }
     }
 
-    /// Create an error result with a message
-    pub fn error(msg: &str) -> Self {
+    /// Create an error result with a message and error kind
+    pub fn error(kind: ErrorKind, msg: &str) -> Self {
         OperationResult {
             success: 0,
+            error_code: kind as i32,
             message: CString::new(msg).unwrap().into_raw(),
+            flags: 0,
         }
     }
+
+    /// Add RESULT_FLAG_* values to the result
+    pub fn with_flags(mut self, flags: u32) -> Self {
+        self.flags |= flags;
+        self
+    }
+
+    /// Create an error result from an FsError
+    pub fn from_error(err: &FsError) -> Self {
+        Self::error(err.kind(), &err.to_string())
+    }
+}
+
+/// C-compatible progress snapshot passed to progress callbacks
+/// `current_path` is only valid for the duration of the callback
+#[repr(C)]
+pub struct ProgressInfo {
+    pub files_done: u64,
+    pub files_total: u64,
+    pub bytes_done: u64,
+    pub bytes_total: u64,
+    pub current_path: *const c_char,
+}
+
+/// Progress callback supplied by the caller; `user_data` is passed back unchanged
+/// Returning a non-zero value cancels the operation before the next file
+pub type ProgressCallback = Option<extern "C" fn(info: *const ProgressInfo, user_data: usize) -> i32>;
+
+/// C-compatible options for copy and move operations
+/// Fields hold the raw values of the corresponding Rust enums
+#[repr(C)]
+#[derive(Debug, Clone, Copy, Default)]
+pub struct TransferOptions {
+    pub conflict_policy: i32,
+    pub symlink_policy: i32,
+    /// Non-zero to preserve permissions, times, ownership and extended attributes
+    pub preserve_metadata: i32,
 }
 
+/// Called once for every conflicting entry with the source, the path written
+/// and the ConflictAction taken; the paths are only valid for the duration of the call
+pub type ConflictCallback =
+    Option<extern "C" fn(src: *const c_char, dst: *const c_char, action: i32, user_data: usize)>;
+
 /// Helper function to safely convert C string to Rust string
 pub fn c_str_to_string(ptr: *const c_char) -> FsResult<String> {
     let c_str = unsafe { CStr::from_ptr(ptr) };
@@ -63,4 +170,20 @@ pub extern "C" fn free_result(result: OperationResult) {
             let _ = CString::from_raw(result.message);
         }
     }
-}
\ No newline at end of file
+}
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_error_kind_mapping() {
+        let missing = FsError::Io(std::io::Error::from(std::io::ErrorKind::NotFound));
+        assert_eq!(missing.kind(), ErrorKind::NotFound);
+
+        let denied = FsError::PermissionError("denied".to_string());
+        assert_eq!(denied.kind(), ErrorKind::PermissionDenied);
+
+        let bad_path = FsError::InvalidUtf8("bad".to_string());
+        assert_eq!(bad_path.kind(), ErrorKind::InvalidPath);
+    }
+}
@@ -1,16 +1,86 @@
-use crate::common::{c_str_to_string, OperationResult};
+use crate::common::{
+    c_str_to_string, ConflictCallback, FsError, OperationResult, ProgressCallback, ProgressInfo,
+    TransferOptions, RESULT_FLAG_BUFFERED, RESULT_FLAG_COPY_FILE_RANGE, RESULT_FLAG_MOVED_BY_COPY,
+    RESULT_FLAG_REFLINK,
+};
 use crate::operations;
+use crate::operations::conflict::{ConflictLog, ConflictPolicy};
+use crate::operations::copy::{CopyOptions, SymlinkPolicy};
+use crate::operations::fast_copy::CopyStrategy;
+use crate::operations::progress::ProgressReporter;
+use std::ffi::CString;
 use std::os::raw::c_char;
 
+/// Build a ProgressReporter that forwards updates to a C callback
+fn callback_reporter(callback: ProgressCallback, user_data: usize) -> ProgressReporter<'static> {
+    match callback {
+        Some(callback) => ProgressReporter::new(move |progress| {
+            let current_path = CString::new(progress.current_path.as_str()).unwrap_or_default();
+            let info = ProgressInfo {
+                files_done: progress.files_done,
+                files_total: progress.files_total,
+                bytes_done: progress.bytes_done,
+                bytes_total: progress.bytes_total,
+                current_path: current_path.as_ptr(),
+            };
+            callback(&info, user_data) == 0
+        }),
+        None => ProgressReporter::silent(),
+    }
+}
+
+/// Build a ConflictLog that forwards every conflict to a C callback
+fn callback_conflict_log(callback: ConflictCallback, user_data: usize) -> ConflictLog<'static> {
+    match callback {
+        Some(callback) => ConflictLog::new(move |conflict| {
+            let src = CString::new(conflict.source.to_string_lossy().as_bytes()).unwrap_or_default();
+            let dst = CString::new(conflict.destination.to_string_lossy().as_bytes()).unwrap_or_default();
+            callback(src.as_ptr(), dst.as_ptr(), conflict.action as i32, user_data);
+        }),
+        None => ConflictLog::silent(),
+    }
+}
+
+/// Result flag telling which copy strategy was used
+fn strategy_flag(strategy: Option<CopyStrategy>) -> u32 {
+    match strategy {
+        Some(CopyStrategy::Reflink) => RESULT_FLAG_REFLINK,
+        Some(CopyStrategy::CopyFileRange) => RESULT_FLAG_COPY_FILE_RANGE,
+        Some(CopyStrategy::Buffered) => RESULT_FLAG_BUFFERED,
+        None => 0,
+    }
+}
+
+/// Convert C transfer options, using the defaults when `options` is null
+fn copy_options(options: *const TransferOptions) -> Result<CopyOptions, FsError> {
+    let raw = if options.is_null() {
+        TransferOptions::default()
+    } else {
+        unsafe { *options }
+    };
+
+    let conflict = ConflictPolicy::from_raw(raw.conflict_policy).ok_or_else(|| {
+        FsError::PathError(format!("Unknown conflict policy: {}", raw.conflict_policy))
+    })?;
+    let symlinks = SymlinkPolicy::from_raw(raw.symlink_policy).ok_or_else(|| {
+        FsError::PathError(format!("Unknown symlink policy: {}", raw.symlink_policy))
+    })?;
+    Ok(CopyOptions {
+        conflict,
+        symlinks,
+        preserve_metadata: raw.preserve_metadata != 0,
+    })
+}
+
 /// FFI wrapper for create_folder
 #[no_mangle]
 pub extern "C" fn create_folder(path: *const c_char) -> OperationResult {
     match c_str_to_string(path) {
         Ok(path_str) => match operations::create::create_folder(&path_str) {
             Ok(msg) => OperationResult::success(&msg),
-            Err(e) => OperationResult::error(&e.to_string()),
+            Err(e) => OperationResult::from_error(&e),
         },
-        Err(e) => OperationResult::error(&e.to_string()),
+        Err(e) => OperationResult::from_error(&e),
     }
 }
 
@@ -20,9 +90,9 @@ pub extern "C" fn create_file(path: *const c_char) -> OperationResult {
     match c_str_to_string(path) {
         Ok(path_str) => match operations::create::create_file(&path_str) {
             Ok(msg) => OperationResult::success(&msg),
-            Err(e) => OperationResult::error(&e.to_string()),
+            Err(e) => OperationResult::from_error(&e),
         },
-        Err(e) => OperationResult::error(&e.to_string()),
+        Err(e) => OperationResult::from_error(&e),
     }
 }
 
@@ -31,17 +101,17 @@ pub extern "C" fn create_file(path: *const c_char) -> OperationResult {
 pub extern "C" fn rename_path(old_path: *const c_char, new_path: *const c_char) -> OperationResult {
     let old_str = match c_str_to_string(old_path) {
         Ok(s) => s,
-        Err(e) => return OperationResult::error(&e.to_string()),
+        Err(e) => return OperationResult::from_error(&e),
     };
     
     let new_str = match c_str_to_string(new_path) {
         Ok(s) => s,
-        Err(e) => return OperationResult::error(&e.to_string()),
+        Err(e) => return OperationResult::from_error(&e),
     };
 
     match operations::rename::rename_path(&old_str, &new_str) {
         Ok(msg) => OperationResult::success(&msg),
-        Err(e) => OperationResult::error(&e.to_string()),
+        Err(e) => OperationResult::from_error(&e),
     }
 }
 
@@ -51,9 +121,9 @@ pub extern "C" fn delete_path(path: *const c_char) -> OperationResult {
     match c_str_to_string(path) {
         Ok(path_str) => match operations::delete::delete_path(&path_str) {
             Ok(msg) => OperationResult::success(&msg),
-            Err(e) => OperationResult::error(&e.to_string()),
+            Err(e) => OperationResult::from_error(&e),
         },
-        Err(e) => OperationResult::error(&e.to_string()),
+        Err(e) => OperationResult::from_error(&e),
     }
 }
 
@@ -63,9 +133,25 @@ pub extern "C" fn change_permissions(path: *const c_char, mode: u32) -> Operatio
     match c_str_to_string(path) {
         Ok(path_str) => match operations::file_permissions::change_permissions(&path_str, mode) {
             Ok(msg) => OperationResult::success(&msg),
-            Err(e) => OperationResult::error(&e.to_string()),
+            Err(e) => OperationResult::from_error(&e),
+        },
+        Err(e) => OperationResult::from_error(&e),
+    }
+}
+
+/// FFI wrapper for change_owner
+/// A negative uid or gid leaves the owner or group unchanged; a non-zero
+/// `no_follow` changes a symbolic link itself instead of what it points to.
+#[no_mangle]
+pub extern "C" fn change_owner(path: *const c_char, uid: i64, gid: i64, no_follow: i32) -> OperationResult {
+    let uid = u32::try_from(uid).ok();
+    let gid = u32::try_from(gid).ok();
+    match c_str_to_string(path) {
+        Ok(path_str) => match operations::file_permissions::change_owner(&path_str, uid, gid, no_follow != 0) {
+            Ok(msg) => OperationResult::success(&msg),
+            Err(e) => OperationResult::from_error(&e),
         },
-        Err(e) => OperationResult::error(&e.to_string()),
+        Err(e) => OperationResult::from_error(&e),
     }
 }
 
@@ -74,17 +160,17 @@ pub extern "C" fn change_permissions(path: *const c_char, mode: u32) -> Operatio
 pub extern "C" fn move_path(src: *const c_char, dst: *const c_char) -> OperationResult {
     let src_str = match c_str_to_string(src) {
         Ok(s) => s,
-        Err(e) => return OperationResult::error(&e.to_string()),
+        Err(e) => return OperationResult::from_error(&e),
     };
     
     let dst_str = match c_str_to_string(dst) {
         Ok(s) => s,
-        Err(e) => return OperationResult::error(&e.to_string()),
+        Err(e) => return OperationResult::from_error(&e),
     };
 
     match operations::move_ops::move_path(&src_str, &dst_str) {
         Ok(msg) => OperationResult::success(&msg),
-        Err(e) => OperationResult::error(&e.to_string()),
+        Err(e) => OperationResult::from_error(&e),
     }
 }
 
@@ -93,16 +179,151 @@ pub extern "C" fn move_path(src: *const c_char, dst: *const c_char) -> Operation
 pub extern "C" fn copy_path(src: *const c_char, dst: *const c_char) -> OperationResult {
     let src_str = match c_str_to_string(src) {
         Ok(s) => s,
-        Err(e) => return OperationResult::error(&e.to_string()),
+        Err(e) => return OperationResult::from_error(&e),
     };
     
     let dst_str = match c_str_to_string(dst) {
         Ok(s) => s,
-        Err(e) => return OperationResult::error(&e.to_string()),
+        Err(e) => return OperationResult::from_error(&e),
     };
 
     match operations::copy::copy_path(&src_str, &dst_str) {
         Ok(msg) => OperationResult::success(&msg),
-        Err(e) => OperationResult::error(&e.to_string()),
+        Err(e) => OperationResult::from_error(&e),
     }
-}
\ No newline at end of file
+}
+/// FFI wrapper for copy_path with progress reporting
+#[no_mangle]
+pub extern "C" fn copy_path_with_progress(
+    src: *const c_char,
+    dst: *const c_char,
+    callback: ProgressCallback,
+    user_data: usize,
+) -> OperationResult {
+    let src_str = match c_str_to_string(src) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let dst_str = match c_str_to_string(dst) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let mut progress = callback_reporter(callback, user_data);
+    match operations::copy::copy_path_with_progress(&src_str, &dst_str, &mut progress) {
+        Ok(msg) => OperationResult::success(&msg),
+        Err(e) => OperationResult::from_error(&e),
+    }
+}
+
+/// FFI wrapper for move_path with progress reporting
+#[no_mangle]
+pub extern "C" fn move_path_with_progress(
+    src: *const c_char,
+    dst: *const c_char,
+    callback: ProgressCallback,
+    user_data: usize,
+) -> OperationResult {
+    let src_str = match c_str_to_string(src) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let dst_str = match c_str_to_string(dst) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let mut progress = callback_reporter(callback, user_data);
+    match operations::move_ops::move_path_with_progress(&src_str, &dst_str, &mut progress) {
+        Ok(msg) => OperationResult::success(&msg),
+        Err(e) => OperationResult::from_error(&e),
+    }
+}
+
+/// FFI wrapper for delete_path with progress reporting
+#[no_mangle]
+pub extern "C" fn delete_path_with_progress(
+    path: *const c_char,
+    callback: ProgressCallback,
+    user_data: usize,
+) -> OperationResult {
+    let mut progress = callback_reporter(callback, user_data);
+    match c_str_to_string(path) {
+        Ok(path_str) => match operations::delete::delete_path_with_progress(&path_str, &mut progress) {
+            Ok(msg) => OperationResult::success(&msg),
+            Err(e) => OperationResult::from_error(&e),
+        },
+        Err(e) => OperationResult::from_error(&e),
+    }
+}
+
+/// FFI wrapper for copy_path with options, progress and conflict reporting
+#[no_mangle]
+pub extern "C" fn copy_path_with_options(
+    src: *const c_char,
+    dst: *const c_char,
+    options: *const TransferOptions,
+    progress_callback: ProgressCallback,
+    conflict_callback: ConflictCallback,
+    user_data: usize,
+) -> OperationResult {
+    let src_str = match c_str_to_string(src) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let dst_str = match c_str_to_string(dst) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let options = match copy_options(options) {
+        Ok(options) => options,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let mut progress = callback_reporter(progress_callback, user_data);
+    let mut conflicts = callback_conflict_log(conflict_callback, user_data);
+    match operations::copy::copy_path_with_options(&src_str, &dst_str, &options, &mut progress, &mut conflicts) {
+        Ok(outcome) => OperationResult::success(&outcome.message).with_flags(strategy_flag(outcome.strategy)),
+        Err(e) => OperationResult::from_error(&e),
+    }
+}
+
+/// FFI wrapper for move_path with options, progress and conflict reporting
+#[no_mangle]
+pub extern "C" fn move_path_with_options(
+    src: *const c_char,
+    dst: *const c_char,
+    options: *const TransferOptions,
+    progress_callback: ProgressCallback,
+    conflict_callback: ConflictCallback,
+    user_data: usize,
+) -> OperationResult {
+    let src_str = match c_str_to_string(src) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let dst_str = match c_str_to_string(dst) {
+        Ok(s) => s,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let options = match copy_options(options) {
+        Ok(options) => options,
+        Err(e) => return OperationResult::from_error(&e),
+    };
+
+    let mut progress = callback_reporter(progress_callback, user_data);
+    let mut conflicts = callback_conflict_log(conflict_callback, user_data);
+    match operations::move_ops::move_path_with_options(&src_str, &dst_str, &options, &mut progress, &mut conflicts) {
+        Ok(outcome) if outcome.copied => {
+            OperationResult::success(&outcome.message).with_flags(RESULT_FLAG_MOVED_BY_COPY)
+        }
+        Ok(outcome) => OperationResult::success(&outcome.message),
+        Err(e) => OperationResult::from_error(&e),
+    }
+}
@@ -5,12 +5,14 @@ pub mod operations;
 pub mod ffi;
 
 // Re-export main types for convenience
-pub use common::{FsError, FsResult};
+pub use common::{ErrorKind, FsError, FsResult};
 pub use operations::{
-    copy::copy_path as copy_operation,
+    conflict::{ConflictAction, ConflictPolicy},
+    copy::{copy_path as copy_operation, CopyOptions, SymlinkPolicy},
     create::{create_file, create_folder},
     delete::delete_path as delete_operation,
     file_permissions::change_permissions as change_perms,
     move_ops::move_path as move_operation,
+    progress::{Progress, ProgressReporter},
     rename::rename_path as rename_operation,
 };
\ No newline at end of file
@@ -0,0 +1,275 @@
+use crate::common::{FsError, FsResult};
+use std::fs;
+use std::io;
+use std::path::{Path, PathBuf};
+
+/// What to do when the destination of a copy or move already exists
+/// The numeric values are part of the C ABI and must match the Go side
+#[repr(i32)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum ConflictPolicy {
+    /// Replace the existing entry
+    #[default]
+    Overwrite = 0,
+    /// Fail with AlreadyExists
+    Fail = 1,
+    /// Leave the existing entry and skip the source
+    Skip = 2,
+    /// Keep both by writing to a free "name (n).ext" path
+    KeepBoth = 3,
+    /// Replace the existing entry only if the source was modified more recently
+    OverwriteIfNewer = 4,
+}
+
+impl ConflictPolicy {
+    /// Convert the raw value received over the FFI boundary
+    pub fn from_raw(value: i32) -> Option<Self> {
+        match value {
+            0 => Some(ConflictPolicy::Overwrite),
+            1 => Some(ConflictPolicy::Fail),
+            2 => Some(ConflictPolicy::Skip),
+            3 => Some(ConflictPolicy::KeepBoth),
+            4 => Some(ConflictPolicy::OverwriteIfNewer),
+            _ => None,
+        }
+    }
+}
+
+/// How a single conflict was resolved
+/// The numeric values are part of the C ABI and must match the Go side
+#[repr(i32)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ConflictAction {
+    Overwritten = 1,
+    Skipped = 2,
+    Renamed = 3,
+}
+
+/// A conflicting entry and what was done about it
+#[derive(Debug, Clone)]
+pub struct Conflict {
+    pub source: PathBuf,
+    /// The path that was written, which differs from the requested one for renames
+    pub destination: PathBuf,
+    pub action: ConflictAction,
+}
+
+/// Outcome of resolving a possible conflict
+#[derive(Debug, PartialEq, Eq)]
+pub enum Resolution {
+    /// Write to the given path; `existing` tells whether it must be replaced
+    Write { path: PathBuf, existing: bool },
+    /// Leave the destination alone
+    Skip,
+}
+
+/// Collects resolved conflicts and forwards each one to an optional observer
+pub struct ConflictLog<'a> {
+    conflicts: Vec<Conflict>,
+    observer: Option<Box<dyn FnMut(&Conflict) + 'a>>,
+}
+
+impl<'a> ConflictLog<'a> {
+    /// Create a log that calls `observer` for every conflict
+    pub fn new(observer: impl FnMut(&Conflict) + 'a) -> Self {
+        ConflictLog {
+            conflicts: Vec::new(),
+            observer: Some(Box::new(observer)),
+        }
+    }
+
+    /// Create a log that only records conflicts
+    pub fn silent() -> Self {
+        ConflictLog {
+            conflicts: Vec::new(),
+            observer: None,
+        }
+    }
+
+    /// All conflicts resolved so far
+    pub fn conflicts(&self) -> &[Conflict] {
+        &self.conflicts
+    }
+
+    /// Record a resolved conflict
+    pub fn record(&mut self, source: &Path, destination: &Path, action: ConflictAction) {
+        let conflict = Conflict {
+            source: source.to_path_buf(),
+            destination: destination.to_path_buf(),
+            action,
+        };
+        if let Some(observer) = self.observer.as_mut() {
+            observer(&conflict);
+        }
+        self.conflicts.push(conflict);
+    }
+
+    /// Short summary such as "2 skipped, 1 renamed", empty without conflicts
+    pub fn summary(&self) -> String {
+        let count = |action| self.conflicts.iter().filter(|c| c.action == action).count();
+        let parts: Vec<String> = [
+            (ConflictAction::Overwritten, "overwritten"),
+            (ConflictAction::Skipped, "skipped"),
+            (ConflictAction::Renamed, "renamed"),
+        ]
+        .iter()
+        .filter_map(|(action, label)| match count(*action) {
+            0 => None,
+            n => Some(format!("{} {}", n, label)),
+        })
+        .collect();
+        parts.join(", ")
+    }
+}
+
+/// Decide where `src` should be written given that `dst` may already exist
+/// `src_metadata` describes what will be written, the link itself or its target.
+/// Conflicts are recorded in `log`. With `merge` set, directories that exist on both
+/// sides are merged by returning Write with `existing` set, except for KeepBoth which
+/// picks a new name; otherwise they are treated like any other entry
+pub fn resolve(
+    src: &Path,
+    src_metadata: &fs::Metadata,
+    dst: &Path,
+    policy: ConflictPolicy,
+    merge: bool,
+    log: &mut ConflictLog,
+) -> FsResult<Resolution> {
+    let dst_metadata = match fs::symlink_metadata(dst) {
+        Ok(metadata) => metadata,
+        Err(e) if e.kind() == io::ErrorKind::NotFound => {
+            return Ok(Resolution::Write {
+                path: dst.to_path_buf(),
+                existing: false,
+            })
+        }
+        Err(e) => return Err(e.into()),
+    };
+    let merge_dirs = merge && src_metadata.is_dir() && dst_metadata.is_dir();
+
+    let action = match policy {
+        ConflictPolicy::Fail => {
+            return Err(FsError::Io(io::Error::new(
+                io::ErrorKind::AlreadyExists,
+                format!("Destination already exists: {}", dst.display()),
+            )))
+        }
+        ConflictPolicy::Skip if !merge_dirs => ConflictAction::Skipped,
+        ConflictPolicy::KeepBoth => {
+            let path = keep_both_path(dst);
+            log.record(src, &path, ConflictAction::Renamed);
+            return Ok(Resolution::Write {
+                path,
+                existing: false,
+            });
+        }
+        ConflictPolicy::OverwriteIfNewer if !merge_dirs => {
+            let src_modified = src_metadata.modified()?;
+            let dst_modified = dst_metadata.modified()?;
+            if src_modified > dst_modified {
+                ConflictAction::Overwritten
+            } else {
+                ConflictAction::Skipped
+            }
+        }
+        _ if merge_dirs => {
+            // Directories are merged, conflicts are resolved for their entries
+            return Ok(Resolution::Write {
+                path: dst.to_path_buf(),
+                existing: true,
+            });
+        }
+        _ => ConflictAction::Overwritten,
+    };
+
+    if action == ConflictAction::Overwritten && dst_metadata.is_dir() != src_metadata.is_dir() {
+        return Err(FsError::Io(io::Error::new(
+            io::ErrorKind::AlreadyExists,
+            format!("Cannot replace {}: file and directory types differ", dst.display()),
+        )));
+    }
+
+    log.record(src, dst, action);
+    match action {
+        ConflictAction::Skipped => Ok(Resolution::Skip),
+        _ => Ok(Resolution::Write {
+            path: dst.to_path_buf(),
+            existing: true,
+        }),
+    }
+}
+
+/// Find a free path next to `path` in the form "name (n).ext"
+pub fn keep_both_path(path: &Path) -> PathBuf {
+    let parent = path.parent().unwrap_or_else(|| Path::new(""));
+    let file_name = path
+        .file_name()
+        .map(|n| n.to_string_lossy().into_owned())
+        .unwrap_or_default();
+
+    // A leading dot starts a hidden name rather than an extension
+    let (stem, extension) = match file_name.rfind('.') {
+        Some(index) if index > 0 => file_name.split_at(index),
+        _ => (file_name.as_str(), ""),
+    };
+
+    let mut n = 1;
+    loop {
+        let candidate = parent.join(format!("{} ({}){}", stem, n, extension));
+        if fs::symlink_metadata(&candidate).is_err() {
+            return candidate;
+        }
+        n += 1;
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_keep_both_path() {
+        let root = "/tmp/test_conflict_keep_both";
+        let _ = fs::remove_dir_all(root);
+        fs::create_dir_all(root).unwrap();
+        fs::write(format!("{}/file.txt", root), "").unwrap();
+        fs::write(format!("{}/file (1).txt", root), "").unwrap();
+
+        let path = keep_both_path(Path::new(&format!("{}/file.txt", root)));
+        assert_eq!(path, PathBuf::from(format!("{}/file (2).txt", root)));
+
+        let path = keep_both_path(Path::new(&format!("{}/.hidden", root)));
+        assert_eq!(path, PathBuf::from(format!("{}/.hidden (1)", root)));
+
+        let _ = fs::remove_dir_all(root);
+    }
+
+    #[test]
+    fn test_resolve_policies() {
+        let root = "/tmp/test_conflict_resolve";
+        let _ = fs::remove_dir_all(root);
+        fs::create_dir_all(root).unwrap();
+        let src = PathBuf::from(format!("{}/src.txt", root));
+        let dst = PathBuf::from(format!("{}/dst.txt", root));
+        fs::write(&dst, "old").unwrap();
+        fs::write(&src, "new").unwrap();
+
+        let meta = fs::metadata(&src).unwrap();
+        let mut log = ConflictLog::silent();
+        assert!(resolve(&src, &meta, &dst, ConflictPolicy::Fail, true, &mut log).is_err());
+        assert_eq!(resolve(&src, &meta, &dst, ConflictPolicy::Skip, true, &mut log).unwrap(), Resolution::Skip);
+        assert_eq!(
+            resolve(&src, &meta, &dst, ConflictPolicy::Overwrite, true, &mut log).unwrap(),
+            Resolution::Write { path: dst.clone(), existing: true }
+        );
+
+        let missing = PathBuf::from(format!("{}/missing.txt", root));
+        assert_eq!(
+            resolve(&src, &meta, &missing, ConflictPolicy::Fail, true, &mut log).unwrap(),
+            Resolution::Write { path: missing, existing: false }
+        );
+        assert_eq!(log.summary(), "1 overwritten, 1 skipped");
+
+        let _ = fs::remove_dir_all(root);
+    }
+}
@@ -1,42 +1,327 @@
 use crate::common::FsResult;
+use crate::operations::conflict::{resolve, ConflictLog, ConflictPolicy, Resolution};
+use crate::operations::fast_copy::{copy_contents, CopyStrategy};
+use crate::operations::metadata::copy_metadata;
+use crate::operations::progress::{scan_tree, ProgressReporter};
+use std::collections::HashMap;
 use std::fs;
-use std::path::Path;
+use std::io;
+use std::path::{Path, PathBuf};
+
+/// What a copy does with symbolic links it encounters
+/// The numeric values are part of the C ABI and must match the Go side
+#[repr(i32)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum SymlinkPolicy {
+    /// Recreate the link pointing at the same target
+    #[default]
+    Preserve = 0,
+    /// Copy what the link points to; links leading back into a directory
+    /// that is being copied are skipped instead of recursing forever
+    Follow = 1,
+    /// Leave links out of the copy
+    Skip = 2,
+}
+
+impl SymlinkPolicy {
+    /// Convert the raw value received over the FFI boundary
+    pub fn from_raw(value: i32) -> Option<Self> {
+        match value {
+            0 => Some(SymlinkPolicy::Preserve),
+            1 => Some(SymlinkPolicy::Follow),
+            2 => Some(SymlinkPolicy::Skip),
+            _ => None,
+        }
+    }
+}
+
+/// Options controlling how entries are copied or moved
+#[derive(Debug, Clone, Copy, Default)]
+pub struct CopyOptions {
+    /// What to do with entries that already exist at the destination
+    pub conflict: ConflictPolicy,
+    /// What to do with symbolic links inside the source
+    pub symlinks: SymlinkPolicy,
+    /// Preserve permissions, times, ownership and extended attributes like `cp -a`
+    pub preserve_metadata: bool,
+}
+
+/// Outcome of a successful copy
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CopyOutcome {
+    pub message: String,
+    /// Slowest strategy any file needed, None when no file data was copied
+    pub strategy: Option<CopyStrategy>,
+}
 
 /// Copy a file or directory from source to destination
 /// Recursively copies directories and their contents
 pub fn copy_path(src: &str, dst: &str) -> FsResult<String> {
+    copy_path_with_progress(src, dst, &mut ProgressReporter::silent())
+}
+
+/// Copy a file or directory, reporting files and bytes copied to `progress`
+pub fn copy_path_with_progress(
+    src: &str,
+    dst: &str,
+    progress: &mut ProgressReporter,
+) -> FsResult<String> {
+    copy_path_with_options(
+        src,
+        dst,
+        &CopyOptions::default(),
+        progress,
+        &mut ConflictLog::silent(),
+    )
+    .map(|outcome| outcome.message)
+}
+
+/// Copy a file or directory using `options`
+/// Existing entries are resolved according to the conflict policy and recorded in `conflicts`.
+/// Files that are hard-linked to each other inside the source stay hard-linked in the copy.
+/// File contents are copied with the fastest strategy available, see `copy_contents`.
+pub fn copy_path_with_options(
+    src: &str,
+    dst: &str,
+    options: &CopyOptions,
+    progress: &mut ProgressReporter,
+    conflicts: &mut ConflictLog,
+) -> FsResult<CopyOutcome> {
     let src_path = Path::new(src);
-    
-    if src_path.is_dir() {
-        copy_dir_all(src, dst)?;
-    } else {
-        fs::copy(src, dst)?;
+    let (files, bytes) = scan_tree(src_path)?;
+    progress.set_totals(files, bytes);
+
+    let mut copier = Copier {
+        options,
+        progress,
+        conflicts,
+        ancestors: Vec::new(),
+        hard_links: HashMap::new(),
+        links_skipped: 0,
+        loops_skipped: 0,
+        strategy: None,
+    };
+    copier.copy_entry(src_path, Path::new(dst))?;
+
+    let mut notes = Vec::new();
+    let summary = copier.conflicts.summary();
+    if !summary.is_empty() {
+        notes.push(summary);
     }
-    
-    Ok(format!("Copied: {} -> {}", src, dst))
+    if copier.links_skipped > 0 {
+        notes.push(format!("{} symlinks skipped", copier.links_skipped));
+    }
+    if copier.loops_skipped > 0 {
+        notes.push(format!("{} symlink loops skipped", copier.loops_skipped));
+    }
+
+    let message = if notes.is_empty() {
+        format!("Copied: {} -> {}", src, dst)
+    } else {
+        format!("Copied: {} -> {} ({})", src, dst, notes.join(", "))
+    };
+    Ok(CopyOutcome {
+        message,
+        strategy: copier.strategy,
+    })
 }
 
-/// Recursively copy a directory and all its contents
-fn copy_dir_all(src: &str, dst: &str) -> std::io::Result<()> {
-    fs::create_dir_all(dst)?;
-    
-    for entry in fs::read_dir(src)? {
-        let entry = entry?;
-        let file_type = entry.file_type()?;
-        let src_path = entry.path();
-        let dst_path = Path::new(dst).join(entry.file_name());
-        
-        if file_type.is_dir() {
-            copy_dir_all(
-                src_path.to_str().unwrap(),
-                dst_path.to_str().unwrap()
-            )?;
-        } else {
-            fs::copy(&src_path, &dst_path)?;
+/// Identity of a file on disk, used to detect loops and hard links
+type FileId = (u64, u64);
+
+#[cfg(unix)]
+fn file_id(metadata: &fs::Metadata) -> Option<FileId> {
+    use std::os::unix::fs::MetadataExt;
+    Some((metadata.dev(), metadata.ino()))
+}
+
+#[cfg(not(unix))]
+fn file_id(_metadata: &fs::Metadata) -> Option<FileId> {
+    None
+}
+
+#[cfg(unix)]
+fn link_count(metadata: &fs::Metadata) -> u64 {
+    use std::os::unix::fs::MetadataExt;
+    metadata.nlink()
+}
+
+#[cfg(not(unix))]
+fn link_count(_metadata: &fs::Metadata) -> u64 {
+    1
+}
+
+#[cfg(unix)]
+fn create_symlink(target: &Path, link: &Path) -> io::Result<()> {
+    std::os::unix::fs::symlink(target, link)
+}
+
+#[cfg(not(unix))]
+fn create_symlink(_target: &Path, _link: &Path) -> io::Result<()> {
+    Err(io::Error::new(io::ErrorKind::Unsupported, "Symlinks are not supported on this platform"))
+}
+
+/// State shared by the steps of a single copy
+struct Copier<'o, 'p, 'a, 'c> {
+    options: &'o CopyOptions,
+    progress: &'p mut ProgressReporter<'a>,
+    conflicts: &'p mut ConflictLog<'c>,
+    /// Directories currently being copied, outermost first
+    ancestors: Vec<FileId>,
+    /// First copy of every multiply-linked source file
+    hard_links: HashMap<FileId, PathBuf>,
+    links_skipped: u64,
+    loops_skipped: u64,
+    /// Slowest strategy used for file contents so far
+    strategy: Option<CopyStrategy>,
+}
+
+impl Copier<'_, '_, '_, '_> {
+    /// Copy a file, directory or symlink after resolving a conflict at the destination
+    fn copy_entry(&mut self, src: &Path, dst: &Path) -> FsResult<()> {
+        let mut metadata = fs::symlink_metadata(src)?;
+
+        if metadata.file_type().is_symlink() {
+            match self.options.symlinks {
+                SymlinkPolicy::Skip => {
+                    self.links_skipped += 1;
+                    self.progress.skip(1, 0);
+                    return Ok(());
+                }
+                SymlinkPolicy::Preserve => return self.copy_symlink(src, &metadata, dst),
+                SymlinkPolicy::Follow => {
+                    metadata = fs::metadata(src)?;
+                    if metadata.is_dir() && self.is_ancestor(&metadata) {
+                        self.loops_skipped += 1;
+                        self.progress.skip(1, 0);
+                        return Ok(());
+                    }
+
+                    // The scan counted the link itself, count what it points to instead
+                    let (files, bytes) = if metadata.is_dir() {
+                        scan_tree(src.canonicalize()?.as_path())?
+                    } else {
+                        (1, metadata.len())
+                    };
+                    self.progress.add_totals(files.saturating_sub(1), bytes);
+                }
+            }
         }
+
+        match resolve(src, &metadata, dst, self.options.conflict, true, self.conflicts)? {
+            Resolution::Skip => {
+                let (files, bytes) = scan_tree(src)?;
+                self.progress.skip(files, bytes);
+                Ok(())
+            }
+            Resolution::Write { path, existing } => {
+                if metadata.is_dir() {
+                    self.ancestors.extend(file_id(&metadata));
+                    let result = self.copy_dir_all(src, &path);
+                    if file_id(&metadata).is_some() {
+                        self.ancestors.pop();
+                    }
+                    result?;
+                } else {
+                    if existing {
+                        // Replace the entry itself instead of writing through a symlink
+                        fs::remove_file(&path)?;
+                    }
+                    self.copy_linked_file(src, &metadata, &path)?;
+                }
+
+                // Directories get their metadata last, copying entries would update their times
+                self.preserve_metadata(src, &metadata, &path)
+            }
+        }
+    }
+
+    /// Copy metadata to `dst` when the options ask for it
+    fn preserve_metadata(&self, src: &Path, metadata: &fs::Metadata, dst: &Path) -> FsResult<()> {
+        if self.options.preserve_metadata {
+            copy_metadata(src, metadata, dst)?;
+        }
+        Ok(())
+    }
+
+    /// Whether the directory described by `metadata` is one that is currently being copied
+    fn is_ancestor(&self, metadata: &fs::Metadata) -> bool {
+        match file_id(metadata) {
+            Some(id) => self.ancestors.contains(&id),
+            None => false,
+        }
+    }
+
+    /// Recreate a symlink at the destination
+    fn copy_symlink(&mut self, src: &Path, metadata: &fs::Metadata, dst: &Path) -> FsResult<()> {
+        let path = match resolve(src, metadata, dst, self.options.conflict, false, self.conflicts)? {
+            Resolution::Skip => {
+                self.progress.skip(1, 0);
+                return Ok(());
+            }
+            Resolution::Write { path, existing } => {
+                if existing {
+                    fs::remove_file(&path)?;
+                }
+                path
+            }
+        };
+
+        self.progress.start_file(src)?;
+        create_symlink(&fs::read_link(src)?, &path)?;
+        self.preserve_metadata(src, metadata, &path)?;
+        self.progress.finish_file();
+        Ok(())
+    }
+
+    /// Recursively copy a directory and all its contents
+    fn copy_dir_all(&mut self, src: &Path, dst: &Path) -> FsResult<()> {
+        fs::create_dir_all(dst)?;
+
+        for entry in fs::read_dir(src)? {
+            let entry = entry?;
+            self.copy_entry(&entry.path(), &dst.join(entry.file_name()))?;
+        }
+
+        Ok(())
+    }
+
+    /// Copy a file, linking it to an earlier copy when the source is hard-linked
+    fn copy_linked_file(&mut self, src: &Path, metadata: &fs::Metadata, dst: &Path) -> FsResult<()> {
+        let id = match file_id(metadata) {
+            Some(id) if link_count(metadata) > 1 => id,
+            _ => return self.copy_file(src, dst),
+        };
+
+        if let Some(first_copy) = self.hard_links.get(&id) {
+            self.progress.start_file(src)?;
+            fs::hard_link(first_copy, dst)?;
+            self.progress.add_bytes(metadata.len());
+            self.progress.finish_file();
+            return Ok(());
+        }
+
+        self.copy_file(src, dst)?;
+        self.hard_links.insert(id, dst.to_path_buf());
+        Ok(())
+    }
+
+    /// Copy a single file, reporting progress while large files are copied
+    /// Permission bits are copied like `fs::copy` does
+    fn copy_file(&mut self, src: &Path, dst: &Path) -> FsResult<()> {
+        self.progress.start_file(src)?;
+
+        let reader = fs::File::open(src)?;
+        let writer = fs::File::create(dst)?;
+        let metadata = reader.metadata()?;
+
+        let strategy = copy_contents(&reader, &writer, metadata.len(), self.progress)?;
+        self.strategy = self.strategy.max(strategy);
+
+        fs::set_permissions(dst, metadata.permissions())?;
+        self.progress.finish_file();
+        Ok(())
     }
-    
-    Ok(())
 }
 
 #[cfg(test)]
@@ -59,4 +344,143 @@ mod tests {
         let _ = fs::remove_file(src);
         let _ = fs::remove_file(dst);
     }
+
+    #[test]
+    fn test_copy_dir_with_progress() {
+        let src = "/tmp/test_copy_progress_src";
+        let dst = "/tmp/test_copy_progress_dst";
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+
+        fs::create_dir_all(format!("{}/nested", src)).unwrap();
+        fs::write(format!("{}/one.txt", src), "one").unwrap();
+        fs::write(format!("{}/nested/two.txt", src), "two!").unwrap();
+
+        let mut last = None;
+        let result = copy_path_with_progress(src, dst, &mut ProgressReporter::new(|p| {
+            last = Some(p.clone());
+            true
+        }));
+        assert!(result.is_ok());
+
+        let last = last.unwrap();
+        assert_eq!(last.files_done, 2);
+        assert_eq!(last.files_total, 2);
+        assert_eq!(last.bytes_done, 7);
+        assert_eq!(last.bytes_total, 7);
+        assert!(Path::new(&format!("{}/nested/two.txt", dst)).exists());
+
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+    }
+
+    #[test]
+    fn test_copy_conflict_policies() {
+        let src = "/tmp/test_copy_conflict_src";
+        let dst = "/tmp/test_copy_conflict_dst";
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+
+        fs::create_dir_all(src).unwrap();
+        fs::create_dir_all(dst).unwrap();
+        fs::write(format!("{}/same.txt", src), "new").unwrap();
+        fs::write(format!("{}/fresh.txt", src), "fresh").unwrap();
+        fs::write(format!("{}/same.txt", dst), "old").unwrap();
+
+        let skip = CopyOptions { conflict: ConflictPolicy::Skip, ..Default::default() };
+        let mut log = ConflictLog::silent();
+        copy_path_with_options(src, dst, &skip, &mut ProgressReporter::silent(), &mut log).unwrap();
+        assert_eq!(fs::read_to_string(format!("{}/same.txt", dst)).unwrap(), "old");
+        assert!(Path::new(&format!("{}/fresh.txt", dst)).exists());
+        assert_eq!(log.conflicts().len(), 1);
+
+        let keep_both = CopyOptions { conflict: ConflictPolicy::KeepBoth, ..Default::default() };
+        let file_src = format!("{}/same.txt", src);
+        let file_dst = format!("{}/same.txt", dst);
+        copy_path_with_options(&file_src, &file_dst, &keep_both, &mut ProgressReporter::silent(), &mut ConflictLog::silent()).unwrap();
+        assert_eq!(fs::read_to_string(format!("{}/same (1).txt", dst)).unwrap(), "new");
+
+        let fail = CopyOptions { conflict: ConflictPolicy::Fail, ..Default::default() };
+        assert!(copy_path_with_options(&file_src, &file_dst, &fail, &mut ProgressReporter::silent(), &mut ConflictLog::silent()).is_err());
+
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_copy_preserve_metadata() {
+        use std::os::unix::fs::PermissionsExt;
+        use std::time::{Duration, SystemTime};
+
+        let src = "/tmp/test_copy_archive_src";
+        let dst = "/tmp/test_copy_archive_dst";
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+        fs::create_dir_all(format!("{}/nested", src)).unwrap();
+        fs::write(format!("{}/nested/file.txt", src), "data").unwrap();
+
+        let past = SystemTime::now() - Duration::from_secs(86400);
+        for path in [format!("{}/nested/file.txt", src), format!("{}/nested", src)] {
+            fs::File::open(&path).unwrap().set_modified(past).unwrap();
+        }
+        fs::set_permissions(format!("{}/nested/file.txt", src), fs::Permissions::from_mode(0o600)).unwrap();
+
+        let options = CopyOptions { preserve_metadata: true, ..Default::default() };
+        copy_path_with_options(src, dst, &options, &mut ProgressReporter::silent(), &mut ConflictLog::silent()).unwrap();
+
+        let file = fs::metadata(format!("{}/nested/file.txt", dst)).unwrap();
+        assert_eq!(file.modified().unwrap(), past);
+        assert_eq!(file.permissions().mode() & 0o777, 0o600);
+        let dir = fs::metadata(format!("{}/nested", dst)).unwrap();
+        assert_eq!(dir.modified().unwrap(), past);
+
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_copy_symlinks() {
+        let src = "/tmp/test_copy_symlink_src";
+        let _ = fs::remove_dir_all(src);
+        fs::create_dir_all(format!("{}/dir", src)).unwrap();
+        fs::write(format!("{}/dir/file.txt", src), "data").unwrap();
+        std::os::unix::fs::symlink("file.txt", format!("{}/dir/link.txt", src)).unwrap();
+        // A link back to the root forms a loop when followed
+        std::os::unix::fs::symlink("..", format!("{}/dir/loop", src)).unwrap();
+        fs::hard_link(format!("{}/dir/file.txt", src), format!("{}/hard.txt", src)).unwrap();
+
+        for (policy, name) in [
+            (SymlinkPolicy::Preserve, "preserve"),
+            (SymlinkPolicy::Follow, "follow"),
+            (SymlinkPolicy::Skip, "skip"),
+        ] {
+            let dst = format!("/tmp/test_copy_symlink_{}", name);
+            let _ = fs::remove_dir_all(&dst);
+
+            let options = CopyOptions { symlinks: policy, ..Default::default() };
+            let result = copy_path_with_options(src, &dst, &options, &mut ProgressReporter::silent(), &mut ConflictLog::silent());
+            assert!(result.is_ok(), "{}: {:?}", name, result);
+
+            let link = fs::symlink_metadata(format!("{}/dir/link.txt", dst));
+            match policy {
+                SymlinkPolicy::Preserve => assert!(link.unwrap().file_type().is_symlink()),
+                SymlinkPolicy::Follow => assert!(link.unwrap().is_file()),
+                SymlinkPolicy::Skip => assert!(link.is_err()),
+            }
+            if policy == SymlinkPolicy::Follow {
+                assert!(result.unwrap().message.contains("1 symlink loops skipped"));
+            }
+
+            use std::os::unix::fs::MetadataExt;
+            let first = fs::metadata(format!("{}/dir/file.txt", dst)).unwrap();
+            let second = fs::metadata(format!("{}/hard.txt", dst)).unwrap();
+            assert_eq!(first.ino(), second.ino(), "{}: hard link not preserved", name);
+
+            let _ = fs::remove_dir_all(&dst);
+        }
+
+        let _ = fs::remove_dir_all(src);
+    }
 }
\ No newline at end of file
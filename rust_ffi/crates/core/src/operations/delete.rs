@@ -1,21 +1,47 @@
 use crate::common::FsResult;
+use crate::operations::progress::{scan_tree, ProgressReporter};
 use std::fs;
 use std::path::Path;
 
 /// Delete a file or directory at the specified path
 /// Recursively deletes directories and their contents
 pub fn delete_path(path: &str) -> FsResult<String> {
+    delete_path_with_progress(path, &mut ProgressReporter::silent())
+}
+
+/// Delete a file or directory, reporting every removed file to `progress`
+/// Symlinks are removed without following them
+pub fn delete_path_with_progress(path: &str, progress: &mut ProgressReporter) -> FsResult<String> {
     let path_obj = Path::new(path);
-    
-    if path_obj.is_dir() {
-        fs::remove_dir_all(path)?;
-    } else {
-        fs::remove_file(path)?;
-    }
-    
+    let (files, bytes) = scan_tree(path_obj)?;
+    progress.set_totals(files, bytes);
+
+    remove_entry(path_obj, progress)?;
+
     Ok(format!("Deleted: {}", path))
 }
 
+/// Remove a single entry, descending into real directories depth-first
+fn remove_entry(path: &Path, progress: &mut ProgressReporter) -> FsResult<()> {
+    let metadata = fs::symlink_metadata(path)?;
+
+    if metadata.is_dir() {
+        for entry in fs::read_dir(path)? {
+            remove_entry(&entry?.path(), progress)?;
+        }
+        fs::remove_dir(path)?;
+        return Ok(());
+    }
+
+    progress.start_file(path)?;
+    fs::remove_file(path)?;
+    if !metadata.file_type().is_symlink() {
+        progress.add_bytes(metadata.len());
+    }
+    progress.finish_file();
+    Ok(())
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -0,0 +1,229 @@
+use crate::common::FsResult;
+use crate::operations::progress::ProgressReporter;
+use std::fs::File;
+
+/// Buffer size used when file contents are copied through user space
+pub const COPY_BUFFER_SIZE: usize = 1024 * 1024;
+
+/// How the contents of a file were copied, from fastest to slowest
+/// The numeric values are part of the C ABI and must match the Go side
+#[repr(i32)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord)]
+pub enum CopyStrategy {
+    /// The copy shares the data blocks of the source (FICLONE on btrfs, XFS and others)
+    Reflink = 1,
+    /// The kernel copied the data with `copy_file_range` without passing it through user space
+    CopyFileRange = 2,
+    /// The data was read into a buffer and written out
+    Buffered = 3,
+}
+
+/// Copy the `len` bytes of `reader` into the empty file `writer`
+///
+/// On Linux a reflink clone is tried first, then `copy_file_range` and finally a
+/// buffered copy. Only the data segments of sparse sources are copied, so holes stay
+/// holes. Elsewhere the contents are copied through a buffer. Returns the strategy
+/// that was used, or None when there was no data to copy.
+#[cfg(target_os = "linux")]
+pub fn copy_contents(
+    reader: &File,
+    writer: &File,
+    len: u64,
+    progress: &mut ProgressReporter,
+) -> FsResult<Option<CopyStrategy>> {
+    if linux::clone(reader, writer) {
+        progress.add_bytes(len);
+        return Ok(Some(CopyStrategy::Reflink));
+    }
+
+    let mut strategy = None;
+    let mut copied = 0;
+    for (start, end) in linux::data_segments(reader, len)? {
+        let used = linux::copy_range(reader, writer, start, end, strategy, progress)?;
+        strategy = strategy.max(Some(used));
+        copied += end - start;
+    }
+
+    // Trailing holes are not written, extending the file recreates them
+    writer.set_len(len)?;
+    progress.add_bytes(len - copied);
+    Ok(strategy)
+}
+
+/// Copy `reader` into `writer` through a buffer
+#[cfg(not(target_os = "linux"))]
+pub fn copy_contents(
+    mut reader: &File,
+    mut writer: &File,
+    _len: u64,
+    progress: &mut ProgressReporter,
+) -> FsResult<Option<CopyStrategy>> {
+    use std::io::{Read, Write};
+
+    let mut buffer = vec![0u8; COPY_BUFFER_SIZE];
+    let mut strategy = None;
+    loop {
+        let read = reader.read(&mut buffer)?;
+        if read == 0 {
+            break;
+        }
+        writer.write_all(&buffer[..read])?;
+        progress.add_bytes(read as u64);
+        strategy = Some(CopyStrategy::Buffered);
+    }
+    Ok(strategy)
+}
+
+#[cfg(target_os = "linux")]
+mod linux {
+    use super::{CopyStrategy, COPY_BUFFER_SIZE};
+    use crate::common::FsResult;
+    use crate::operations::progress::ProgressReporter;
+    use std::fs::File;
+    use std::io;
+    use std::os::unix::fs::FileExt;
+    use std::os::unix::io::AsRawFd;
+
+    /// ioctl request sharing the extents of one file with another, _IOW(0x94, 9, int)
+    const FICLONE: libc::c_ulong = 0x4004_9409;
+
+    /// Clone the whole of `reader` into `writer`
+    /// Any failure means the filesystems cannot share extents and the data has to be copied.
+    pub fn clone(reader: &File, writer: &File) -> bool {
+        unsafe { libc::ioctl(writer.as_raw_fd(), FICLONE as _, reader.as_raw_fd()) == 0 }
+    }
+
+    /// Byte ranges of `file` holding data, in order
+    /// Filesystems without SEEK_DATA support report the whole file as data.
+    pub fn data_segments(file: &File, len: u64) -> FsResult<Vec<(u64, u64)>> {
+        let fd = file.as_raw_fd();
+        let mut segments = Vec::new();
+        let mut offset = 0;
+
+        while offset < len {
+            let start = unsafe { libc::lseek(fd, offset as libc::off_t, libc::SEEK_DATA) };
+            if start < 0 {
+                let err = io::Error::last_os_error();
+                return match err.raw_os_error() {
+                    // No data after offset, the rest of the file is a hole
+                    Some(libc::ENXIO) => Ok(segments),
+                    Some(libc::EINVAL) | Some(libc::EOPNOTSUPP) => Ok(vec![(0, len)]),
+                    _ => Err(err.into()),
+                };
+            }
+            let end = unsafe { libc::lseek(fd, start, libc::SEEK_HOLE) };
+            let end = if end < 0 { len } else { (end as u64).min(len) };
+
+            let start = start as u64;
+            if start >= len {
+                break;
+            }
+            segments.push((start, end));
+            offset = end;
+        }
+
+        Ok(segments)
+    }
+
+    /// Copy the bytes from `start` to `end` of `reader` to the same offsets of `writer`
+    /// `copy_file_range` is used unless an earlier segment already had to be buffered
+    /// or the kernel refuses it for these files.
+    pub fn copy_range(
+        reader: &File,
+        writer: &File,
+        start: u64,
+        end: u64,
+        previous: Option<CopyStrategy>,
+        progress: &mut ProgressReporter,
+    ) -> FsResult<CopyStrategy> {
+        let mut offset = start;
+
+        if previous != Some(CopyStrategy::Buffered) {
+            while offset < end {
+                let mut off_in = offset as libc::loff_t;
+                let mut off_out = offset as libc::loff_t;
+                let chunk = ((end - offset) as usize).min(COPY_BUFFER_SIZE * 8);
+                let copied = unsafe {
+                    libc::copy_file_range(reader.as_raw_fd(), &mut off_in, writer.as_raw_fd(), &mut off_out, chunk, 0)
+                };
+                if copied <= 0 {
+                    // Unsupported, or the source shrank; the buffered copy sorts out which
+                    break;
+                }
+                offset += copied as u64;
+                progress.add_bytes(copied as u64);
+            }
+            if offset == end {
+                return Ok(CopyStrategy::CopyFileRange);
+            }
+        }
+
+        let mut buffer = vec![0u8; COPY_BUFFER_SIZE.min((end - offset) as usize)];
+        while offset < end {
+            let want = ((end - offset) as usize).min(buffer.len());
+            let read = reader.read_at(&mut buffer[..want], offset)?;
+            if read == 0 {
+                break;
+            }
+            writer.write_all_at(&buffer[..read], offset)?;
+            offset += read as u64;
+            progress.add_bytes(read as u64);
+        }
+        Ok(CopyStrategy::Buffered)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::fs;
+
+    #[cfg(target_os = "linux")]
+    #[test]
+    fn test_copy_keeps_holes() {
+        use std::os::unix::fs::{FileExt, MetadataExt};
+
+        let src = "/tmp/test_fast_copy_sparse_src";
+        let dst = "/tmp/test_fast_copy_sparse_dst";
+        let len = 64 * 1024 * 1024;
+
+        let file = fs::File::create(src).unwrap();
+        file.set_len(len).unwrap();
+        file.write_all_at(b"head", 0).unwrap();
+        file.write_all_at(b"middle", len / 2).unwrap();
+        drop(file);
+
+        let reader = fs::File::open(src).unwrap();
+        let writer = fs::File::create(dst).unwrap();
+        let mut progress = ProgressReporter::silent();
+        progress.set_totals(1, len);
+        let strategy = copy_contents(&reader, &writer, len, &mut progress).unwrap();
+        assert!(strategy.is_some());
+        assert_eq!(progress.state().bytes_done, len);
+
+        let copy = fs::metadata(dst).unwrap();
+        assert_eq!(copy.len(), len);
+        assert_eq!(fs::read(dst).unwrap(), fs::read(src).unwrap());
+        // Only the written blocks are allocated when the filesystem supports holes
+        assert!(copy.blocks() <= fs::metadata(src).unwrap().blocks() + 8, "holes were filled");
+
+        let _ = fs::remove_file(src);
+        let _ = fs::remove_file(dst);
+    }
+
+    #[test]
+    fn test_copy_empty_file() {
+        let src = "/tmp/test_fast_copy_empty_src";
+        let dst = "/tmp/test_fast_copy_empty_dst";
+        fs::write(src, "").unwrap();
+
+        let reader = fs::File::open(src).unwrap();
+        let writer = fs::File::create(dst).unwrap();
+        let strategy = copy_contents(&reader, &writer, 0, &mut ProgressReporter::silent()).unwrap();
+        assert_ne!(strategy, Some(CopyStrategy::Buffered));
+        assert_eq!(fs::metadata(dst).unwrap().len(), 0);
+
+        let _ = fs::remove_file(src);
+        let _ = fs::remove_file(dst);
+    }
+}
@@ -24,6 +24,29 @@ pub fn change_permissions(path: &str, _mode: u32) -> FsResult<String> {
     ))
 }
 
+/// Change the owner and group of a file or directory (Unix only)
+/// `None` leaves the owner or group unchanged. With `no_follow` a symbolic link is
+/// changed itself instead of what it points to.
+#[cfg(unix)]
+pub fn change_owner(path: &str, uid: Option<u32>, gid: Option<u32>, no_follow: bool) -> FsResult<String> {
+    if no_follow {
+        std::os::unix::fs::lchown(path, uid, gid)?;
+    } else {
+        std::os::unix::fs::chown(path, uid, gid)?;
+    }
+    let id = |id: Option<u32>| id.map_or_else(|| "-".to_string(), |id| id.to_string());
+    Ok(format!("Owner changed: {} ({}:{})", path, id(uid), id(gid)))
+}
+
+/// Change the owner and group of a file or directory (Windows stub)
+#[cfg(not(unix))]
+pub fn change_owner(_path: &str, _uid: Option<u32>, _gid: Option<u32>, _no_follow: bool) -> FsResult<String> {
+    use crate::common::FsError;
+    Err(FsError::PermissionError(
+        "Ownership changes are only supported on Unix systems".to_string()
+    ))
+}
+
 #[cfg(test)]
 #[cfg(unix)]
 mod tests {
@@ -43,4 +66,24 @@ mod tests {
         
         let _ = fs::remove_file(test_path);
     }
+
+    #[test]
+    fn test_change_owner_keeps_ids() {
+        use std::os::unix::fs::MetadataExt;
+
+        let test_path = "/tmp/test_change_owner.txt";
+        fs::File::create(test_path).unwrap();
+        let before = fs::metadata(test_path).unwrap();
+
+        // Giving a file the owner and group it already has is allowed without privileges
+        let result = change_owner(test_path, Some(before.uid()), None, false);
+        assert!(result.is_ok());
+        let result = change_owner(test_path, None, Some(before.gid()), true);
+        assert!(result.is_ok());
+
+        let after = fs::metadata(test_path).unwrap();
+        assert_eq!((after.uid(), after.gid()), (before.uid(), before.gid()));
+
+        let _ = fs::remove_file(test_path);
+    }
 }
\ No newline at end of file
@@ -0,0 +1,179 @@
+use crate::common::FsResult;
+use std::fs;
+use std::path::Path;
+
+/// Copy the metadata described by `metadata` from `src` to `dst` like `cp -a` does
+/// Permission bits and times are always copied, ownership only when running as root
+/// and extended attributes only on Linux. When `metadata` describes a symlink the
+/// link itself is updated, otherwise links are followed.
+pub fn copy_metadata(src: &Path, metadata: &fs::Metadata, dst: &Path) -> FsResult<()> {
+    let is_symlink = metadata.file_type().is_symlink();
+
+    #[cfg(target_os = "linux")]
+    copy_xattrs(src, dst, is_symlink)?;
+    #[cfg(not(target_os = "linux"))]
+    let _ = src;
+
+    #[cfg(unix)]
+    copy_ownership(metadata, dst)?;
+
+    // Ownership changes clear setuid bits, so permissions are set afterwards
+    if !is_symlink {
+        fs::set_permissions(dst, metadata.permissions())?;
+    }
+
+    copy_times(metadata, dst)?;
+    Ok(())
+}
+
+#[cfg(unix)]
+fn c_path(path: &Path) -> std::io::Result<std::ffi::CString> {
+    use std::os::unix::ffi::OsStrExt;
+    std::ffi::CString::new(path.as_os_str().as_bytes())
+        .map_err(|e| std::io::Error::new(std::io::ErrorKind::InvalidInput, e))
+}
+
+/// Set the access and modification times of `dst` without following symlinks
+#[cfg(unix)]
+fn copy_times(metadata: &fs::Metadata, dst: &Path) -> FsResult<()> {
+    use std::os::unix::fs::MetadataExt;
+
+    let path = c_path(dst)?;
+    let times = [
+        libc::timespec {
+            tv_sec: metadata.atime() as libc::time_t,
+            tv_nsec: metadata.atime_nsec() as _,
+        },
+        libc::timespec {
+            tv_sec: metadata.mtime() as libc::time_t,
+            tv_nsec: metadata.mtime_nsec() as _,
+        },
+    ];
+
+    let rc = unsafe {
+        libc::utimensat(libc::AT_FDCWD, path.as_ptr(), times.as_ptr(), libc::AT_SYMLINK_NOFOLLOW)
+    };
+    if rc != 0 {
+        return Err(std::io::Error::last_os_error().into());
+    }
+    Ok(())
+}
+
+#[cfg(not(unix))]
+fn copy_times(metadata: &fs::Metadata, dst: &Path) -> FsResult<()> {
+    if metadata.file_type().is_symlink() {
+        return Ok(());
+    }
+    let times = fs::FileTimes::new()
+        .set_accessed(metadata.accessed()?)
+        .set_modified(metadata.modified()?);
+    fs::File::options().write(true).open(dst)?.set_times(times)?;
+    Ok(())
+}
+
+/// Copy uid and gid when running as root; other users cannot give files away
+#[cfg(unix)]
+fn copy_ownership(metadata: &fs::Metadata, dst: &Path) -> FsResult<()> {
+    use std::os::unix::fs::MetadataExt;
+
+    if unsafe { libc::geteuid() } != 0 {
+        return Ok(());
+    }
+
+    let path = c_path(dst)?;
+    if unsafe { libc::lchown(path.as_ptr(), metadata.uid(), metadata.gid()) } != 0 {
+        return Err(std::io::Error::last_os_error().into());
+    }
+    Ok(())
+}
+
+/// Copy every extended attribute that the destination filesystem accepts
+/// With `link` set the attributes of a symlink itself are copied
+#[cfg(target_os = "linux")]
+fn copy_xattrs(src: &Path, dst: &Path, link: bool) -> FsResult<()> {
+    let (list, get): (
+        unsafe extern "C" fn(*const libc::c_char, *mut libc::c_char, libc::size_t) -> libc::ssize_t,
+        unsafe extern "C" fn(*const libc::c_char, *const libc::c_char, *mut libc::c_void, libc::size_t) -> libc::ssize_t,
+    ) = if link {
+        (libc::llistxattr, libc::lgetxattr)
+    } else {
+        (libc::listxattr, libc::getxattr)
+    };
+    let src_path = c_path(src)?;
+    let dst_path = c_path(dst)?;
+
+    let size = unsafe { list(src_path.as_ptr(), std::ptr::null_mut(), 0) };
+    if size <= 0 {
+        // Filesystems without xattr support have nothing to copy
+        return Ok(());
+    }
+    let mut names = vec![0u8; size as usize];
+    let size = unsafe { list(src_path.as_ptr(), names.as_mut_ptr() as *mut libc::c_char, names.len()) };
+    if size < 0 {
+        return Err(std::io::Error::last_os_error().into());
+    }
+    names.truncate(size as usize);
+
+    for name in names.split(|&b| b == 0).filter(|n| !n.is_empty()) {
+        let name = std::ffi::CString::new(name).unwrap_or_default();
+
+        let size = unsafe { get(src_path.as_ptr(), name.as_ptr(), std::ptr::null_mut(), 0) };
+        if size < 0 {
+            continue;
+        }
+        let mut value = vec![0u8; size as usize];
+        let size = unsafe {
+            get(src_path.as_ptr(), name.as_ptr(), value.as_mut_ptr() as *mut libc::c_void, value.len())
+        };
+        if size < 0 {
+            continue;
+        }
+
+        let rc = unsafe {
+            libc::lsetxattr(dst_path.as_ptr(), name.as_ptr(), value.as_ptr() as *const libc::c_void, size as usize, 0)
+        };
+        if rc != 0 {
+            let err = std::io::Error::last_os_error();
+            // Unsupported namespaces (e.g. security.* as non-root) are skipped like cp -a does
+            match err.raw_os_error() {
+                Some(libc::ENOTSUP) | Some(libc::EPERM) => continue,
+                _ => return Err(err.into()),
+            }
+        }
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::time::{Duration, SystemTime};
+
+    #[test]
+    fn test_copy_metadata() {
+        let src = "/tmp/test_metadata_src.txt";
+        let dst = "/tmp/test_metadata_dst.txt";
+        fs::write(src, "data").unwrap();
+        fs::write(dst, "data").unwrap();
+
+        let past = SystemTime::now() - Duration::from_secs(86400);
+        let file = fs::File::options().write(true).open(src).unwrap();
+        file.set_times(fs::FileTimes::new().set_modified(past).set_accessed(past)).unwrap();
+
+        #[cfg(unix)]
+        {
+            use std::os::unix::fs::PermissionsExt;
+            fs::set_permissions(src, fs::Permissions::from_mode(0o640)).unwrap();
+        }
+
+        let metadata = fs::symlink_metadata(src).unwrap();
+        copy_metadata(Path::new(src), &metadata, Path::new(dst)).unwrap();
+
+        let copied = fs::metadata(dst).unwrap();
+        assert_eq!(copied.modified().unwrap(), metadata.modified().unwrap());
+        assert_eq!(copied.permissions(), metadata.permissions());
+
+        let _ = fs::remove_file(src);
+        let _ = fs::remove_file(dst);
+    }
+}
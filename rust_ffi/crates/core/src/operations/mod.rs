@@ -1,6 +1,11 @@
+pub mod conflict;
 pub mod copy;
 pub mod create;
 pub mod delete;
+pub mod fast_copy;
 pub mod file_permissions;
+pub mod metadata;
 pub mod move_ops;
-pub mod rename;
\ No newline at end of file
+pub mod progress;
+pub mod rename;
+pub mod verify;
\ No newline at end of file
@@ -1,18 +1,159 @@
-use crate::common::FsResult;
+use crate::common::{FsError, FsResult};
+use crate::operations::conflict::{resolve, ConflictLog, ConflictPolicy, Resolution};
+use crate::operations::copy::{copy_path_with_options, CopyOptions, SymlinkPolicy};
+use crate::operations::progress::ProgressReporter;
+use crate::operations::verify::verify_copy;
 use std::fs;
+use std::io;
+use std::path::{Path, PathBuf};
+
+/// Outcome of a successful move
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct MoveOutcome {
+    pub message: String,
+    /// Set when the move crossed filesystems and was carried out by copying
+    pub copied: bool,
+}
 
 /// Move a file or directory from source to destination
 /// This is essentially a rename operation that can work across filesystems
 pub fn move_path(src: &str, dst: &str) -> FsResult<String> {
-    fs::rename(src, dst)?;
-    Ok(format!("Moved: {} -> {}", src, dst))
+    move_path_with_progress(src, dst, &mut ProgressReporter::silent())
+}
+
+/// Move a file or directory, reporting to `progress`
+/// A rename is a single step, so it reports one entry completed
+pub fn move_path_with_progress(
+    src: &str,
+    dst: &str,
+    progress: &mut ProgressReporter,
+) -> FsResult<String> {
+    move_path_with_options(
+        src,
+        dst,
+        &CopyOptions::default(),
+        progress,
+        &mut ConflictLog::silent(),
+    )
+    .map(|outcome| outcome.message)
+}
+
+/// Move a file or directory using `options`
+/// The source is treated as a single entry, so existing directories are not merged.
+/// When source and destination are on different filesystems the source is copied,
+/// the copy is verified and only then the source is deleted; see `move_by_copy`.
+pub fn move_path_with_options(
+    src: &str,
+    dst: &str,
+    options: &CopyOptions,
+    progress: &mut ProgressReporter,
+    conflicts: &mut ConflictLog,
+) -> FsResult<MoveOutcome> {
+    progress.set_totals(1, 0);
+    progress.start_file(Path::new(src))?;
+
+    // A rename moves symlinks themselves, so conflicts are judged on the link
+    let src_metadata = fs::symlink_metadata(src)?;
+    let target = match resolve(Path::new(src), &src_metadata, Path::new(dst), options.conflict, false, conflicts)? {
+        Resolution::Skip => {
+            progress.finish_file();
+            return Ok(MoveOutcome {
+                message: format!("Skipped: {} (destination exists)", src),
+                copied: false,
+            });
+        }
+        Resolution::Write { path, .. } => path,
+    };
+
+    match fs::rename(src, &target) {
+        Ok(()) => {}
+        Err(e) if e.kind() == io::ErrorKind::CrossesDevices => {
+            move_by_copy(Path::new(src), &src_metadata, &target, progress)?;
+            return Ok(MoveOutcome {
+                message: format!("Moved: {} -> {} (copied across filesystems and verified)", src, target.display()),
+                copied: true,
+            });
+        }
+        Err(e) => return Err(e.into()),
+    }
+    progress.finish_file();
+
+    Ok(MoveOutcome {
+        message: format!("Moved: {} -> {}", src, target.display()),
+        copied: false,
+    })
+}
+
+/// Move `src` to `target` on another filesystem
+/// The source is copied to a staging path next to `target` with its metadata, the copy
+/// is compared against the source by size and SHA-256 checksum and renamed into place,
+/// and only then is the source deleted. Copy progress replaces the single rename step
+/// in `progress`. On failure or cancellation the staged copy is removed and the source
+/// is left untouched.
+fn move_by_copy(
+    src: &Path,
+    src_metadata: &fs::Metadata,
+    target: &Path,
+    progress: &mut ProgressReporter,
+) -> FsResult<()> {
+    let staging = staging_path(target);
+    remove_entry(&staging).ok();
+
+    let options = CopyOptions {
+        conflict: ConflictPolicy::Overwrite,
+        symlinks: SymlinkPolicy::Preserve,
+        preserve_metadata: true,
+    };
+    let copied = copy_path_with_options(
+        &src.to_string_lossy(),
+        &staging.to_string_lossy(),
+        &options,
+        progress,
+        &mut ConflictLog::silent(),
+    )
+    .and_then(|_| verify_copy(src, &staging))
+    .and_then(|_| fs::rename(&staging, target).map_err(FsError::from));
+    if let Err(e) = copied {
+        remove_entry(&staging).ok();
+        return Err(e);
+    }
+
+    // The copy is complete, so removing the source is not cancellable
+    let removed = if src_metadata.is_dir() {
+        fs::remove_dir_all(src)
+    } else {
+        fs::remove_file(src)
+    };
+    removed.map_err(|e| {
+        FsError::Io(io::Error::new(
+            e.kind(),
+            format!("Copied to {} but failed to remove source {}: {}", target.display(), src.display(), e),
+        ))
+    })
+}
+
+/// Hidden path next to `target` that a cross-filesystem move copies into first
+fn staging_path(target: &Path) -> PathBuf {
+    let name = target
+        .file_name()
+        .map(|n| n.to_string_lossy().into_owned())
+        .unwrap_or_default();
+    target.with_file_name(format!(".{}.moving-{}", name, std::process::id()))
+}
+
+/// Remove a file, symlink or directory tree
+fn remove_entry(path: &Path) -> io::Result<()> {
+    if fs::symlink_metadata(path)?.is_dir() {
+        fs::remove_dir_all(path)
+    } else {
+        fs::remove_file(path)
+    }
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
     use std::fs;
-    use std::path::Path;
 
     #[test]
     fn test_move_file() {
@@ -28,4 +169,54 @@ mod tests {
         
         let _ = fs::remove_file(dst);
     }
-}
\ No newline at end of file
+
+    #[test]
+    fn test_move_keep_both() {
+        let src = "/tmp/test_move_keep_both_src.txt";
+        let dst = "/tmp/test_move_keep_both_dst.txt";
+        let renamed = "/tmp/test_move_keep_both_dst (1).txt";
+        let _ = fs::remove_file(renamed);
+
+        fs::write(src, "new").unwrap();
+        fs::write(dst, "old").unwrap();
+
+        let options = CopyOptions { conflict: ConflictPolicy::KeepBoth, ..Default::default() };
+        let mut log = ConflictLog::silent();
+        let result = move_path_with_options(src, dst, &options, &mut ProgressReporter::silent(), &mut log);
+        assert!(!result.unwrap().copied);
+        assert_eq!(fs::read_to_string(dst).unwrap(), "old");
+        assert_eq!(fs::read_to_string(renamed).unwrap(), "new");
+        assert_eq!(log.conflicts().len(), 1);
+
+        let _ = fs::remove_file(dst);
+        let _ = fs::remove_file(renamed);
+    }
+
+    #[test]
+    fn test_move_by_copy() {
+        let src = "/tmp/test_move_by_copy_src";
+        let dst = "/tmp/test_move_by_copy_dst";
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+        fs::create_dir_all(format!("{}/nested", src)).unwrap();
+        fs::write(format!("{}/nested/file.txt", src), "data").unwrap();
+
+        // A rename across filesystems cannot be forced in a test, so call the fallback directly
+        let metadata = fs::symlink_metadata(src).unwrap();
+        let mut last = None;
+        let mut progress = ProgressReporter::new(|p| {
+            last = Some(p.clone());
+            true
+        });
+        move_by_copy(Path::new(src), &metadata, Path::new(dst), &mut progress).unwrap();
+        drop(progress);
+
+        assert!(!Path::new(src).exists());
+        assert_eq!(fs::read_to_string(format!("{}/nested/file.txt", dst)).unwrap(), "data");
+        assert!(!staging_path(Path::new(dst)).exists());
+        let last = last.unwrap();
+        assert_eq!((last.files_done, last.bytes_done), (1, 4));
+
+        let _ = fs::remove_dir_all(dst);
+    }
+}
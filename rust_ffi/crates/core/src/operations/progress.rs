@@ -0,0 +1,175 @@
+use crate::common::{FsError, FsResult};
+use std::fs;
+use std::path::Path;
+
+/// Snapshot of how far a long-running operation has progressed
+#[derive(Debug, Clone, Default)]
+pub struct Progress {
+    pub files_done: u64,
+    pub files_total: u64,
+    pub bytes_done: u64,
+    pub bytes_total: u64,
+    pub current_path: String,
+}
+
+/// Tracks progress of an operation and forwards every update to an optional observer
+/// The observer returns false to request cancellation, which takes effect before the next file
+pub struct ProgressReporter<'a> {
+    state: Progress,
+    observer: Option<Box<dyn FnMut(&Progress) -> bool + 'a>>,
+    cancelled: bool,
+}
+
+impl<'a> ProgressReporter<'a> {
+    /// Create a reporter that calls `observer` on every update
+    pub fn new(observer: impl FnMut(&Progress) -> bool + 'a) -> Self {
+        ProgressReporter {
+            state: Progress::default(),
+            observer: Some(Box::new(observer)),
+            cancelled: false,
+        }
+    }
+
+    /// Create a reporter that only tracks state
+    pub fn silent() -> Self {
+        ProgressReporter {
+            state: Progress::default(),
+            observer: None,
+            cancelled: false,
+        }
+    }
+
+    /// Current progress snapshot
+    pub fn state(&self) -> &Progress {
+        &self.state
+    }
+
+    /// Set the expected totals before work starts
+    pub fn set_totals(&mut self, files: u64, bytes: u64) {
+        self.state.files_total = files;
+        self.state.bytes_total = bytes;
+        self.emit();
+    }
+
+    /// Mark `path` as the entry currently being processed
+    /// Fails with FsError::Cancelled if cancellation was requested, so callers stop between files
+    pub fn start_file(&mut self, path: &Path) -> FsResult<()> {
+        self.state.current_path = path.to_string_lossy().into_owned();
+        self.emit();
+
+        if self.cancelled {
+            return Err(FsError::Cancelled(self.state.files_done, self.state.files_total));
+        }
+        Ok(())
+    }
+
+    /// Record `bytes` more bytes of the current entry as processed
+    pub fn add_bytes(&mut self, bytes: u64) {
+        self.state.bytes_done += bytes;
+        self.emit();
+    }
+
+    /// Mark the current entry as finished
+    pub fn finish_file(&mut self) {
+        self.state.files_done += 1;
+        self.emit();
+    }
+
+    /// Grow the expected totals, e.g. when a followed symlink turns out to be a directory
+    pub fn add_totals(&mut self, files: u64, bytes: u64) {
+        self.state.files_total += files;
+        self.state.bytes_total += bytes;
+        self.emit();
+    }
+
+    /// Count `files` entries of `bytes` total as done without processing them
+    pub fn skip(&mut self, files: u64, bytes: u64) {
+        self.state.files_done += files;
+        self.state.bytes_done += bytes;
+        self.emit();
+    }
+
+    fn emit(&mut self) {
+        if let Some(observer) = self.observer.as_mut() {
+            if !observer(&self.state) {
+                self.cancelled = true;
+            }
+        }
+    }
+}
+
+/// Count the files and bytes below `path` without following symlinks
+/// Directories themselves are not counted as files, symlinks count as files without bytes
+pub fn scan_tree(path: &Path) -> std::io::Result<(u64, u64)> {
+    let metadata = fs::symlink_metadata(path)?;
+
+    if metadata.file_type().is_symlink() {
+        return Ok((1, 0));
+    }
+    if !metadata.is_dir() {
+        return Ok((1, metadata.len()));
+    }
+
+    let mut files = 0;
+    let mut bytes = 0;
+    for entry in fs::read_dir(path)? {
+        let (entry_files, entry_bytes) = scan_tree(&entry?.path())?;
+        files += entry_files;
+        bytes += entry_bytes;
+    }
+
+    Ok((files, bytes))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_scan_tree() {
+        let root = "/tmp/test_progress_scan";
+        let _ = fs::remove_dir_all(root);
+        fs::create_dir_all(format!("{}/sub", root)).unwrap();
+        fs::write(format!("{}/a.txt", root), "12345").unwrap();
+        fs::write(format!("{}/sub/b.txt", root), "123").unwrap();
+
+        let (files, bytes) = scan_tree(Path::new(root)).unwrap();
+        assert_eq!(files, 2);
+        assert_eq!(bytes, 8);
+
+        let _ = fs::remove_dir_all(root);
+    }
+
+    #[test]
+    fn test_reporter_observer() {
+        let mut updates = 0;
+        {
+            let mut reporter = ProgressReporter::new(|_| {
+                updates += 1;
+                true
+            });
+            reporter.set_totals(1, 10);
+            reporter.start_file(Path::new("/tmp/x")).unwrap();
+            reporter.add_bytes(10);
+            reporter.finish_file();
+            assert_eq!(reporter.state().files_done, 1);
+        }
+        assert_eq!(updates, 4);
+    }
+
+    #[test]
+    fn test_reporter_cancel() {
+        let mut reporter = ProgressReporter::new(|p| p.files_done < 1);
+        reporter.set_totals(3, 0);
+        assert!(reporter.start_file(Path::new("/tmp/a")).is_ok());
+        reporter.finish_file();
+
+        match reporter.start_file(Path::new("/tmp/b")) {
+            Err(FsError::Cancelled(done, total)) => {
+                assert_eq!(done, 1);
+                assert_eq!(total, 3);
+            }
+            other => panic!("expected cancellation, got {:?}", other),
+        }
+    }
+}
@@ -0,0 +1,108 @@
+use crate::common::{FsError, FsResult};
+use sha2::{Digest, Sha256};
+use std::collections::BTreeSet;
+use std::fs;
+use std::io::{self, Read};
+use std::path::Path;
+
+/// Buffer size used when hashing file contents
+const HASH_BUFFER_SIZE: usize = 1024 * 1024;
+
+/// Check that `dst` is an exact copy of `src`
+/// Directories must hold the same entries, symlinks the same target and
+/// files the same size and SHA-256 checksum. Symlinks are never followed.
+pub fn verify_copy(src: &Path, dst: &Path) -> FsResult<()> {
+    let src_metadata = fs::symlink_metadata(src)?;
+    let dst_metadata = fs::symlink_metadata(dst)?;
+
+    let src_type = src_metadata.file_type();
+    if src_type != dst_metadata.file_type() {
+        return Err(mismatch(dst, "file type differs"));
+    }
+
+    if src_type.is_symlink() {
+        if fs::read_link(src)? != fs::read_link(dst)? {
+            return Err(mismatch(dst, "symlink target differs"));
+        }
+        return Ok(());
+    }
+
+    if src_type.is_dir() {
+        let src_names = entry_names(src)?;
+        if src_names != entry_names(dst)? {
+            return Err(mismatch(dst, "directory entries differ"));
+        }
+        for name in src_names {
+            verify_copy(&src.join(&name), &dst.join(&name))?;
+        }
+        return Ok(());
+    }
+
+    if src_metadata.len() != dst_metadata.len() {
+        return Err(mismatch(dst, "size differs"));
+    }
+    if sha256_file(src)? != sha256_file(dst)? {
+        return Err(mismatch(dst, "checksum differs"));
+    }
+    Ok(())
+}
+
+/// Compute the SHA-256 checksum of a file's contents
+pub fn sha256_file(path: &Path) -> FsResult<[u8; 32]> {
+    let mut file = fs::File::open(path)?;
+    let mut hasher = Sha256::new();
+    let mut buffer = vec![0u8; HASH_BUFFER_SIZE];
+
+    loop {
+        let read = file.read(&mut buffer)?;
+        if read == 0 {
+            break;
+        }
+        hasher.update(&buffer[..read]);
+    }
+
+    Ok(hasher.finalize().into())
+}
+
+fn entry_names(dir: &Path) -> io::Result<BTreeSet<std::ffi::OsString>> {
+    fs::read_dir(dir)?
+        .map(|entry| entry.map(|e| e.file_name()))
+        .collect()
+}
+
+fn mismatch(path: &Path, reason: &str) -> FsError {
+    FsError::Io(io::Error::new(
+        io::ErrorKind::InvalidData,
+        format!("Verification failed for {}: {}", path.display(), reason),
+    ))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_verify_copy() {
+        let src = "/tmp/test_verify_src";
+        let dst = "/tmp/test_verify_dst";
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+
+        for root in [src, dst] {
+            fs::create_dir_all(format!("{}/nested", root)).unwrap();
+            fs::write(format!("{}/nested/file.txt", root), "same").unwrap();
+        }
+        assert!(verify_copy(Path::new(src), Path::new(dst)).is_ok());
+
+        // Same size, different contents
+        fs::write(format!("{}/nested/file.txt", dst), "diff").unwrap();
+        assert!(verify_copy(Path::new(src), Path::new(dst)).is_err());
+
+        fs::write(format!("{}/nested/file.txt", dst), "same").unwrap();
+        fs::write(format!("{}/extra.txt", dst), "").unwrap();
+        assert!(verify_copy(Path::new(src), Path::new(dst)).is_err());
+
+        let _ = fs::remove_dir_all(src);
+        let _ = fs::remove_dir_all(dst);
+    }
+}